@@ -26,6 +26,7 @@
 package gorest
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"time"
@@ -36,6 +37,9 @@ const (
 	XSXRF_PARAM_NAME  = "xsrft"
 )
 
+//How often LongPoll calls its producer.
+var LongPollInterval = 250 * time.Millisecond
+
 //Used to declare a new service. 
 //See code example below:
 //
@@ -77,6 +81,11 @@ func (serv RestService) ResponseBuilder() *ResponseBuilder {
 	return serv.rb
 }
 
+//Returns the context.Context of the current request. See Context.Ctx().
+func (serv RestService) Ctx() context.Context {
+	return serv.Context.Ctx()
+}
+
 //Get the SessionData associated with the current request, as sotred in the Context.
 func (serv RestService) Session() SessionData {
 	return serv.Context.relSessionData
@@ -94,6 +103,10 @@ type Context struct {
 	args           map[string]string
 	queryArgs      map[string]string
 	relSessionData SessionData
+	ctx            context.Context
+	consumesMime   string //Negotiated for the request, see negotiate()
+	producesMime   string
+	acceptedMimes  []string //Fallbacks for producesMime, best first
 	//Response flags
 	overide            bool
 	responseCode       int
@@ -106,6 +119,35 @@ func (c *Context) Request() *http.Request {
 	return c.request
 }
 
+//Returns the context.Context of the request. It is cancelled when the client disconnects, and carries the
+//deadline set by the endpoint's "timeout" tag, if any:
+//
+//	search EndPoint `method:"GET" path:"/search/{q:string}" output:"[]Result" timeout:"5s"`
+//
+//Service methods may also take it as their first parameter, before the postdata and path parameters:
+//
+//	func (serv SearchService) Search(ctx context.Context, q string) []Result
+//
+//A method that returns because the context is done should leave the response alone, gorest then answers
+//with 503 Service Unavailable.
+func (c *Context) Ctx() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+//Returns the status to answer with, and true, once the request's context is done.
+func (c *Context) expired() (restStatus, bool) {
+	switch c.Ctx().Err() {
+	case nil:
+		return restStatus{}, false
+	case context.DeadlineExceeded:
+		return restStatus{http.StatusServiceUnavailable, "Request timed out."}, true
+	}
+	return restStatus{http.StatusServiceUnavailable, "Request cancelled."}, true
+}
+
 //Facilitates the construction of the response to be sent to the client.
 type ResponseBuilder struct {
 	ctx *Context
@@ -183,9 +225,42 @@ func (this *ResponseBuilder) Write(data []byte) *ResponseBuilder {
 	return this
 }
 
+//Holds the request open for up to delay seconds, calling producer every LongPollInterval until it returns something
+//other than nil. That is marshalled with the service's output mime type, or sent as is if it is a []byte, and written
+//to the response. producer is passed the request's context.Context.
+//If nothing is produced in time, or the request deadline passes, a 204 No Content is sent instead.
+//Returns early, writing nothing, when the client disconnects.
 func (this *ResponseBuilder) LongPoll(delay int, producer func(interface{}) interface{}) *ResponseBuilder {
-
-	return this
+	ctx := this.ctx.Ctx()
+	timer := time.NewTimer(time.Duration(delay) * time.Second)
+	defer timer.Stop()
+	ticker := time.NewTicker(LongPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if data := producer(ctx); data != nil {
+			if b, ok := data.([]byte); ok {
+				return this.WriteAndOveride(b)
+			}
+			b, state := marshalNegotiated(this.ctx, data)
+			if state.httpCode != http.StatusOK {
+				panic(state.reason)
+			}
+			return this.WriteAndOveride(b)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return this.SetResponseCode(http.StatusNoContent).WriteAndOveride(nil)
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return this.SetResponseCode(http.StatusNoContent).WriteAndOveride(nil)
+			}
+			this.Overide(true)
+			return this
+		}
+	}
 }
 
 //Cache related
@@ -0,0 +1,136 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	CircuitClosed   = "closed"    //Requests flow as normal
+	CircuitOpen     = "open"      //Requests fail straight away with a *CircuitOpenError
+	CircuitHalfOpen = "half-open" //One trial request is let through to see if the host has recovered
+)
+
+//Tracks the health of one host for RequestBuilder. After failures consecutive failures the circuit opens,
+//and requests to the host fail fast until cooldown has passed. A single trial request is then let through:
+//the circuit closes if it succeeds, and opens for another cooldown if it fails.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+var (
+	breakerFailures = 5
+	breakerCooldown = 30 * time.Second
+	breakers        = make(map[string]*circuitBreaker)
+	breakersMu      sync.Mutex
+)
+
+//Sets when RequestBuilder stops calling a host that keeps failing: after failures consecutive transport errors
+//or 5XX responses, for a period of cooldown. A failures of 0 turns circuit breaking off.
+//The defaults are 5 failures and 30 seconds. Calling this resets the state of every host.
+func SetCircuitBreaker(failures int, cooldown time.Duration) {
+	breakersMu.Lock()
+	breakerFailures = failures
+	breakerCooldown = cooldown
+	breakers = make(map[string]*circuitBreaker)
+	breakersMu.Unlock()
+}
+
+//Returns the state of the circuit for host, as in the host:port of a URL. One of CircuitClosed, CircuitOpen and CircuitHalfOpen.
+func CircuitState(host string) string {
+	breakersMu.Lock()
+	cb := breakers[host]
+	cooldown := breakerCooldown
+	breakersMu.Unlock()
+	if cb == nil {
+		return CircuitClosed
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cooldown {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+//Returns the breaker for host, or nil when circuit breaking is off.
+func breakerFor(host string) (*circuitBreaker, int, time.Duration) {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	if breakerFailures <= 0 {
+		return nil, 0, 0
+	}
+	cb, found := breakers[host]
+	if !found {
+		cb = &circuitBreaker{state: CircuitClosed}
+		breakers[host] = cb
+	}
+	return cb, breakerFailures, breakerCooldown
+}
+
+//Returns false if a request may not be sent now.
+func (this *circuitBreaker) allow(cooldown time.Duration) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	switch this.state {
+	case CircuitOpen:
+		if time.Since(this.openedAt) < cooldown {
+			return false
+		}
+		this.state = CircuitHalfOpen
+		this.trial = true
+		return true
+	case CircuitHalfOpen:
+		if this.trial { //Someone else is already trying
+			return false
+		}
+		this.trial = true
+	}
+	return true
+}
+
+func (this *circuitBreaker) record(ok bool, failures int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.trial = false
+	if ok {
+		this.state = CircuitClosed
+		this.failures = 0
+		return
+	}
+	this.failures++
+	if this.state == CircuitHalfOpen || this.failures >= failures {
+		this.state = CircuitOpen
+		this.openedAt = time.Now()
+	}
+}
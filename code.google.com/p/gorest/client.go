@@ -27,16 +27,70 @@ package gorest
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"github.com/azr/backoff"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var sharedClient *http.Client
 
+var (
+	//Time allowed for each attempt of a request, including reading the response body, unless set with RequestBuilder.Timeout().
+	DefaultRequestTimeout = 30 * time.Second
+	//Times an idempotent request is retried, unless set with RequestBuilder.Retry().
+	DefaultRetries = 2
+)
+
+//Returned when a request could not be sent or no response was received: the host could not be reached, the connection
+//broke, or the request timed out.
+type TransportError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (this *TransportError) Error() string {
+	return this.Method + " " + this.URL + ": " + this.Err.Error()
+}
+
+//Returns true if the request was abandoned because it took longer than its timeout.
+func (this *TransportError) Timeout() bool {
+	if this.Err == context.DeadlineExceeded {
+		return true
+	}
+	ne, ok := this.Err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+//Returned, together with the response, when the server answered with an unexpected status: anything outside 2XX,
+//or for Get() anything other than the expected code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Response   *http.Response
+}
+
+func (this *StatusError) Error() string {
+	return this.Status
+}
+
+//Returned without sending the request when the circuit for the host is open. See SetCircuitBreaker().
+type CircuitOpenError struct {
+	Host string
+}
+
+func (this *CircuitOpenError) Error() string {
+	return "Circuit open for host " + this.Host + ", request not sent."
+}
+
 //Use this if you have a *http.Client instance that you specifically want to use. 
 //Otherwise just use NewRequestBuilder(), which uses the http.Client maintained by GoRest.
 func NewRequestBuilderFromClient(client *http.Client, url string) (*RequestBuilder, error) {
@@ -44,7 +98,7 @@ func NewRequestBuilderFromClient(client *http.Client, url string) (*RequestBuild
 	if err != nil {
 		return nil, err
 	}
-	rb := RequestBuilder{client, Application_Json, req}
+	rb := newRequestBuilder(client, req)
 	return &rb, nil
 }
 
@@ -60,19 +114,67 @@ func NewRequestBuilder(url string) (*RequestBuilder, error) {
 	if err != nil {
 		return nil, err
 	}
-	rb := RequestBuilder{sharedClient, Application_Json, req}
+	rb := newRequestBuilder(sharedClient, req)
 	return &rb, nil
 }
 
+func newRequestBuilder(client *http.Client, req *http.Request) RequestBuilder {
+	return RequestBuilder{
+		client:             client,
+		defaultContentType: Application_Json,
+		_req:               req,
+		ctx:                context.Background(),
+		timeout:            DefaultRequestTimeout,
+		retries:            DefaultRetries,
+	}
+}
+
+//Builds and sends requests to a REST service.
+//
+//GET, HEAD, OPTIONS and DELETE requests are retried when the host can not be reached or answers with 502, 503 or 504,
+//waiting longer after every attempt. POSTs are never retried. Requests to a host that keeps failing are refused
+//for a while by its circuit breaker, see SetCircuitBreaker().
+//
+//Errors are a *TransportError when no response was received, a *StatusError when the response has an unexpected
+//status, and a *CircuitOpenError when the request was not sent at all.
 type RequestBuilder struct {
 	client             *http.Client
 	defaultContentType string
 	_req               *http.Request
+	ctx                context.Context
+	timeout            time.Duration
+	retries            int
+	backOff            *backoff.ExponentialBackOff
 }
 
 func (this *RequestBuilder) Request() *http.Request {
 	return this._req
 }
+
+//Sets the time allowed for each attempt of the request, including reading the response body. 0 means no limit.
+func (this *RequestBuilder) Timeout(d time.Duration) *RequestBuilder {
+	this.timeout = d
+	return this
+}
+
+//Sets the number of times an idempotent request is retried. 0 turns retrying off.
+func (this *RequestBuilder) Retry(retries int) *RequestBuilder {
+	this.retries = retries
+	return this
+}
+
+//Sets the backoff between retries. The default starts at half a second and grows by half with every retry.
+func (this *RequestBuilder) BackOff(b *backoff.ExponentialBackOff) *RequestBuilder {
+	this.backOff = b
+	return this
+}
+
+//Sets a context.Context for the request; no attempts are made once it is done.
+//Pass serv.Ctx() to call other services on behalf of a request without outliving it.
+func (this *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	this.ctx = ctx
+	return this
+}
 func (this *RequestBuilder) UseContentType(mime string) *RequestBuilder {
 	this.defaultContentType = mime
 	return this
@@ -165,18 +267,18 @@ func (this *RequestBuilder) Delete() (*http.Response, error) {
 	//	//this._req.URL = u
 	this._req.Method = DELETE
 
-	return this.client.Do(this._req)
+	return this.do(0)
 }
 
 func (this *RequestBuilder) Head() (*http.Response, error) {
 	this._req.Method = HEAD
-	return this.client.Do(this._req)
+	return this.do(0)
 }
 
 func (this *RequestBuilder) Options(opts *[]string) (*http.Response, error) {
 	this._req.Method = OPTIONS
 
-	res, err := this.client.Do(this._req)
+	res, err := this.do(0)
 	if err != nil {
 		return res, err
 	}
@@ -190,21 +292,20 @@ func (this *RequestBuilder) Options(opts *[]string) (*http.Response, error) {
 func (this *RequestBuilder) Get(i interface{}, expecting int) (*http.Response, error) {
 	//this._req.URL = u
 	this._req.Method = GET
+	if this._req.Header.Get("Accept") == "" {
+		this._req.Header.Set("Accept", this.defaultContentType)
+	}
 
-	res, err := this.client.Do(this._req)
+	res, err := this.do(expecting)
 	if err != nil {
 		return res, err
 	}
 
-	if res.StatusCode == expecting {
-		buf := new(bytes.Buffer)
-		io.Copy(buf, res.Body)
-		res.Body.Close()
-		err = BytesToInterface(buf, i, this.defaultContentType)
-		return res, nil
-	}
-
-	return res, errors.New(res.Status)
+	buf := new(bytes.Buffer)
+	io.Copy(buf, res.Body)
+	res.Body.Close()
+	err = BytesToInterface(buf, i, this.defaultContentType)
+	return res, nil
 }
 func (this *RequestBuilder) Post(i interface{}) (*http.Response, error) {
 	this._req.Method = POST
@@ -213,7 +314,97 @@ func (this *RequestBuilder) Post(i interface{}) (*http.Response, error) {
 		return nil, err
 	}
 	this._req.Body = ioutil.NopCloser(bytes.NewBuffer(bb))
+	this._req.ContentLength = int64(len(bb))
+	this._req.Header.Set("Content-Type", this.defaultContentType)
+
+	return this.do(0)
+
+}
+
+//Sends the request, retrying idempotent methods as configured. A status other than expecting, or outside 2XX
+//when expecting is 0, is returned as a *StatusError along with the response.
+func (this *RequestBuilder) do(expecting int) (*http.Response, error) {
+	host := this._req.URL.Host
+	cb, failures, cooldown := breakerFor(host)
+
+	retries := 0
+	switch this._req.Method {
+	case GET, HEAD, OPTIONS, DELETE:
+		retries = this.retries
+	}
+	b := this.backOff
+	if b == nil {
+		b = backoff.NewExponential()
+	}
+	b.Reset()
+
+	for attempt := 0; ; attempt++ {
+		if cb != nil && !cb.allow(cooldown) {
+			return nil, &CircuitOpenError{host}
+		}
+		res, err := this.attempt()
+		if cb != nil {
+			cb.record(err == nil && res.StatusCode < 500, failures)
+		}
+
+		retry := err != nil && this.ctx.Err() == nil
+		if res != nil {
+			switch res.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retry = true
+			}
+		}
+		if !retry || attempt >= retries {
+			if err != nil {
+				return nil, &TransportError{this._req.Method, this._req.URL.String(), err}
+			}
+			if (expecting == 0 && (res.StatusCode < 200 || res.StatusCode > 299)) || (expecting != 0 && res.StatusCode != expecting) {
+				return res, &StatusError{this._req.Method, this._req.URL.String(), res.StatusCode, res.Status, res}
+			}
+			return res, nil
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		wait := time.NewTimer(b.GetSleepTime())
+		select {
+		case <-wait.C:
+		case <-this.ctx.Done():
+			wait.Stop()
+			return nil, &TransportError{this._req.Method, this._req.URL.String(), this.ctx.Err()}
+		}
+		b.IncrementCurrentInterval()
+	}
+}
 
-	return this.client.Do(this._req)
+//Makes one attempt at sending the request, bounded by the timeout.
+func (this *RequestBuilder) attempt() (*http.Response, error) {
+	ctx, cancel := this.ctx, context.CancelFunc(func() {})
+	if this.timeout > 0 {
+		ctx, cancel = context.WithTimeout(this.ctx, this.timeout)
+	}
+	res, err := this.client.Do(this._req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	res.Body = &cancelOnClose{res.Body, cancel}
+	return res, nil
+}
+
+//Releases the timeout of a request once its response has been read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
+func (this *cancelOnClose) Close() error {
+	err := this.ReadCloser.Close()
+	this.cancel()
+	return err
 }
@@ -0,0 +1,145 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"github.com/azr/backoff"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastBackOff() *backoff.ExponentialBackOff {
+	b := backoff.NewExponential()
+	b.InitialInterval = time.Millisecond
+	b.MaxInterval = 5 * time.Millisecond
+	return b
+}
+
+//Serves 503 for the first failFirst requests, and then 200 after sleeping for delay.
+func flakyServer(failFirst int32, delay time.Duration, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failFirst {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(delay)
+		w.Write([]byte("ok"))
+	}))
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls int32
+	server := flakyServer(2, 0, &calls)
+	defer server.Close()
+
+	str := ""
+	rb, _ := NewRequestBuilder(server.URL)
+	res, err := rb.BackOff(fastBackOff()).Get(&str, 200)
+	AssertEqual(err, nil, "Retried GET error", t)
+	AssertEqual(res.StatusCode, 200, "Retried GET status", t)
+	AssertEqual(str, "ok", "Retried GET body", t)
+	AssertEqual(atomic.LoadInt32(&calls), int32(3), "Retried GET attempts", t)
+
+	calls = 0
+	rb, _ = NewRequestBuilder(server.URL)
+	res, err = rb.BackOff(fastBackOff()).Post("data")
+	AssertEqual(atomic.LoadInt32(&calls), int32(1), "POST not retried", t)
+	if se, ok := err.(*StatusError); ok {
+		AssertEqual(se.StatusCode, 503, "POST StatusError code", t)
+		AssertEqual(se.Response, res, "POST StatusError response", t)
+	} else {
+		t.Error("Expecting a *StatusError for a 503, got:", err)
+	}
+
+	calls = 0
+	rb, _ = NewRequestBuilder(server.URL)
+	_, err = rb.Retry(1).BackOff(fastBackOff()).Delete()
+	AssertEqual(atomic.LoadInt32(&calls), int32(2), "Retries limited", t)
+	if _, ok := err.(*StatusError); !ok {
+		t.Error("Expecting a *StatusError once retries run out, got:", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	var calls int32
+	server := flakyServer(0, 200*time.Millisecond, &calls)
+	defer server.Close()
+
+	str := ""
+	rb, _ := NewRequestBuilder(server.URL)
+	start := time.Now()
+	_, err := rb.Timeout(20*time.Millisecond).Retry(0).Get(&str, 200)
+	AssertEqual(time.Since(start) < 150*time.Millisecond, true, "Timed out early", t)
+	if te, ok := err.(*TransportError); ok {
+		AssertEqual(te.Timeout(), true, "TransportError is a timeout", t)
+	} else {
+		t.Error("Expecting a *TransportError for a timeout, got:", err)
+	}
+
+	server.Close()
+	rb, _ = NewRequestBuilder(server.URL)
+	_, err = rb.Retry(0).Head()
+	if te, ok := err.(*TransportError); ok {
+		AssertEqual(te.Timeout(), false, "Refused connection is not a timeout", t)
+	} else {
+		t.Error("Expecting a *TransportError for a closed server, got:", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	SetCircuitBreaker(3, 50*time.Millisecond)
+	defer SetCircuitBreaker(5, 30*time.Second)
+
+	var calls int32
+	server := flakyServer(3, 0, &calls)
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	for i := 0; i < 3; i++ {
+		rb, _ := NewRequestBuilder(server.URL)
+		rb.Retry(0).Head()
+	}
+	AssertEqual(CircuitState(u.Host), CircuitOpen, "Circuit opens after failures", t)
+
+	rb, _ := NewRequestBuilder(server.URL)
+	_, err := rb.Head()
+	if _, ok := err.(*CircuitOpenError); !ok {
+		t.Error("Expecting a *CircuitOpenError, got:", err)
+	}
+	AssertEqual(atomic.LoadInt32(&calls), int32(3), "Open circuit sends nothing", t)
+
+	time.Sleep(60 * time.Millisecond)
+	AssertEqual(CircuitState(u.Host), CircuitHalfOpen, "Circuit half open after cooldown", t)
+	rb, _ = NewRequestBuilder(server.URL)
+	res, err := rb.Retry(0).Head()
+	AssertEqual(err, nil, "Trial request error", t)
+	AssertEqual(res.StatusCode, 200, "Trial request status", t)
+	AssertEqual(CircuitState(u.Host), CircuitClosed, "Circuit closes after success", t)
+}
@@ -0,0 +1,119 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ContextService struct {
+	RestService `root:"/context-service/"`
+
+	wait     EndPoint `method:"GET" path:"/wait/{Millis:int}" output:"string" timeout:"100ms"`
+	deadline EndPoint `method:"GET" path:"/deadline/" output:"bool" timeout:"1m"`
+	poll     EndPoint `method:"GET" path:"/poll/{After:int}" output:"string" timeout:"300ms"`
+	put      EndPoint `method:"PUT" path:"/put/{Id:int}" postdata:"string"`
+}
+
+func (serv ContextService) Wait(ctx context.Context, Millis int) string {
+	select {
+	case <-time.After(time.Duration(Millis) * time.Millisecond):
+		return "done"
+	case <-ctx.Done():
+		return ""
+	}
+}
+
+func (serv ContextService) Deadline() bool {
+	_, found := serv.Ctx().Deadline()
+	return found
+}
+
+func (serv ContextService) Poll(After int) string {
+	calls := 0
+	serv.ResponseBuilder().LongPoll(1, func(ctx interface{}) interface{} {
+		if calls++; calls > After {
+			return "polled"
+		}
+		return nil
+	})
+	return ""
+}
+
+var contextPut string
+
+func (serv ContextService) Put(ctx context.Context, data string, Id int) {
+	contextPut = fmt.Sprint(ctx != nil, " ", data, " ", Id)
+}
+
+func TestContext(t *testing.T) {
+	LongPollInterval = 10 * time.Millisecond
+	RegisterServiceOnPath(MUX_ROOT, new(ContextService))
+	root := RootPath + "context-service/"
+
+	code, body := interceptedGet(t, root+"wait/10")
+	AssertEqual(code, 200, "Within timeout", t)
+	AssertEqual(body, "done", "Within timeout body", t)
+
+	start := time.Now()
+	code, body = interceptedGet(t, root+"wait/5000")
+	AssertEqual(code, 503, "Past timeout", t)
+	AssertEqual(body, "Request timed out.", "Past timeout body", t)
+	AssertEqual(time.Since(start) < time.Second, true, "Method cancelled at deadline", t)
+
+	_, body = interceptedGet(t, root+"deadline/")
+	AssertEqual(body, "true", "Deadline from RestService", t)
+
+	code, body = interceptedGet(t, root+"poll/3")
+	AssertEqual(code, 200, "LongPoll produced", t)
+	AssertEqual(body, "polled", "LongPoll body", t)
+
+	code, body = interceptedGet(t, root+"poll/1000")
+	AssertEqual(code, 204, "LongPoll timed out", t)
+	AssertEqual(body, "", "LongPoll timed out body", t)
+
+	req, _ := http.NewRequest("PUT", root+"put/7", strings.NewReader("payload"))
+	req.Header.Set("Content-Type", Application_Json)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 200, "PUT with context", t)
+	AssertEqual(contextPut, "true payload 7", "Context passed before postdata and path parameters", t)
+}
+
+func TestContextTimeoutTag(t *testing.T) {
+	defer func() {
+		AssertEqual(recover() != nil, true, "Bad timeout panics", t)
+	}()
+	makeEndPointStruct(`method:"GET" path:"/x/" output:"string" timeout:"soon"`, "/")
+}
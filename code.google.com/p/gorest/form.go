@@ -0,0 +1,260 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Form (application/x-www-form-urlencoded): Handles structs and string keyed maps. Struct fields are named by their
+//"form" tag, or else their "json" tag, or else the field name. Fields may be strings, bools, numbers or slices of these,
+//slices being sent as repeated keys. Embedded structs are flattened, other nested structs are not supported.
+//Postdata is only read as a form by services that consume it, or endpoints tagged input:"application/x-www-form-urlencoded".
+//
+//	type Login struct {
+//	    User     string `form:"user"`
+//	    Password string `form:"pass"`
+//	    Remember bool   `form:"remember,omitempty"`
+//	}
+func NewFormMarshaller() *Marshaller {
+	m := Marshaller{formMarshal, formUnMarshal}
+	return &m
+}
+
+func formMarshal(v interface{}) ([]byte, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		if err := formFromStruct(values, rv); err != nil {
+			return nil, err
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.New("form: only string keyed maps can be marshalled")
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if err := formAdd(values, k.String(), rv.MapIndex(k)); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("form: can not marshal " + rv.Type().String())
+	}
+	return []byte(values.Encode()), nil
+}
+
+func formFromStruct(values url.Values, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := formFromStruct(values, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name, omitEmpty := formFieldName(f)
+		if name == "" {
+			continue
+		}
+		fv := rv.Field(i)
+		if omitEmpty && isZero(fv) {
+			continue
+		}
+		if err := formAdd(values, name, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formAdd(values url.Values, name string, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			if err := formAdd(values, name, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		values.Add(name, fmt.Sprint(v.Interface()))
+		return nil
+	}
+	return errors.New("form: field " + name + " of type " + v.Type().String() + " can not be marshalled")
+}
+
+//Returns the key of a struct field, or "" if it is not sent, and whether it has the omitempty option.
+func formFieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag := f.Tag.Get("form")
+	if tag == "" {
+		tag = f.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", false
+	}
+	name, opts := tag, ""
+	if i := strings.Index(tag, ","); i != -1 {
+		name, opts = tag[:i], tag[i+1:]
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(","+opts+",", ",omitempty,")
+}
+
+func formUnMarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("form: can only unmarshal into a pointer")
+	}
+	rv = rv.Elem()
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return formToStruct(values, rv)
+	case reflect.Map:
+		t := rv.Type()
+		if t.Key().Kind() != reflect.String {
+			return errors.New("form: only string keyed maps can be unmarshalled")
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
+		}
+		for k, vals := range values {
+			e := reflect.New(t.Elem()).Elem()
+			if err := formSet(e, vals, k); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), e)
+		}
+		return nil
+	}
+	return errors.New("form: can not unmarshal into " + rv.Type().String())
+}
+
+func formToStruct(values url.Values, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := formToStruct(values, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name, _ := formFieldName(f)
+		if vals, found := values[name]; found && name != "" {
+			if err := formSet(rv.Field(i), vals, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Sets v from the values sent for one key: all of them for a slice, the first one otherwise.
+func formSet(v reflect.Value, vals []string, name string) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := formSet(s.Index(i), []string{val}, name); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+
+	s := vals[0]
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 10, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 10, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var n float64
+		if n, err = strconv.ParseFloat(s, v.Type().Bits()); err == nil {
+			v.SetFloat(n)
+		}
+	default:
+		return errors.New("form: field " + name + " of type " + v.Type().String() + " can not be unmarshalled")
+	}
+	if err != nil {
+		return errors.New("form: invalid value for " + name + ": " + s)
+	}
+	return nil
+}
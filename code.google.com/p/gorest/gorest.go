@@ -26,12 +26,15 @@
 package gorest
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"net/url"
+	"reflect"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type GoRestService interface {
@@ -59,6 +62,7 @@ type endPointStruct struct {
 	signitureLen         int
 	paramLen             int
 	inputMime            string
+	producesMimes        []string //Written by the method itself, without a Marshaller
 	outputType           string
 	outputTypeIsArray    bool
 	outputTypeIsMap      bool
@@ -69,6 +73,19 @@ type endPointStruct struct {
 	parentTypeName       string
 	methodNumberInParent int
 	role                 string
+	outputGoType         reflect.Type      //Set for GET, used to describe the endpoint
+	postdataGoType       reflect.Type      //Set for POST and PUT
+	paramRules           map[string][]rule //Validation rules of path and query parameters, by name
+	takesContext         bool              //The method's first parameter is a context.Context
+	timeout              time.Duration
+}
+
+//Returns the position of the first parameter of the method that is taken from the request: the postdata, or the first path parameter.
+func (ep endPointStruct) argOffset() int {
+	if ep.takesContext {
+		return 2
+	}
+	return 1
 }
 
 type restStatus struct {
@@ -104,6 +121,11 @@ func newManager() *manager {
 }
 func init() {
 	RegisterMarshaller(Application_Json, NewJSONMarshaller())
+	RegisterMarshaller(Application_Xml, NewXMLMarshaller())
+	RegisterMarshaller(Text_Xml, NewXMLMarshaller())
+	RegisterMarshaller(Application_Protobuf, NewProtobufMarshaller())
+	RegisterMarshaller(Application_Msgpack, NewMsgpackMarshaller())
+	RegisterMarshaller(Application_WwwForm, NewFormMarshaller())
 
 }
 
@@ -203,6 +225,11 @@ func (_ manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if openAPIPath != "" && r.Method == GET && r.URL.Path == openAPIPath {
+		serveOpenAPI(w)
+		return
+	}
+
 	if ep, args, queryArgs, xsrft, found := getEndPointByUrl(r.Method, url_); found {
 
 		ctx := new(Context)
@@ -212,7 +239,19 @@ func (_ manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx.queryArgs = queryArgs
 		ctx.xsrftoken = xsrft
 
-		data, state := prepareServe(ctx, ep)
+		var cancel context.CancelFunc
+		if ep.timeout > 0 {
+			ctx.ctx, cancel = context.WithTimeout(r.Context(), ep.timeout)
+		} else {
+			ctx.ctx, cancel = context.WithCancel(r.Context())
+		}
+		defer cancel()
+
+		var data []byte
+		state := negotiate(ctx, ep)
+		if state.httpCode == http.StatusOK {
+			data, state = intercept(ctx, ep)
+		}
 
 		if state.httpCode == http.StatusOK {
 			switch ep.requestMethod {
@@ -230,13 +269,13 @@ func (_ manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				{
 					if ctx.responseCode == 0 {
 						if !ctx.responseMimeSet {
-							w.Header().Set("Content-Type", _manager().getType(ep.parentTypeName).producesMime)
+							w.Header().Set("Content-Type", ctx.producesMime)
 						}
 						w.WriteHeader(getDefaultResponseCode(ep.requestMethod))
 					} else {
 						if !ctx.dataHasBeenWritten {
 							if !ctx.responseMimeSet {
-								w.Header().Set("Content-Type", _manager().getType(ep.parentTypeName).producesMime)
+								w.Header().Set("Content-Type", ctx.producesMime)
 							}
 							w.WriteHeader(ctx.responseCode)
 						}
@@ -0,0 +1,193 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+//Signiture of functions to be used as Interceptors. An interceptor is called for every request routed to the
+//services it is registered for, before the service method. It passes the request on by calling inv.Proceed(),
+//which returns once the service method has returned, so that the interceptor can wrap the call:
+//
+//	gorest.RegisterInterceptor(func(inv *gorest.Invocation) {
+//	    start := time.Now()
+//	    inv.Proceed()
+//	    log.Println(inv.EndPoint.Method, inv.EndPoint.Path, inv.Status(), time.Since(start))
+//	})
+//
+//An interceptor that does not call Proceed() answers the request itself, either through inv.ResponseBuilder()
+//or with inv.Fail(), and the service method is never called:
+//
+//	gorest.RegisterServiceInterceptor(new(HelloService), func(inv *gorest.Invocation) {
+//	    if !limiter.Allow(inv.Context.Request().RemoteAddr) {
+//	        inv.Fail(429, "Too many requests.")
+//	        return
+//	    }
+//	    inv.Proceed()
+//	})
+type Interceptor func(inv *Invocation)
+
+//Describes the endpoint a request has been routed to.
+type EndPointInfo struct {
+	Service   string            //Full name of the service type, e.g. "example.com/hello/HelloService"
+	Name      string            //Name of the EndPoint field, e.g. "sayHello"
+	Method    string            //HTTP method, e.g. "GET"
+	Path      string            //Path declared on the endpoint, including the service root
+	Realm     string            //Security realm of the service, if any
+	Role      string            //Role required by the endpoint, if any
+	Consumes  string            //Mime type of postdata
+	Produces  string            //Mime type of output
+	PathArgs  map[string]string //Path parameters of the request, by name
+	QueryArgs map[string]string //Query parameters of the request, by name
+	Timeout   time.Duration     //Timeout declared on the endpoint, if any
+}
+
+//A request on its way through the interceptor chain to the service method.
+type Invocation struct {
+	Context  *Context
+	EndPoint EndPointInfo
+
+	ep     endPointStruct
+	next   []Interceptor
+	called bool
+	data   []byte
+	state  restStatus
+}
+
+var (
+	interceptors        []Interceptor
+	serviceInterceptors = make(map[string][]Interceptor)
+	interceptorsMu      sync.RWMutex
+)
+
+//Registers an Interceptor for all services. Interceptors are called in the order they are registered,
+//global ones before those registered for a particular service.
+func RegisterInterceptor(i Interceptor) {
+	interceptorsMu.Lock()
+	interceptors = append(interceptors, i)
+	interceptorsMu.Unlock()
+}
+
+//Registers an Interceptor for the service h only. h is the same value that is passed to RegisterService.
+func RegisterServiceInterceptor(h interface{}, i Interceptor) {
+	t := reflect.TypeOf(h)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		log.Panic(ERROR_INVALID_INTERFACE)
+	}
+	t = t.Elem()
+	name := t.PkgPath() + "/" + t.Name()
+
+	interceptorsMu.Lock()
+	serviceInterceptors[name] = append(serviceInterceptors[name], i)
+	interceptorsMu.Unlock()
+}
+
+//Passes the request on to the next interceptor in the chain, or calls the service method if this is the last one.
+//The service method is called at most once per request.
+func (this *Invocation) Proceed() {
+	if len(this.next) > 0 {
+		i := this.next[0]
+		this.next = this.next[1:]
+		i(this)
+		return
+	}
+	if !this.called {
+		this.called = true
+		this.data, this.state = prepareServe(this.Context, this.ep)
+	}
+}
+
+//Returns a ResponseBuilder for the request, with which an interceptor can set headers or answer the request itself.
+func (this *Invocation) ResponseBuilder() *ResponseBuilder {
+	return &ResponseBuilder{ctx: this.Context}
+}
+
+//Ends the request with the given http code and reason, as if the service method had failed.
+//Called after Proceed(), it replaces whatever the service method returned.
+func (this *Invocation) Fail(code int, reason string) {
+	this.data = nil
+	this.state = restStatus{code, reason}
+}
+
+//Returns the http code the response will be sent with, as it stands.
+func (this *Invocation) Status() int {
+	if this.state.httpCode != http.StatusOK {
+		return this.state.httpCode
+	}
+	if this.Context.responseCode != 0 {
+		return this.Context.responseCode
+	}
+	if !this.called {
+		return http.StatusOK
+	}
+	return getDefaultResponseCode(this.ep.requestMethod)
+}
+
+//Returns true once the service method has been called.
+func (this *Invocation) Proceeded() bool {
+	return this.called
+}
+
+//Runs the interceptors registered for the endpoint's service, ending with the service method.
+func intercept(context *Context, ep endPointStruct) ([]byte, restStatus) {
+	interceptorsMu.RLock()
+	chain := make([]Interceptor, 0, len(interceptors)+len(serviceInterceptors[ep.parentTypeName]))
+	chain = append(chain, interceptors...)
+	chain = append(chain, serviceInterceptors[ep.parentTypeName]...)
+	interceptorsMu.RUnlock()
+
+	if len(chain) == 0 {
+		return prepareServe(context, ep)
+	}
+
+	servMeta := _manager().getType(ep.parentTypeName)
+	inv := &Invocation{
+		Context: context,
+		EndPoint: EndPointInfo{
+			Service:   ep.parentTypeName,
+			Name:      ep.name,
+			Method:    ep.requestMethod,
+			Path:      ep.signiture,
+			Realm:     servMeta.realm,
+			Role:      ep.role,
+			Consumes:  servMeta.consumesMime,
+			Produces:  servMeta.producesMime,
+			PathArgs:  context.args,
+			QueryArgs: context.queryArgs,
+			Timeout:   ep.timeout,
+		},
+		ep:    ep,
+		next:  chain,
+		state: restStatus{http.StatusOK, ""},
+	}
+	inv.Proceed()
+	return inv.data, inv.state
+}
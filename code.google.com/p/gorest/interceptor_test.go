@@ -0,0 +1,121 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type InterceptedService struct {
+	RestService `root:"/intercepted-service/"`
+
+	greet     EndPoint `method:"GET" path:"/greet/{Name:string}" output:"string"`
+	crash     EndPoint `method:"GET" path:"/crash/" output:"string"`
+	storeNote EndPoint `method:"POST" path:"/note/" postdata:"string"`
+}
+
+var interceptorTrace []string
+
+func (serv InterceptedService) Greet(Name string) string {
+	interceptorTrace = append(interceptorTrace, "method")
+	return "Hello " + Name
+}
+
+func (serv InterceptedService) Crash() string {
+	panic("crashed")
+}
+
+func (serv InterceptedService) StoreNote(note string) {
+	interceptorTrace = append(interceptorTrace, "method")
+}
+
+func TestInterceptors(t *testing.T) {
+	service := new(InterceptedService)
+	RegisterInterceptor(func(inv *Invocation) {
+		if inv.EndPoint.Service != "code.google.com/p/gorest/InterceptedService" {
+			inv.Proceed()
+			return
+		}
+		interceptorTrace = append(interceptorTrace, "global:"+inv.EndPoint.Name)
+		inv.Proceed()
+		interceptorTrace = append(interceptorTrace, "global-after")
+	})
+	RegisterServiceInterceptor(service, func(inv *Invocation) {
+		defer func() {
+			if r := recover(); r != nil {
+				inv.Fail(http.StatusInternalServerError, "Recovered")
+			}
+		}()
+		interceptorTrace = append(interceptorTrace, "service:"+inv.EndPoint.Method+":"+inv.EndPoint.PathArgs["Name"])
+		if inv.EndPoint.PathArgs["Name"] == "blocked" {
+			inv.ResponseBuilder().SetResponseCode(http.StatusForbidden).WriteAndOveride([]byte("Blocked"))
+			return
+		}
+		inv.Proceed()
+		interceptorTrace = append(interceptorTrace, "status:"+string(rune('0'+inv.Status()/100)))
+	})
+	RegisterServiceOnPath(MUX_ROOT, service)
+
+	root := RootPath + "intercepted-service/"
+
+	interceptorTrace = nil
+	code, body := interceptedGet(t, root+"greet/Joe")
+	AssertEqual(code, 200, "Intercepted call", t)
+	AssertEqual(body, "Hello Joe", "Intercepted call body", t)
+	AssertEqual(strings.Join(interceptorTrace, ","), "global:greet,service:GET:Joe,method,status:2,global-after", "Interceptor order", t)
+
+	interceptorTrace = nil
+	code, body = interceptedGet(t, root+"greet/blocked")
+	AssertEqual(code, 403, "Short-circuited call", t)
+	AssertEqual(body, "Blocked", "Short-circuited call body", t)
+	AssertEqual(strings.Join(interceptorTrace, ","), "global:greet,service:GET:blocked,global-after", "Method not called", t)
+
+	code, body = interceptedGet(t, root+"crash/")
+	AssertEqual(code, 500, "Recovered call", t)
+	AssertEqual(body, "Recovered", "Recovered call body", t)
+
+	interceptorTrace = nil
+	res, err := http.Post(root+"note/", Application_Json, strings.NewReader("\"note\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 202, "Intercepted POST", t)
+	AssertEqual(strings.Join(interceptorTrace, ","), "global:storeNote,service:POST:,method,status:2,global-after", "Intercepted POST order", t)
+}
+
+func interceptedGet(t *testing.T, url string) (int, string) {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
@@ -28,6 +28,10 @@ package gorest
 import (
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"reflect"
+	"sort"
 )
 
 //A Marshaller represents the two functions used to marshal/unmarshal interfaces back and forth.
@@ -57,6 +61,16 @@ func GetMarshallerByMime(mime string) (m *Marshaller) {
 	return
 }
 
+//Returns the mime types of all registered Marshallers, sorted.
+func RegisteredMimes() []string {
+	mimes := make([]string, 0, len(marshallers))
+	for mime := range marshallers {
+		mimes = append(mimes, mime)
+	}
+	sort.Strings(mimes)
+	return mimes
+}
+
 //Predefined Marshallers
 
 //JSON: This makes the JSON Marshaller. The Marshaller uses pkg: json
@@ -82,3 +96,38 @@ func xmlMarshal(v interface{}) ([]byte, error) {
 func xmlUnMarshal(data []byte, v interface{}) error {
 	return xml.Unmarshal(data, v)
 }
+
+//Protocol Buffers: The Marshaller uses pkg: github.com/golang/protobuf/proto, and only handles types generated by protoc-gen-go
+//(or anything else implementing proto.Message).
+func NewProtobufMarshaller() *Marshaller {
+	m := Marshaller{protobufMarshal, protobufUnMarshal}
+	return &m
+}
+func protobufMarshal(v interface{}) ([]byte, error) {
+	pb, err := protoMessage(v)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pb)
+}
+func protobufUnMarshal(data []byte, v interface{}) error {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("Type " + reflect.TypeOf(v).String() + " is not a proto.Message")
+	}
+	return proto.Unmarshal(data, pb)
+}
+
+//Returns v as a proto.Message. Service methods return generated messages by value, whose methods are on the pointer.
+func protoMessage(v interface{}) (proto.Message, error) {
+	if pb, ok := v.(proto.Message); ok {
+		return pb, nil
+	}
+	rv := reflect.ValueOf(v)
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	if pb, ok := ptr.Interface().(proto.Message); ok {
+		return pb, nil
+	}
+	return nil, errors.New("Type " + rv.Type().String() + " is not a proto.Message")
+}
@@ -38,6 +38,9 @@ const (
 	Application_Rtf           = "application/rtf"
 	Application_Xml           = "application/xml"
 	Application_Json          = "application/json"
+	Application_Msgpack       = "application/x-msgpack"
+	Application_Protobuf      = "application/x-protobuf"
+	Application_WwwForm       = "application/x-www-form-urlencoded"
 	Application_Zip           = "application/zip"
 	Audio_Xaiff               = "audio/x-aiff"
 	Audio_Xwav                = "audio/x-wav"
@@ -0,0 +1,308 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+//MessagePack (http://msgpack.org): Values go through encoding/json on their way in and out, so field names,
+//omitempty and custom json.Marshalers behave exactly as with the JSON Marshaller.
+//Byte slices travel as base64 strings, as they do in JSON.
+func NewMsgpackMarshaller() *Marshaller {
+	m := Marshaller{msgpackMarshal, msgpackUnMarshal}
+	return &m
+}
+
+func msgpackMarshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := msgpackEncode(buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func msgpackUnMarshal(data []byte, v interface{}) error {
+	d := &msgpackDecoder{data: data}
+	generic, err := d.decode()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errors.New("msgpack: unexpected data after the value")
+	}
+	asJson, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(asJson, v)
+}
+
+//Encodes the values encoding/json decodes to: nil, bool, json.Number, string, []interface{} and map[string]interface{}.
+func msgpackEncode(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if x {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			msgpackEncodeInt(buf, i)
+		} else if f, err := x.Float64(); err == nil {
+			buf.WriteByte(0xcb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		} else {
+			return err
+		}
+	case string:
+		msgpackEncodeLen(buf, len(x), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(x)
+	case []interface{}:
+		msgpackEncodeLen(buf, len(x), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, e := range x {
+			if err := msgpackEncode(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		msgpackEncodeLen(buf, len(x), 0x80, 16, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			msgpackEncode(buf, k)
+			if err := msgpackEncode(buf, x[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: can not encode %T", v)
+	}
+	return nil
+}
+
+func msgpackEncodeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i < 128:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.Write([]byte{0xd0, byte(int8(i))})
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+//Writes the header of a string, array or map of length n: the fix form when n is below fixMax, else the 8 (if any), 16 or 32 bit form.
+func msgpackEncodeLen(buf *bytes.Buffer, n int, fix byte, fixMax int, b8, b16, b32 byte) {
+	switch {
+	case n < fixMax:
+		buf.WriteByte(fix | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		buf.Write([]byte{b8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(b32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (this *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || this.pos+n > len(this.data) {
+		return nil, errMsgpackShort
+	}
+	b := this.data[this.pos : this.pos+n]
+	this.pos += n
+	return b, nil
+}
+
+//Reads an unsigned big endian integer of n bytes.
+func (this *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := this.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+//Decodes the next value into nil, bool, int64, uint64, float64, string, []byte, []interface{} or map[string]interface{}.
+func (this *msgpackDecoder) decode() (interface{}, error) {
+	b, err := this.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return this.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return this.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return this.object(int(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return this.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		u, err := this.uint(n)
+		if err != nil {
+			return nil, err
+		}
+		shift := uint(64 - 8*n)
+		return int64(u<<shift) >> shift, nil //Sign extend
+	case 0xca:
+		u, err := this.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := this.uint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := this.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return this.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := this.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := this.next(int(n))
+		return append([]byte(nil), b...), err
+	case 0xdc, 0xdd:
+		n, err := this.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return this.array(int(n))
+	case 0xde, 0xdf:
+		n, err := this.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return this.object(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x", c)
+}
+
+func (this *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := this.next(n)
+	return string(b), err
+}
+
+func (this *msgpackDecoder) array(n int) (interface{}, error) {
+	if n > len(this.data)-this.pos { //Every element takes at least a byte
+		return nil, errMsgpackShort
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := this.decode()
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (this *msgpackDecoder) object(n int) (interface{}, error) {
+	if 2*n > len(this.data)-this.pos {
+		return nil, errMsgpackShort
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := this.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := this.decode()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok {
+			m[s] = v
+		} else {
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, nil
+}
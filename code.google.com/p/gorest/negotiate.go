@@ -0,0 +1,187 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Picks the Marshallers for the request. Postdata is unmarshalled according to the Content-Type header and output
+//marshalled according to the Accept header, from among the registered Marshallers. The service's consumes and
+//produces mime types are used when the client does not say, and preferred when the client accepts several.
+//Answers 415 Unsupported Media Type or 406 Not Acceptable when no registered Marshaller matches.
+//An endpoint can list types it writes itself in its produces tag, e.g. produces:"application/pdf,text/csv",
+//which are then acceptable too. The method checks the Accept header and writes such bodies with the ResponseBuilder.
+//Form postdata is only decoded as a form when the service consumes forms or the endpoint says so with its input tag,
+//e.g. input:"application/x-www-form-urlencoded". Other endpoints read a form typed body with the service's consumes
+//Marshaller, as that is what clients such as curl send by default.
+//Negotiation only applies to types that go through a Marshaller: strings and numbers are sent as they are,
+//and streams have their own content types.
+func negotiate(context *Context, ep endPointStruct) restStatus {
+	servMeta := _manager().getType(ep.parentTypeName)
+	context.consumesMime = servMeta.consumesMime
+	context.producesMime = servMeta.producesMime
+	context.acceptedMimes = nil
+
+	if ep.postdataGoType != nil && needsMarshaller(ep.postdataGoType) {
+		if ct := context.request.Header.Get("Content-Type"); ct != "" {
+			mt, _, err := mime.ParseMediaType(ct)
+			if err != nil || GetMarshallerByMime(mt) == nil {
+				return restStatus{http.StatusUnsupportedMediaType, "Unsupported Content-Type: " + ct + ". Expecting one of: " + strings.Join(RegisteredMimes(), ", ")}
+			}
+			if mt != Application_WwwForm || servMeta.consumesMime == mt || ep.inputMime == mt {
+				context.consumesMime = mt
+			}
+		}
+	}
+
+	if ep.outputGoType != nil && needsMarshaller(ep.outputGoType) {
+		context.writer.Header().Add("Vary", "Accept")
+		if accept := context.request.Header.Get("Accept"); accept != "" {
+			mimes := acceptedMimes(accept, servMeta.producesMime, ep.producesMimes)
+			if len(mimes) == 0 {
+				return restStatus{http.StatusNotAcceptable, "Not Acceptable: " + accept + ". Available: " + strings.Join(append(RegisteredMimes(), ep.producesMimes...), ", ")}
+			}
+			context.producesMime = mimes[0]
+			context.acceptedMimes = mimes
+		}
+	}
+	return restStatus{http.StatusOK, ""}
+}
+
+//Marshals v with the negotiated Marshaller. Should that Marshaller not handle the type, as XML does not handle maps
+//and protobuf only handles generated messages, the next type the client accepts is tried. Answers 406 Not Acceptable
+//when none of them can, or 500 when the client did not ask for anything and the service's own type fails.
+func marshalNegotiated(context *Context, v interface{}) ([]byte, restStatus) {
+	b, err := InterfaceToBytes(v, context.producesMime)
+	if err == nil {
+		return b, restStatus{http.StatusOK, ""}
+	}
+	if len(context.acceptedMimes) == 0 {
+		return nil, restStatus{http.StatusInternalServerError, "Internal server error. Could not Marshal/UnMarshal data: " + err.Error()}
+	}
+	for _, mt := range context.acceptedMimes {
+		if mt == context.producesMime {
+			continue
+		}
+		if b, e := InterfaceToBytes(v, mt); e == nil {
+			context.producesMime = mt
+			return b, restStatus{http.StatusOK, ""}
+		}
+	}
+	return nil, restStatus{http.StatusNotAcceptable, "Not Acceptable: " + context.request.Header.Get("Accept") + ". Could not Marshal data: " + err.Error()}
+}
+
+func needsMarshaller(t reflect.Type) bool {
+	if isStreamType(t) {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+type acceptRange struct {
+	mime  string
+	q     float64
+	index int
+}
+
+//Returns the registered mime types, and the endpoint's own extra ones, that the Accept header allows, best first, or
+//nil if none. Each type takes the quality of the most specific range matching it. Among types of equal quality preferred comes first, and then the others in
+//the order their ranges appear in the header.
+func acceptedMimes(accept string, preferred string, extra []string) []string {
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, found := params["q"]; found {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mt, q, len(ranges)})
+	}
+
+	accepted := make([]acceptRange, 0)
+	seen := make(map[string]bool)
+	for _, mt := range append(RegisteredMimes(), extra...) {
+		if seen[mt] {
+			continue
+		}
+		seen[mt] = true
+		match, specificity := acceptRange{}, 0
+		for _, r := range ranges {
+			s := 0
+			switch {
+			case r.mime == mt:
+				s = 3
+			case r.mime == "*/*":
+				s = 1
+			case strings.HasSuffix(r.mime, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(r.mime, "*")):
+				s = 2
+			}
+			if s > specificity {
+				match, specificity = r, s
+			}
+		}
+		if specificity > 0 && match.q > 0 {
+			accepted = append(accepted, acceptRange{mt, match.q, match.index})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		a, b := accepted[i], accepted[j]
+		if a.q != b.q {
+			return a.q > b.q
+		}
+		if (a.mime == preferred) != (b.mime == preferred) {
+			return a.mime == preferred
+		}
+		return a.index < b.index
+	})
+
+	if len(accepted) == 0 {
+		return nil
+	}
+	mimes := make([]string, len(accepted))
+	for i, r := range accepted {
+		mimes[i] = r.mime
+	}
+	return mimes
+}
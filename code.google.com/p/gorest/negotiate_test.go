@@ -0,0 +1,223 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Parcel struct {
+	Ref              *string `protobuf:"bytes,1,opt,name=ref" json:"ref,omitempty"`
+	Weight           *int32  `protobuf:"varint,2,opt,name=weight" json:"weight,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Parcel) Reset()         { *m = Parcel{} }
+func (m *Parcel) String() string { return proto.CompactTextString(m) }
+func (*Parcel) ProtoMessage()    {}
+
+type Consignee struct {
+	XMLName xml.Name `xml:"consignee" json:"-" form:"-"`
+	Name    string   `json:"name" xml:"name" form:"name"`
+	Port    string   `json:"port" xml:"port"`
+	Items   []int    `json:"items" xml:"item"`
+}
+
+//Like most printable documents, not something XML can marshal
+type Manifest struct {
+	Title  string
+	Header map[string]interface{}
+}
+
+type NegotiationService struct {
+	RestService `root:"/negotiation-service/"`
+
+	getConsignee    EndPoint `method:"GET" path:"/consignee/" output:"Consignee"`
+	addConsignee    EndPoint `method:"POST" path:"/consignee/" postdata:"Consignee" input:"application/x-www-form-urlencoded"`
+	updateConsignee EndPoint `method:"PUT" path:"/consignee/" postdata:"Consignee"`
+	getParcel       EndPoint `method:"GET" path:"/parcel/" output:"Parcel"`
+	getManifest     EndPoint `method:"GET" path:"/manifest/" output:"Manifest" produces:"text/csv"`
+	getName         EndPoint `method:"GET" path:"/name/" output:"string"`
+}
+
+var negotiatedConsignee Consignee
+
+func (serv NegotiationService) GetConsignee() Consignee {
+	return Consignee{Name: "Ceylon Tea", Port: "CMB", Items: []int{1, 2}}
+}
+
+func (serv NegotiationService) AddConsignee(c Consignee) {
+	negotiatedConsignee = c
+}
+
+func (serv NegotiationService) UpdateConsignee(c Consignee) {
+	negotiatedConsignee = c
+}
+
+func (serv NegotiationService) GetManifest() Manifest {
+	if strings.Contains(serv.Context.Request().Header.Get("Accept"), "text/csv") {
+		serv.ResponseBuilder().SetContentType("text/csv")
+		serv.ResponseBuilder().SetResponseCode(200).WriteAndOveride([]byte("port\nCMB\n"))
+		return Manifest{}
+	}
+	return Manifest{Title: "Manifest", Header: map[string]interface{}{"port": "CMB"}}
+}
+
+func (serv NegotiationService) GetParcel() Parcel {
+	return Parcel{Ref: proto.String("P-1"), Weight: proto.Int32(12)}
+}
+
+func (serv NegotiationService) GetName() string {
+	return "name"
+}
+
+func TestNegotiation(t *testing.T) {
+	RegisterServiceOnPath(MUX_ROOT, new(NegotiationService))
+	root := RootPath + "negotiation-service/"
+	expecting := Consignee{Name: "Ceylon Tea", Port: "CMB", Items: []int{1, 2}}
+
+	res, body := negotiatedRequest(t, "GET", root+"consignee/", "Accept", "application/xml", nil)
+	AssertEqual(res.StatusCode, 200, "Accept XML", t)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Xml, "Accept XML content type", t)
+	AssertEqual(res.Header.Get("Vary"), "Accept", "Vary on Accept", t)
+	c := Consignee{}
+	AssertEqual(xml.Unmarshal(body, &c), nil, "XML body", t)
+	AssertEqual(reflect.DeepEqual(c, Consignee{XMLName: xml.Name{Local: "consignee"}, Name: "Ceylon Tea", Port: "CMB", Items: []int{1, 2}}), true, "XML body content", t)
+
+	res, body = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "application/x-msgpack", nil)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Msgpack, "Accept msgpack content type", t)
+	c = Consignee{}
+	AssertEqual(msgpackUnMarshal(body, &c), nil, "Msgpack body", t)
+	AssertEqual(reflect.DeepEqual(c, expecting), true, "Msgpack body content", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "text/html, */*;q=0.1", nil)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Json, "Wildcard gets the service default", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "text/html, application/json;q=0", nil)
+	AssertEqual(res.StatusCode, 406, "Not acceptable", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "application/xml, application/json", nil)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Json, "Service default preferred at equal quality", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "application/*;q=0.1, application/json;q=0.2, application/xml;q=0.4", nil)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Xml, "Most specific range sets the quality", t)
+
+	browser := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	res, body = negotiatedRequest(t, "GET", root+"manifest/", "Accept", browser, nil)
+	AssertEqual(res.StatusCode, 200, "Browser Accept header on a map", t)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Json, "Falls back to JSON when XML cannot marshal", t)
+	AssertEqual(string(body), `{"Title":"Manifest","Header":{"port":"CMB"}}`, "Fallback body", t)
+
+	res, body = negotiatedRequest(t, "GET", root+"manifest/", "Accept", "text/csv", nil)
+	AssertEqual(res.StatusCode, 200, "Type the endpoint produces itself", t)
+	AssertEqual(res.Header.Get("Content-Type"), "text/csv", "Endpoint content type", t)
+	AssertEqual(string(body), "port\nCMB\n", "Endpoint body", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "text/csv", nil)
+	AssertEqual(res.StatusCode, 406, "Type another endpoint produces", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"manifest/", "Accept", "application/xml", nil)
+	AssertEqual(res.StatusCode, 406, "Only XML accepted on a map", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "application/x-protobuf", nil)
+	AssertEqual(res.StatusCode, 406, "Protobuf on a type that is not a message", t)
+
+	res, _ = negotiatedRequest(t, "GET", root+"consignee/", "Accept", "application/x-protobuf, application/x-msgpack;q=0.5", nil)
+	AssertEqual(res.StatusCode, 200, "Protobuf with a fallback", t)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Msgpack, "Falls back to the next accepted type", t)
+
+	res, body = negotiatedRequest(t, "GET", root+"name/", "Accept", "text/plain", nil)
+	AssertEqual(res.StatusCode, 200, "Strings are not negotiated", t)
+	AssertEqual(string(body), "name", "String body", t)
+
+	res, body = negotiatedRequest(t, "GET", root+"parcel/", "Accept", "application/x-protobuf", nil)
+	AssertEqual(res.Header.Get("Content-Type"), Application_Protobuf, "Accept protobuf content type", t)
+	p := new(Parcel)
+	AssertEqual(proto.Unmarshal(body, p), nil, "Protobuf body", t)
+	AssertEqual(*p.Ref, "P-1", "Protobuf body ref", t)
+	AssertEqual(*p.Weight, int32(12), "Protobuf body weight", t)
+
+	form := url.Values{"name": {"Ceylon Tea"}, "port": {"CMB"}, "items": {"1", "2"}}.Encode()
+	res, _ = negotiatedRequest(t, "POST", root+"consignee/", "Content-Type", Application_WwwForm, []byte(form))
+	AssertEqual(res.StatusCode, 202, "Form postdata", t)
+	AssertEqual(reflect.DeepEqual(negotiatedConsignee, expecting), true, "Form postdata content", t)
+
+	res, _ = negotiatedRequest(t, "PUT", root+"consignee/", "Content-Type", Application_WwwForm, []byte(`{"name":"Spice Route","port":"HMB"}`))
+	AssertEqual(res.StatusCode, 200, "JSON postdata sent as a form", t)
+	AssertEqual(negotiatedConsignee.Name+"/"+negotiatedConsignee.Port, "Spice Route/HMB", "Form typed body read as JSON", t)
+
+	data, _ := msgpackMarshal(Consignee{Name: "Lanka Spice"})
+	res, _ = negotiatedRequest(t, "POST", root+"consignee/", "Content-Type", Application_Msgpack, data)
+	AssertEqual(res.StatusCode, 202, "Msgpack postdata", t)
+	AssertEqual(negotiatedConsignee.Name, "Lanka Spice", "Msgpack postdata content", t)
+
+	res, body = negotiatedRequest(t, "POST", root+"consignee/", "Content-Type", "text/csv", []byte("a,b"))
+	AssertEqual(res.StatusCode, 415, "Unsupported media type", t)
+	AssertEqual(strings.Contains(string(body), Application_Msgpack), true, "Supported types listed", t)
+}
+
+func TestMsgpack(t *testing.T) {
+	in := map[string]interface{}{
+		"small": 1, "negative": -5, "int16": -3000, "big": int64(1) << 40, "float": 2.5,
+		"string": strings.Repeat("s", 40), "list": make([]int, 20), "nil": nil, "bool": true,
+	}
+	data, err := msgpackMarshal(in)
+	AssertEqual(err, nil, "Msgpack marshal", t)
+	AssertEqual(data[0], byte(0x89), "Msgpack fixmap", t)
+
+	out := make(map[string]interface{})
+	AssertEqual(msgpackUnMarshal(data, &out), nil, "Msgpack unmarshal", t)
+	AssertEqual(out["big"], float64(int64(1)<<40), "Msgpack int64", t)
+	AssertEqual(out["int16"], float64(-3000), "Msgpack int16", t)
+	AssertEqual(out["float"], 2.5, "Msgpack float", t)
+	AssertEqual(out["string"], strings.Repeat("s", 40), "Msgpack str8", t)
+	AssertEqual(len(out["list"].([]interface{})), 20, "Msgpack array16", t)
+	AssertEqual(out["nil"], nil, "Msgpack nil", t)
+	AssertEqual(out["bool"], true, "Msgpack bool", t)
+
+	AssertEqual(msgpackUnMarshal(data[:len(data)-1], &out) != nil, true, "Msgpack truncated", t)
+	AssertEqual(msgpackUnMarshal([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &out) != nil, true, "Msgpack bogus length", t)
+}
+
+func negotiatedRequest(t *testing.T, method string, url string, header string, value string, body []byte) (*http.Response, []byte) {
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	req.Header.Set(header, value)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, _ := ioutil.ReadAll(res.Body)
+	return res, data
+}
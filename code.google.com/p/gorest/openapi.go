@@ -0,0 +1,365 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Describes the API in the generated OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type openAPISecurityScheme struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type openAPIOperation struct {
+	OperationId string                      `json:"operationId"`
+	Tags        []string                    `json:"tags"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	openAPIPath string
+	openAPIInfo OpenAPIInfo
+)
+
+//Serves the OpenAPI document of all registered services on GET requests to path, which is
+//matched against the full request path, e.g. "/api/openapi.json".
+func RegisterOpenAPI(path string, info OpenAPIInfo) {
+	openAPIPath = path
+	openAPIInfo = info
+}
+
+//Generates an OpenAPI 3.0 document describing every endpoint registered so far.
+//Schemas for the output and postdata types are built by reflecting on the Go types of the service methods,
+//following the encoding/json field naming rules.
+func GenerateOpenAPI(info OpenAPIInfo) ([]byte, error) {
+	return json.MarshalIndent(buildOpenAPI(info), "", "  ")
+}
+
+func serveOpenAPI(w http.ResponseWriter) {
+	data, err := GenerateOpenAPI(openAPIInfo)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", Application_Json)
+	w.Write(data)
+}
+
+func buildOpenAPI(info OpenAPIInfo) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.0",
+		Info:    info,
+		Paths:   make(map[string]map[string]*openAPIOperation),
+	}
+	if restManager == nil {
+		return doc
+	}
+
+	sb := newSchemaBuilder()
+	secured := false
+
+	keys := make([]string, 0, len(restManager.endpoints))
+	for k := range restManager.endpoints {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ep := restManager.endpoints[k]
+		meta := restManager.getType(ep.parentTypeName)
+		path, op := describeEndPoint(ep, meta, sb)
+		if meta.realm != "" {
+			secured = true
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(ep.requestMethod)] = op
+	}
+
+	doc.Components.Schemas = sb.defs
+	if secured {
+		doc.Components.SecuritySchemes = map[string]*openAPISecurityScheme{
+			"xsrft": &openAPISecurityScheme{Type: "apiKey", Name: XSXRF_PARAM_NAME, In: "query"},
+		}
+	}
+	return doc
+}
+
+func describeEndPoint(ep endPointStruct, meta serviceMetaData, sb *schemaBuilder) (string, *openAPIOperation) {
+	serviceName := ep.parentTypeName[strings.LastIndex(ep.parentTypeName, "/")+1:]
+	op := &openAPIOperation{
+		OperationId: serviceName + "." + ep.name,
+		Tags:        []string{serviceName},
+		Responses:   make(map[string]*openAPIResponse),
+	}
+
+	pathPart := ep.signiture
+	if i := strings.Index(pathPart, "?"); i != -1 {
+		pathPart = pathPart[:i]
+	}
+	parts := strings.Split(strings.Trim(pathPart, "/"), "/")
+	for pos, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			continue
+		}
+		for _, par := range ep.params {
+			if par.positionInPath != pos {
+				continue
+			}
+			name := par.name
+			p := &openAPIParameter{Name: name, In: "path", Required: true, Schema: paramSchema(par.typeName)}
+			if ep.isVariableLength {
+				name = "varArgs"
+				p.Name = name
+				p.Description = "One or more '/' separated " + par.typeName + " values."
+			}
+			parts[pos] = "{" + name + "}"
+			op.Parameters = append(op.Parameters, p)
+		}
+	}
+	for _, par := range ep.queryParams {
+		op.Parameters = append(op.Parameters, &openAPIParameter{Name: par.name, In: "query", Schema: paramSchema(par.typeName)})
+	}
+
+	if ep.postdataGoType == multipartReaderType {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]*openAPIMediaType{"multipart/form-data": &openAPIMediaType{&openAPISchema{Type: "object"}}},
+		}
+	} else if ep.postdataGoType != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]*openAPIMediaType{meta.consumesMime: &openAPIMediaType{sb.schemaOf(ep.postdataGoType)}},
+		}
+	}
+
+	code := strconv.Itoa(getDefaultResponseCode(ep.requestMethod))
+	res := &openAPIResponse{Description: http.StatusText(getDefaultResponseCode(ep.requestMethod))}
+	if ep.outputGoType != nil {
+		res.Content = map[string]*openAPIMediaType{meta.producesMime: &openAPIMediaType{sb.schemaOf(ep.outputGoType)}}
+	}
+	op.Responses[code] = res
+
+	if meta.realm != "" {
+		op.Security = []map[string][]string{{"xsrft": {}}}
+		op.Responses["403"] = &openAPIResponse{Description: http.StatusText(http.StatusForbidden)}
+		if ep.role != "" {
+			op.Description = "Requires role: " + ep.role
+		}
+	}
+
+	return "/" + strings.Join(parts, "/"), op
+}
+
+func paramSchema(typeName string) *openAPISchema {
+	switch strings.ToLower(typeName) {
+	case "int", "int64":
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case "int32":
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case "bool":
+		return &openAPISchema{Type: "boolean"}
+	case "float32":
+		return &openAPISchema{Type: "number", Format: "float"}
+	case "float64":
+		return &openAPISchema{Type: "number", Format: "double"}
+	}
+	return &openAPISchema{Type: "string"}
+}
+
+//Builds schemas for Go types. Named structs are emitted once under components/schemas and referenced from
+//everywhere else, which also takes care of recursive types.
+type schemaBuilder struct {
+	defs  map[string]*openAPISchema
+	names map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{defs: make(map[string]*openAPISchema), names: make(map[reflect.Type]string)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (this *schemaBuilder) schemaOf(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	if t == readerType {
+		return &openAPISchema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: this.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: this.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return this.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + this.define(t)}
+	}
+	return &openAPISchema{} //interface{} and anything else can hold any value
+}
+
+func (this *schemaBuilder) define(t reflect.Type) string {
+	if name, found := this.names[t]; found {
+		return name
+	}
+	name := t.Name()
+	if _, taken := this.defs[name]; taken {
+		name = strings.Replace(t.PkgPath(), "/", ".", -1) + "." + t.Name()
+	}
+	this.names[t] = name
+	this.defs[name] = &openAPISchema{} //Placeholder, so that recursive references find the name
+	*this.defs[name] = *this.structSchema(t)
+	return name
+}
+
+func (this *schemaBuilder) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	this.addFields(s, t)
+	return s
+}
+
+//Adds the exported fields of t to s the way encoding/json would name them, promoting the fields of embedded structs.
+func (this *schemaBuilder) addFields(s *openAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		opts := ""
+		if i := strings.Index(tag, ","); i != -1 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			this.addFields(s, ft)
+			continue
+		}
+		if f.PkgPath != "" { //Unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		if strings.Contains(","+opts+",", ",string,") {
+			s.Properties[name] = &openAPISchema{Type: "string"}
+		} else {
+			s.Properties[name] = this.schemaOf(f.Type)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	data, err := GenerateOpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := new(openAPIDocument)
+	if err := json.Unmarshal(data, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	root := "/home/now/the/future/types-service"
+	op, found := doc.Paths[root+"/string/{Bool}/{Int}"]["get"]
+	if !found {
+		t.Fatal("OpenAPI: getString not described")
+	}
+	AssertEqual(op.OperationId, "TypesService.getString", "OpenAPI operationId", t)
+	AssertEqual(len(op.Parameters), 4, "OpenAPI parameter count", t)
+	AssertEqual(op.Parameters[0].In, "path", "OpenAPI path parameter", t)
+	AssertEqual(op.Parameters[1].Schema.Type, "integer", "OpenAPI path parameter type", t)
+	AssertEqual(op.Parameters[2].In, "query", "OpenAPI query parameter", t)
+	AssertEqual(op.Parameters[2].Required, false, "OpenAPI query parameter optional", t)
+	AssertEqual(op.Description, "Requires role: string-user", "OpenAPI role", t)
+	AssertEqual(len(op.Security), 1, "OpenAPI realm security", t)
+
+	op = doc.Paths[root+"/arraystruct/{FName}/{Age}"]["get"]
+	schema := op.Responses["200"].Content["application/json"].Schema
+	AssertEqual(schema.Type, "array", "OpenAPI output array", t)
+	AssertEqual(schema.Items.Ref, "#/components/schemas/User", "OpenAPI output item ref", t)
+
+	op = doc.Paths[root+"/mapstruct/{Bool}/{Int}"]["post"]
+	schema = op.RequestBody.Content["application/json"].Schema
+	AssertEqual(schema.Type, "object", "OpenAPI postdata map", t)
+	AssertEqual(schema.AdditionalProperties.Ref, "#/components/schemas/User", "OpenAPI postdata map values", t)
+	if _, found := op.Responses["202"]; !found {
+		t.Error("OpenAPI: POST should default to 202")
+	}
+
+	_, found = doc.Paths[root+"/var/{varArgs}"]["get"]
+	AssertEqual(found, true, "OpenAPI variable length path", t)
+
+	user := doc.Components.Schemas["User"]
+	AssertEqual(user.Properties["Age"].Type, "integer", "OpenAPI struct field", t)
+	AssertEqual(user.Properties["Weight"].Format, "float", "OpenAPI struct field format", t)
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	RegisterOpenAPI(MUX_ROOT+"openapi.json", OpenAPIInfo{Title: "Test", Version: "1.0"})
+	defer RegisterOpenAPI("", OpenAPIInfo{})
+
+	res, err := http.Get(RootPath + "openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	AssertEqual(res.StatusCode, 200, "OpenAPI served", t)
+
+	doc := new(openAPIDocument)
+	if err := json.NewDecoder(res.Body).Decode(doc); err != nil {
+		t.Fatal(err)
+	}
+	AssertEqual(doc.Info.Title, "Test", "OpenAPI info", t)
+}
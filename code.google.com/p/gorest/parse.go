@@ -29,6 +29,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type argumentData struct {
@@ -131,9 +132,23 @@ func makeEndPointStruct(tags reflect.StructTag, serviceRoot string) endPointStru
 		if tag := tags.Get("input"); tag != "" {
 			ms.inputMime = tag
 		}
+		if tag := tags.Get("produces"); tag != "" { //Types the method writes itself, e.g. produces:"application/pdf,text/csv"
+			for _, mt := range strings.Split(tag, ",") {
+				if mt = strings.TrimSpace(mt); mt != "" {
+					ms.producesMimes = append(ms.producesMimes, mt)
+				}
+			}
+		}
 		if tag := tags.Get("role"); tag != "" {
 			ms.role = tag
 		}
+		if tag := tags.Get("timeout"); tag != "" {
+			if d, err := time.ParseDuration(tag); err == nil && d > 0 {
+				ms.timeout = d
+			} else {
+				log.Panic("Invalid timeout:[" + tag + "] in endpoint declaration, expecting a duration such as \"30s\". Endpoint: " + ms.signiture)
+			}
+		}
 
 		if tag := tags.Get("postdata"); tag != "" {
 			ms.postdataType = tag
@@ -154,6 +169,10 @@ func makeEndPointStruct(tags reflect.StructTag, serviceRoot string) endPointStru
 		}
 
 		parseParams(ms)
+
+		if tag := tags.Get("validate"); tag != "" {
+			parseParamRules(ms, tag)
+		}
 		return *ms
 	}
 
@@ -27,6 +27,7 @@ package gorest
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"log"
 	"net/http"
@@ -34,6 +35,8 @@ import (
 	"strings"
 )
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 const (
 	ERROR_INVALID_INTERFACE = "RegisterService(interface{}) takes a pointer to a struct that inherits from type RestService. Example usage: gorest.RegisterService(new(ServiceOne)) "
 )
@@ -99,11 +102,21 @@ func mapFieldsToMethods(t reflect.Type, f reflect.StructField, typeFullName stri
 			if !methFound {
 				log.Panic("Method name not found. " + panicMethNotFound(methFound, ep, t, f, methodName))
 			}
+			ep.takesContext = method.Type.NumIn() > 1 && method.Type.In(1) == contextType
 			if !isLegalForRequestType(method.Type, ep) {
 				log.Panic("Parameter list not matching. " + panicMethNotFound(methFound, ep, t, f, methodName))
 			}
 		}
 		ep.methodNumberInParent = methodNumberInParent
+		if ep.requestMethod == GET {
+			ep.outputGoType = method.Type.Out(0)
+		}
+		if ep.requestMethod == POST || ep.requestMethod == PUT {
+			ep.postdataGoType = method.Type.In(ep.argOffset())
+			if !isStreamType(ep.postdataGoType) {
+				checkRules(ep.postdataGoType, make(map[reflect.Type]bool))
+			}
+		}
 		_manager().addEndPoint(ep)
 		log.Println("Registerd service:", t.Name(), " endpoint:", ep.requestMethod, ep.signiture)
 	}
@@ -133,6 +146,9 @@ func isLegalForRequestType(methType reflect.Type, ep endPointStruct) (cool bool)
 		}
 
 	}
+	if ep.takesContext {
+		numInputIgnore++ //Followed by the context.Context
+	}
 
 	if (methType.NumIn() - numInputIgnore) != (ep.paramLen + len(ep.queryParams)) {
 		cool = false
@@ -140,8 +156,15 @@ func isLegalForRequestType(methType reflect.Type, ep endPointStruct) (cool bool)
 		cool = false
 	} else {
 		//Check the first parameter type for POST and PUT
-		if numInputIgnore == 2 {
-			methVal := methType.In(1)
+		if ep.requestMethod == POST || ep.requestMethod == PUT {
+			methVal := methType.In(ep.argOffset())
+			if st, found := streamTypes[ep.postdataType]; found && !ep.postdataTypeIsArray && !ep.postdataTypeIsMap {
+				if methVal != st {
+					cool = false
+					return
+				}
+				goto Params
+			}
 			if ep.postdataTypeIsArray {
 				if methVal.Kind() == reflect.Slice {
 					methVal = methVal.Elem()
@@ -164,6 +187,7 @@ func isLegalForRequestType(methType reflect.Type, ep endPointStruct) (cool bool)
 				return
 			}
 		}
+	Params:
 		//Check the rest of input path param types
 		i := numInputIgnore
 		if ep.isVariableLength {
@@ -309,30 +333,43 @@ Run:
 	servVal.FieldByName("RestService").FieldByName("Context").Set(reflect.ValueOf(context))
 
 	arrArgs := make([]reflect.Value, 0)
+	invalid := make(ValidationErrors, 0)
+	if ep.takesContext {
+		arrArgs = append(arrArgs, reflect.ValueOf(context.Ctx()))
+	}
 
 	targetMethod := servVal.Type().Method(ep.methodNumberInParent)
 	//For POST and PUT, make and add the first "postdata" argument to the argument list
 	if ep.requestMethod == POST || ep.requestMethod == PUT {
 
-		//Get postdata here
-		//TODO: Also check if this is a multipart post and handle as required.
-		buf := new(bytes.Buffer)
-		io.Copy(buf, context.request.Body)
-		body := buf.String()
+		if postType := targetMethod.Type.In(ep.argOffset()); isStreamType(postType) {
+			//The method reads the body itself
+			if v, state := streamArg(context.request, postType); state.httpCode == http.StatusOK {
+				arrArgs = append(arrArgs, v)
+			} else {
+				return nil, state
+			}
+		} else {
+			//Get postdata here
+			buf := new(bytes.Buffer)
+			io.Copy(buf, context.request.Body)
+			body := buf.String()
 
-		//println("This is the body of the post:",body)
+			//println("This is the body of the post:",body)
 
-		if v, state := makeArg(body, targetMethod.Type.In(1), servMeta.consumesMime); state.httpCode != http.StatusBadRequest {
-			arrArgs = append(arrArgs, v)
-		} else {
-			return nil, state
+			if v, state := makeArg(body, postType, context.consumesMime); state.httpCode != http.StatusBadRequest {
+				arrArgs = append(arrArgs, v)
+				invalid = append(invalid, validateValue(v, "")...)
+			} else {
+				return nil, state
+			}
 		}
 	}
 
 	if len(context.args) == ep.paramLen || (ep.isVariableLength && ep.paramLen == 1) {
-		startIndex := 1
+		startIndex := ep.argOffset()
 		if ep.requestMethod == POST || ep.requestMethod == PUT {
-			startIndex = 2
+			startIndex++
 		}
 
 		if ep.isVariableLength {
@@ -358,6 +395,7 @@ Run:
 
 				if v, state := makeArg(dat, targetMethod.Type.In(startIndex), servMeta.consumesMime); state.httpCode != http.StatusBadRequest {
 					arrArgs = append(arrArgs, v)
+					invalid = append(invalid, applyRules(ep.paramRules[par.name], v, par.name, dat != "")...)
 				} else {
 					return nil, state
 				}
@@ -376,6 +414,7 @@ Run:
 
 			if v, state := makeArg(dat, targetMethod.Type.In(startIndex), servMeta.consumesMime); state.httpCode != http.StatusBadRequest {
 				arrArgs = append(arrArgs, v)
+				invalid = append(invalid, applyRules(ep.paramRules[par.name], v, par.name, dat != "")...)
 			} else {
 				return nil, state
 			}
@@ -383,6 +422,13 @@ Run:
 			startIndex++
 		}
 
+		if len(invalid) > 0 {
+			return nil, validationFailed(context, invalid)
+		}
+		if state, done := context.expired(); done {
+			return nil, state
+		}
+
 		//Now call the actual method with the data
 		var ret []reflect.Value
 		if ep.isVariableLength {
@@ -391,14 +437,24 @@ Run:
 			ret = servVal.Method(ep.methodNumberInParent).Call(arrArgs)
 		}
 
+		//A method that gave up because the deadline passed or the client went away has nothing useful to send
+		if state, done := context.expired(); done && !context.dataHasBeenWritten {
+			return nil, state
+		}
+
+		if len(ret) == 1 && ep.outputGoType == readerType {
+			if !context.dataHasBeenWritten {
+				writeOutputStream(context, ret[0].Interface())
+			}
+			return nil, restStatus{http.StatusOK, ""}
+		}
+
 		if len(ret) == 1 { //This is when we have just called a GET
 			//At this stage we should be ready to write the response to client
-			if bytarr, err := InterfaceToBytes(ret[0].Interface(), servMeta.producesMime); err == nil {
-				return bytarr, restStatus{http.StatusOK, ""}
-			} else {
-				//This is an internal error with the registered marshaller not being able to marshal internal structs
-				return nil, restStatus{http.StatusInternalServerError, "Internal server error. Could not Marshal/UnMarshal data: " + err.Error()}
+			if context.overide { //The method wrote its own body, which may be of a type only it produces
+				return nil, restStatus{http.StatusOK, ""}
 			}
+			return marshalNegotiated(context, ret[0].Interface())
 		} else {
 
 			return nil, restStatus{http.StatusOK, ""}
@@ -0,0 +1,170 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+var (
+	readerType          = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	multipartReaderType = reflect.TypeOf((*multipart.Reader)(nil))
+)
+
+//Postdata types that are handed the request body as it arrives, instead of it being read and unmarshalled first.
+//
+//	upload EndPoint `method:"POST" path:"/upload/{Name:string}" postdata:"io.Reader"`
+//	scan   EndPoint `method:"POST" path:"/scan/" postdata:"multipart.Reader"`
+//
+//	func (serv DocService) Upload(body io.Reader, Name string)
+//	func (serv DocService) Scan(parts *multipart.Reader)
+var streamTypes = map[string]reflect.Type{
+	"io.Reader":        readerType,
+	"multipart.Reader": multipartReaderType,
+}
+
+func isStreamType(t reflect.Type) bool {
+	return t == readerType || t == multipartReaderType
+}
+
+//Makes the postdata argument for a method that streams the request body.
+func streamArg(request *http.Request, template reflect.Type) (reflect.Value, restStatus) {
+	if template == multipartReaderType {
+		mr, err := request.MultipartReader()
+		if err != nil {
+			return reflect.ValueOf(nil), restStatus{http.StatusBadRequest, "Expecting a multipart/form-data or multipart/mixed entity. (" + err.Error() + ")"}
+		}
+		return reflect.ValueOf(mr), restStatus{http.StatusOK, ""}
+	}
+	body := reflect.New(readerType).Elem()
+	body.Set(reflect.ValueOf(request.Body))
+	return body, restStatus{http.StatusOK, ""}
+}
+
+//Remembers the status written, so that ServeHTTP does not write another.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (this *statusWriter) WriteHeader(code int) {
+	if this.code == 0 {
+		this.code = code
+	}
+	this.ResponseWriter.WriteHeader(code)
+}
+
+func (this *statusWriter) Write(data []byte) (int, error) {
+	if this.code == 0 {
+		this.code = http.StatusOK
+	}
+	return this.ResponseWriter.Write(data)
+}
+
+//Sends content to the client as the response, and discards the data returned by the endpoint method.
+//Range, If-Range, If-Modified-Since and If-None-Match requests are answered as http.ServeContent does,
+//with a Content-Length, 206 Partial Content and so on. The content type is taken from the name's extension,
+//or sniffed, unless it has been set with SetContentType. A zero modtime leaves out Last-Modified.
+//
+//	func (serv DocService) GetPdf(Id string) string {
+//	    f, err := os.Open(serv.path(Id))
+//	    ...
+//	    defer f.Close()
+//	    serv.ResponseBuilder().ServeContent(Id+".pdf", modtime, f)
+//	    return ""
+//	}
+func (this *ResponseBuilder) ServeContent(name string, modtime time.Time, content io.ReadSeeker) *ResponseBuilder {
+	sw := &statusWriter{ResponseWriter: this.writer()}
+	http.ServeContent(sw, this.ctx.request, name, modtime, content)
+	this.ctx.overide = true
+	this.ctx.dataHasBeenWritten = true
+	this.ctx.responseCode = sw.code
+	return this
+}
+
+//Returns a writer that streams to the client, flushing after every write so that the response is sent chunked
+//as it is produced. The status and headers are sent on the first write. Data returned by the endpoint method
+//is discarded.
+//
+//	func (serv ReportService) Export(From string) string {
+//	    w := serv.ResponseBuilder().SetContentType("text/csv").Stream()
+//	    for _, row := range serv.rows(From) {
+//	        fmt.Fprintln(w, row)
+//	    }
+//	    return ""
+//	}
+func (this *ResponseBuilder) Stream() io.Writer {
+	this.ctx.overide = true
+	return &streamWriter{rb: this}
+}
+
+type streamWriter struct {
+	rb *ResponseBuilder
+}
+
+func (this *streamWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	this.rb.Write(data)
+	if f, ok := this.rb.writer().(http.Flusher); ok {
+		f.Flush()
+	}
+	return len(data), nil
+}
+
+//Sends the io.Reader returned by an endpoint with output:"io.Reader". Content that can seek is served with
+//ServeContent, anything else is streamed. Readers that are also io.Closers are closed afterwards.
+//Unless set with SetContentType, the content type is the one the service produces, if that is not the JSON default,
+//or else sniffed from seekable content and application/octet-stream otherwise.
+func writeOutputStream(context *Context, out interface{}) {
+	rb := &ResponseBuilder{ctx: context}
+	if c, ok := out.(io.Closer); ok {
+		defer c.Close()
+	}
+	if !context.responseMimeSet && context.producesMime != "" && context.producesMime != Application_Json {
+		rb.SetContentType(context.producesMime)
+	}
+
+	switch r := out.(type) {
+	case nil:
+		rb.SetResponseCode(http.StatusNoContent).WriteAndOveride(nil)
+	case io.ReadSeeker:
+		rb.ServeContent("", time.Time{}, r)
+	case io.Reader:
+		if !context.responseMimeSet {
+			rb.SetContentType(Application_OctetStream)
+		}
+		io.Copy(rb.Stream(), r)
+		if !context.dataHasBeenWritten { //Nothing to read
+			rb.WriteAndOveride(nil)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type StreamService struct {
+	RestService `root:"/stream-service/"`
+
+	upload   EndPoint `method:"POST" path:"/upload/{Name:string}" postdata:"io.Reader"`
+	scan     EndPoint `method:"POST" path:"/scan/" postdata:"multipart.Reader"`
+	document EndPoint `method:"GET" path:"/document/" output:"io.Reader"`
+	pipe     EndPoint `method:"GET" path:"/pipe/" output:"io.Reader"`
+	export   EndPoint `method:"GET" path:"/export/{Rows:int}" output:"string"`
+}
+
+var streamUploads = make(map[string]string)
+
+func (serv StreamService) Upload(body io.Reader, Name string) {
+	data, _ := ioutil.ReadAll(body)
+	streamUploads[Name] = string(data)
+}
+
+func (serv StreamService) Scan(parts *multipart.Reader) {
+	for {
+		p, err := parts.NextPart()
+		if err != nil {
+			return
+		}
+		data, _ := ioutil.ReadAll(p)
+		streamUploads[p.FormName()] = p.FileName() + ":" + string(data)
+	}
+}
+
+func (serv StreamService) Document() io.Reader {
+	return strings.NewReader("0123456789")
+}
+
+func (serv StreamService) Pipe() io.Reader {
+	return io.MultiReader(strings.NewReader("abc"), strings.NewReader("def"))
+}
+
+func (serv StreamService) Export(Rows int) string {
+	w := serv.ResponseBuilder().SetContentType("text/csv").Stream()
+	for i := 0; i < Rows; i++ {
+		fmt.Fprintf(w, "row,%d\n", i)
+	}
+	return "ignored"
+}
+
+func TestStreaming(t *testing.T) {
+	RegisterServiceOnPath(MUX_ROOT, new(StreamService))
+	root := RootPath + "stream-service/"
+
+	res, err := http.Post(root+"upload/raw", Application_OctetStream, strings.NewReader("raw bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 202, "io.Reader postdata", t)
+	AssertEqual(streamUploads["raw"], "raw bytes", "io.Reader postdata body", t)
+
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	fw, _ := mw.CreateFormFile("declaration", "cusdec.pdf")
+	fw.Write([]byte("%PDF"))
+	mw.WriteField("note", "urgent")
+	mw.Close()
+	res, err = http.Post(root+"scan/", mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 202, "Multipart postdata", t)
+	AssertEqual(streamUploads["declaration"], "cusdec.pdf:%PDF", "Multipart file part", t)
+	AssertEqual(streamUploads["note"], ":urgent", "Multipart field part", t)
+
+	res, err = http.Post(root+"scan/", Application_Json, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 400, "Multipart postdata required", t)
+
+	res, err = http.Get(root + "document/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 200, "Seekable output", t)
+	AssertEqual(string(body), "0123456789", "Seekable output body", t)
+	AssertEqual(res.ContentLength, int64(10), "Seekable output Content-Length", t)
+	AssertEqual(res.Header.Get("Accept-Ranges"), "bytes", "Seekable output Accept-Ranges", t)
+
+	req, _ := http.NewRequest("GET", root+"document/", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 206, "Range request", t)
+	AssertEqual(string(body), "234", "Range request body", t)
+	AssertEqual(res.Header.Get("Content-Range"), "bytes 2-4/10", "Range request Content-Range", t)
+
+	req.Header.Set("Range", "bytes=20-")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 416, "Unsatisfiable range", t)
+
+	res, err = http.Get(root + "pipe/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	AssertEqual(string(body), "abcdef", "Streamed output body", t)
+	AssertEqual(res.Header.Get("Content-Type"), Application_OctetStream, "Streamed output content type", t)
+
+	res, err = http.Get(root + "export/20000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 200, "Stream writer", t)
+	AssertEqual(res.ContentLength, int64(-1), "Stream writer is chunked", t)
+	AssertEqual(res.Header.Get("Content-Type"), "text/csv", "Stream writer content type", t)
+	AssertEqual(strings.Count(string(body), "\n"), 20000, "Stream writer rows", t)
+	AssertEqual(strings.HasSuffix(string(body), "row,19999\n"), true, "Stream writer ignores returned data", t)
+}
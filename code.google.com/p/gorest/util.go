@@ -58,6 +58,9 @@ func InterfaceToBytes(i interface{}, mime string) ([]byte, error) {
 		return []byte(strconv.FormatInt(v.Int(), 10)), nil
 	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
 		m := GetMarshallerByMime(mime)
+		if m == nil {
+			return nil, errors.New("No Marshaller registered for " + mime)
+		}
 		return m.Marshal(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		return []byte(strconv.FormatUint(v.Uint(), 10)), nil
@@ -94,6 +97,9 @@ func BytesToInterface(buf *bytes.Buffer, i interface{}, mime string) error {
 		break
 	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
 		m := GetMarshallerByMime(mime)
+		if m == nil {
+			return errors.New("No Marshaller registered for " + mime)
+		}
 		return m.Unmarshal(buf.Bytes(), i)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 
@@ -0,0 +1,398 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Describes one value that failed validation. Field is the path to the value in the postdata,
+//e.g. "Address.City" or "Items[2].Qty", or the name of a path/query parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+//All the failures found in a request. This is what gets sent to the client, as JSON, with a 400 response.
+type ValidationErrors []FieldError
+
+func (this ValidationErrors) Error() string {
+	msgs := make([]string, len(this))
+	for i, e := range this {
+		msgs[i] = e.Field + ": " + e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//Signiture of custom validators. The param is whatever follows "=" in the rule, e.g. "CUSDEC" in `validate:"docref=CUSDEC"`.
+//Return nil when the value is valid.
+type ValidatorFunc func(value interface{}, param string) error
+
+var (
+	validators   = make(map[string]ValidatorFunc)
+	validatorsMu sync.RWMutex
+)
+
+//Registers a custom validation rule, which can then be used by name in `validate` tags.
+//Register validators before registering the services that use them.
+//
+//	gorest.RegisterValidator("even", func(v interface{}, param string) error {
+//	    if v.(int)%2 != 0 {
+//	        return errors.New("must be even")
+//	    }
+//	    return nil
+//	})
+//
+//	type Order struct {
+//	    Qty int `validate:"required,even"`
+//	}
+func RegisterValidator(name string, f ValidatorFunc) {
+	if isBuiltinRule(name) {
+		log.Panic("The validation rule [" + name + "] is built in and can not be replaced.")
+	}
+	validatorsMu.Lock()
+	validators[name] = f
+	validatorsMu.Unlock()
+}
+
+func getValidator(name string) ValidatorFunc {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+	return validators[name]
+}
+
+type rule struct {
+	name  string
+	param string
+	re    *regexp.Regexp
+	enum  []string
+	bound float64
+}
+
+func isBuiltinRule(name string) bool {
+	switch name {
+	case "required", "min", "max", "len", "regex", "enum":
+		return true
+	}
+	return false
+}
+
+//Parses a list of rules such as "required,min=1,max=10,enum=a|b|c".
+//A "regex" rule must come last, since the expression takes the rest of the tag, commas and all.
+func parseRules(tag string, where string) []rule {
+	rules := make([]rule, 0)
+	for tag != "" {
+		part := tag
+		if strings.HasPrefix(tag, "regex=") {
+			tag = ""
+		} else if i := strings.Index(tag, ","); i != -1 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			tag = ""
+		}
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r := rule{name: part}
+		if i := strings.Index(part, "="); i != -1 {
+			r.name, r.param = part[:i], part[i+1:]
+		}
+
+		var err error
+		switch r.name {
+		case "required":
+		case "min", "max", "len":
+			r.bound, err = strconv.ParseFloat(r.param, 64)
+		case "regex":
+			r.re, err = regexp.Compile(r.param)
+		case "enum":
+			r.enum = strings.Split(r.param, "|")
+		default:
+			if getValidator(r.name) == nil {
+				err = fmt.Errorf("unknown rule %q, register it with RegisterValidator", r.name)
+			}
+		}
+		if err != nil {
+			log.Panic("Invalid validation rule [" + part + "] on " + where + ": " + err.Error())
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+var paramRulesPattern = regexp.MustCompile(`(\w+)\((.*?)\)(?:\s+|$)`)
+
+//Parses the `validate` tag of an EndPoint, which lists rules per path or query parameter:
+//
+//	getUser EndPoint `method:"GET" path:"/users/{Id:int}?{name:string}" output:"User" validate:"Id(min=1) name(required,len=3)"`
+func parseParamRules(ep *endPointStruct, tag string) {
+	ep.paramRules = make(map[string][]rule)
+	for _, m := range paramRulesPattern.FindAllStringSubmatch(tag, -1) {
+		name := m[1]
+		known := false
+		for _, par := range ep.params {
+			known = known || par.name == name
+		}
+		for _, par := range ep.queryParams {
+			known = known || par.name == name
+		}
+		if !known {
+			log.Panic("Validation rules given for unknown parameter [" + name + "] in REST path: " + ep.signiture)
+		}
+		ep.paramRules[name] = parseRules(m[2], "parameter "+name+" of "+ep.signiture)
+	}
+}
+
+type fieldRules struct {
+	index []int
+	name  string
+	rules []rule
+	//Set for an embedded struct of an unexported type. Its value may not be used, only walked into
+	//for the exported fields it promotes.
+	walkOnly bool
+}
+
+var (
+	structRules   = make(map[reflect.Type][]fieldRules)
+	structRulesMu sync.Mutex
+)
+
+//Returns the rules declared on the fields of struct type t, parsing them the first time round.
+func rulesOf(t reflect.Type) []fieldRules {
+	structRulesMu.Lock()
+	defer structRulesMu.Unlock()
+
+	if rules, found := structRules[t]; found {
+		return rules
+	}
+	rules := make([]fieldRules, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fr := fieldRules{index: f.Index, name: f.Name}
+		if f.PkgPath != "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if !f.Anonymous || ft.Kind() != reflect.Struct {
+				continue
+			}
+			fr.walkOnly = true
+		} else if tag := f.Tag.Get("validate"); tag != "" {
+			fr.rules = parseRules(tag, t.Name()+"."+f.Name)
+		}
+		rules = append(rules, fr)
+	}
+	structRules[t] = rules
+	return rules
+}
+
+//Parses the rules of t and of every struct type reachable from it, so that bad tags are reported
+//when the service is registered rather than on the first request.
+func checkRules(t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for _, fr := range rulesOf(t) {
+		checkRules(t.FieldByIndex(fr.index).Type, seen)
+	}
+}
+
+//Validates v, descending into nested structs, slices and maps, and returns every failure found.
+func validateValue(v reflect.Value, path string) ValidationErrors {
+	errs := make(ValidationErrors, 0)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return errs
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, fr := range rulesOf(v.Type()) {
+			fv := v.FieldByIndex(fr.index)
+			fpath := fr.name
+			if path != "" {
+				fpath = path + "." + fr.name
+			}
+			if !fr.walkOnly {
+				errs = append(errs, applyRules(fr.rules, fv, fpath, !isZero(fv))...)
+			}
+			errs = append(errs, validateValue(fv, fpath)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateValue(v.Index(i), path+"["+strconv.Itoa(i)+"]")...)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			errs = append(errs, validateValue(v.MapIndex(k), path+"["+fmt.Sprint(k.Interface())+"]")...)
+		}
+	}
+	return errs
+}
+
+//Applies rules to v. Only "required" is checked on values that were not given, so that optional
+//fields and query parameters can be left out.
+func applyRules(rules []rule, v reflect.Value, field string, present bool) ValidationErrors {
+	errs := make(ValidationErrors, 0)
+	for _, r := range rules {
+		if r.name == "required" {
+			if !present {
+				errs = append(errs, FieldError{field, r.name, "is required"})
+			}
+			continue
+		}
+		if !present {
+			continue
+		}
+		if msg := checkRule(r, v); msg != "" {
+			errs = append(errs, FieldError{field, r.name, msg})
+		}
+	}
+	return errs
+}
+
+func checkRule(r rule, v reflect.Value) string {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch r.name {
+	case "min", "max", "len":
+		n, isLength := measure(v)
+		what := "must be"
+		if isLength {
+			what = "length must be"
+		}
+		switch {
+		case r.name == "min" && n < r.bound:
+			return what + " at least " + r.param
+		case r.name == "max" && n > r.bound:
+			return what + " at most " + r.param
+		case r.name == "len" && n != r.bound:
+			return "length must be " + r.param
+		}
+	case "regex":
+		if !r.re.MatchString(fmt.Sprint(v.Interface())) {
+			return "must match " + r.param
+		}
+	case "enum":
+		s := fmt.Sprint(v.Interface())
+		for _, e := range r.enum {
+			if s == e {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(r.enum, ", ")
+	default:
+		if err := getValidator(r.name)(v.Interface(), r.param); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+//Returns the number that min and max compare against: the value of numbers and the length of everything else.
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+//Reports whether v was left out: the zero value, or an empty slice or map. It never calls Interface,
+//so it is safe on values reached through unexported fields.
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.IsNil() || v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZero(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+//Makes the 400 response sent for a request that failed validation. The body lists every failure:
+//
+//	{"errors":[{"field":"Qty","rule":"min","message":"must be at least 1"}]}
+func validationFailed(context *Context, errs ValidationErrors) restStatus {
+	body, _ := json.Marshal(struct {
+		Errors ValidationErrors `json:"errors"`
+	}{errs})
+	context.writer.Header().Set("Content-Type", Application_Json)
+	return restStatus{http.StatusBadRequest, string(body)}
+}
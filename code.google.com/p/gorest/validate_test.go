@@ -0,0 +1,203 @@
+//Copyright 2011 Siyabonga Dlamini (siyabonga.dlamini@gmail.com). All rights reserved.
+//
+//Redistribution and use in source and binary forms, with or without
+//modification, are permitted provided that the following conditions
+//are met:
+//
+//  1. Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer
+//     in the documentation and/or other materials provided with the
+//     distribution.
+//
+//THIS SOFTWARE IS PROVIDED BY THE AUTHOR ``AS IS'' AND ANY EXPRESS OR
+//IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES
+//OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+//IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+//SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+//PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS;
+//OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY,
+//WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+//OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF
+//ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gorest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Order struct {
+	Ref   string  `validate:"required,regex=^ORD-[0-9]{4}$"`
+	Qty   int     `validate:"min=1,max=10"`
+	State string  `validate:"enum=new|paid"`
+	Code  string  `validate:"len=3,upper"`
+	Items []Item  `validate:"max=2"`
+	Note  *string `validate:"max=5"`
+}
+
+type Item struct {
+	Name string `validate:"required"`
+}
+
+type ValidationService struct {
+	RestService `root:"/validation-service/"`
+
+	addOrder  EndPoint `method:"POST" path:"/order/" postdata:"Order"`
+	findOrder EndPoint `method:"GET" path:"/order/{Id:int}?{state:string}" output:"string" validate:"Id(min=1) state(required,enum=new|paid)"`
+}
+
+func (serv ValidationService) AddOrder(o Order) {
+}
+
+func (serv ValidationService) FindOrder(Id int, state string) string {
+	return "found"
+}
+
+func TestValidationService(t *testing.T) {
+	RegisterValidator("upper", func(v interface{}, param string) error {
+		if s := v.(string); s != strings.ToUpper(s) {
+			return errors.New("must be upper case")
+		}
+		return nil
+	})
+	RegisterServiceOnPath(MUX_ROOT, new(ValidationService))
+
+	root := RootPath + "validation-service/order/"
+	body := `{"Ref":"ORD-1234","Qty":2,"State":"new","Code":"ABC","Items":[{"Name":"a"}]}`
+	res, err := http.Post(root, Application_Json, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 202, "Valid postdata", t)
+
+	body = `{"Ref":"1234","Qty":11,"State":"gone","Code":"abcd","Items":[{"Name":"a"},{},{"Name":"c"}],"Note":"too long"}`
+	errs := postInvalid(t, root, body)
+	expecting := []string{"Ref/regex", "Qty/max", "State/enum", "Code/len", "Code/upper", "Items/max", "Items[1].Name/required", "Note/max"}
+	AssertEqual(len(errs), len(expecting), "Validation failure count", t)
+	for i := 0; i < len(errs) && i < len(expecting); i++ {
+		AssertEqual(errs[i].Field+"/"+errs[i].Rule, expecting[i], "Validation failure", t)
+	}
+
+	errs = postInvalid(t, root, `{"Qty":1}`)
+	AssertEqual(len(errs), 1, "Only required checked on missing fields", t)
+	if len(errs) == 1 {
+		AssertEqual(errs[0], FieldError{"Ref", "required", "is required"}, "Required field", t)
+	}
+
+	res, err = http.Get(root + "5?state=paid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 200, "Valid parameters", t)
+
+	res, err = http.Get(root + "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs = decodeValidationErrors(t, res)
+	AssertEqual(res.StatusCode, 400, "Invalid parameters", t)
+	AssertEqual(len(errs), 2, "Parameter failure count", t)
+	if len(errs) == 2 {
+		AssertEqual(errs[0].Field+"/"+errs[0].Rule, "Id/min", "Path parameter", t)
+		AssertEqual(errs[1].Field+"/"+errs[1].Rule, "state/required", "Query parameter", t)
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	rules := parseRules("required,min=1,regex=^a,b$", "test")
+	AssertEqual(len(rules), 3, "Rule count", t)
+	AssertEqual(rules[2].param, "^a,b$", "Regex takes the rest of the tag", t)
+
+	for _, tag := range []string{"min=x", "regex=(", "nosuchrule"} {
+		func() {
+			defer func() {
+				AssertEqual(recover() != nil, true, "Bad rule panics: "+tag, t)
+			}()
+			parseRules(tag, "test")
+		}()
+	}
+}
+
+//Postdata embedding structs of unexported types, whose promoted fields are validated, next to
+//unexported fields that are not.
+type Shipment struct {
+	audit
+	*port
+	Ref    string `validate:"required"`
+	secret map[string]int
+	count  int
+}
+
+type audit struct {
+	CreatedBy string `validate:"required"`
+	note      string
+}
+
+type port struct {
+	Port string `validate:"enum=CMB|HMB"`
+}
+
+type EmbeddedValidationService struct {
+	RestService `root:"/validation-embedded/"`
+
+	addShipment EndPoint `method:"POST" path:"/shipment/" postdata:"Shipment"`
+}
+
+func (serv EmbeddedValidationService) AddShipment(s Shipment) {
+}
+
+func TestValidationUnexportedFields(t *testing.T) {
+	RegisterServiceOnPath(MUX_ROOT, new(EmbeddedValidationService))
+	root := RootPath + "validation-embedded/shipment/"
+
+	res, err := http.Post(root, Application_Json, strings.NewReader(`{"Ref":"S1","CreatedBy":"clerk"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	AssertEqual(res.StatusCode, 202, "Valid postdata with embedded unexported structs", t)
+
+	errs := postInvalid(t, root, `{"Ref":"S1"}`)
+	AssertEqual(len(errs), 1, "Promoted field failure count", t)
+	if len(errs) == 1 {
+		AssertEqual(errs[0], FieldError{"audit.CreatedBy", "required", "is required"}, "Promoted field", t)
+	}
+
+	s := Shipment{audit: audit{note: "x"}, port: &port{Port: "LAX"}, Ref: "S1", secret: map[string]int{"a": 1}, count: 2}
+	errs = validateValue(reflect.ValueOf(s), "")
+	AssertEqual(len(errs), 2, "Failures behind an embedded pointer", t)
+	if len(errs) == 2 {
+		AssertEqual(errs[0].Field+"/"+errs[0].Rule, "audit.CreatedBy/required", "Embedded struct", t)
+		AssertEqual(errs[1].Field+"/"+errs[1].Rule, "port.Port/enum", "Embedded pointer", t)
+	}
+}
+
+func postInvalid(t *testing.T, url string, body string) []FieldError {
+	res, err := http.Post(url, Application_Json, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEqual(res.StatusCode, 400, "Invalid postdata", t)
+	return decodeValidationErrors(t, res)
+}
+
+func decodeValidationErrors(t *testing.T, res *http.Response) []FieldError {
+	defer res.Body.Close()
+	AssertEqual(res.Header.Get("Content-Type"), Application_Json, "Validation failure content type", t)
+	v := new(struct{ Errors []FieldError })
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return v.Errors
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2011 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The binary protocol is described at
+// https://github.com/memcached/memcached/wiki/BinaryProtocolRevamped
+
+const (
+	binReqMagic  = 0x80
+	binResMagic  = 0x81
+	binHeaderLen = 24
+)
+
+const (
+	opGet       = 0x00
+	opSet       = 0x01
+	opAdd       = 0x02
+	opReplace   = 0x03
+	opDelete    = 0x04
+	opIncrement = 0x05
+	opDecrement = 0x06
+	opFlush     = 0x08
+	opNoop      = 0x0a
+	opGetKQ     = 0x0d
+	opSetQ      = 0x11
+	opDeleteQ   = 0x14
+	opTouch     = 0x1c
+	opSASLAuth  = 0x21
+)
+
+const (
+	statusOK          = 0x00
+	statusKeyNotFound = 0x01
+	statusKeyExists   = 0x02
+	statusNotStored   = 0x05
+	statusAuthError   = 0x20
+)
+
+// binResponse is a response packet of the binary protocol.
+type binResponse struct {
+	op     byte
+	status uint16
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+func writeBinRequest(w io.Writer, op byte, key string, extras, value []byte, cas uint64, opaque uint32) error {
+	var h [binHeaderLen]byte
+	h[0] = binReqMagic
+	h[1] = op
+	binary.BigEndian.PutUint16(h[2:], uint16(len(key)))
+	h[4] = byte(len(extras))
+	binary.BigEndian.PutUint32(h[8:], uint32(len(extras)+len(key)+len(value)))
+	binary.BigEndian.PutUint32(h[12:], opaque)
+	binary.BigEndian.PutUint64(h[16:], cas)
+	for _, b := range [][]byte{h[:], extras, []byte(key), value} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBinResponse(r *bufio.Reader) (*binResponse, error) {
+	var h [binHeaderLen]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, err
+	}
+	if h[0] != binResMagic {
+		return nil, fmt.Errorf("memcache: corrupt binary response: bad magic 0x%x", h[0])
+	}
+	keyLen := int(binary.BigEndian.Uint16(h[2:]))
+	extLen := int(h[4])
+	bodyLen := int(binary.BigEndian.Uint32(h[8:]))
+	if extLen+keyLen > bodyLen {
+		return nil, fmt.Errorf("memcache: corrupt binary response: body too short")
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &binResponse{
+		op:     h[1],
+		status: binary.BigEndian.Uint16(h[6:]),
+		opaque: binary.BigEndian.Uint32(h[12:]),
+		cas:    binary.BigEndian.Uint64(h[16:]),
+		extras: body[:extLen],
+		key:    body[extLen : extLen+keyLen],
+		value:  body[extLen+keyLen:],
+	}, nil
+}
+
+// statusError maps the status of a response to this package's errors.
+func (res *binResponse) statusError() error {
+	switch res.status {
+	case statusOK:
+		return nil
+	case statusKeyNotFound:
+		return ErrCacheMiss
+	case statusKeyExists:
+		return ErrCASConflict
+	case statusNotStored:
+		return ErrNotStored
+	case statusAuthError:
+		return ErrAuthFailed
+	}
+	return fmt.Errorf("memcache: server error 0x%x: %s", res.status, res.value)
+}
+
+// binRoundTrip sends a single request and reads its response.
+func binRoundTrip(rw *bufio.ReadWriter, op byte, key string, extras, value []byte, cas uint64) (*binResponse, error) {
+	if err := writeBinRequest(rw, op, key, extras, value, cas, 0); err != nil {
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readBinResponse(rw.Reader)
+}
+
+// binQuiet sends quiet requests followed by a no-op, then reads
+// responses up to the no-op's. A quiet request only gets a response
+// when it fails or, for GetKQ, when the key is found; fn is called
+// with each of them. The first error fn returns is returned once all
+// responses have been read.
+func binQuiet(rw *bufio.ReadWriter, n int, write func(i int) error, fn func(*binResponse) error) error {
+	for i := 0; i < n; i++ {
+		if err := write(i); err != nil {
+			return err
+		}
+	}
+	if err := writeBinRequest(rw, opNoop, "", nil, nil, 0, uint32(n)); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	var ferr error
+	for {
+		res, err := readBinResponse(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if res.op == opNoop {
+			return ferr
+		}
+		if int(res.opaque) >= n {
+			return fmt.Errorf("memcache: unexpected binary response opaque %d", res.opaque)
+		}
+		if err := fn(res); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+}
+
+func binGet(rw *bufio.ReadWriter, keys []string, cb func(*Item)) error {
+	return binQuiet(rw, len(keys), func(i int) error {
+		return writeBinRequest(rw, opGetKQ, keys[i], nil, nil, 0, uint32(i))
+	}, func(res *binResponse) error {
+		switch {
+		case res.status == statusKeyNotFound:
+			return nil
+		case res.status != statusOK:
+			return res.statusError()
+		case len(res.extras) < 4:
+			return fmt.Errorf("memcache: corrupt get response for %q", res.key)
+		}
+		cb(&Item{
+			Key:   string(res.key),
+			Value: res.value,
+			Flags: binary.BigEndian.Uint32(res.extras),
+			casid: res.cas,
+		})
+		return nil
+	})
+}
+
+func storeExtras(item *Item) []byte {
+	extras := make([]byte, 8)
+	binary.BigEndian.PutUint32(extras, item.Flags)
+	binary.BigEndian.PutUint32(extras[4:], uint32(item.Expiration))
+	return extras
+}
+
+func binStore(rw *bufio.ReadWriter, verb string, item *Item) error {
+	var op byte
+	var cas uint64
+	switch verb {
+	case "set":
+		op = opSet
+	case "add":
+		op = opAdd
+	case "replace":
+		op = opReplace
+	case "cas":
+		op, cas = opSet, item.casid
+	default:
+		return fmt.Errorf("memcache: unknown store verb %q", verb)
+	}
+	res, err := binRoundTrip(rw, op, item.Key, storeExtras(item), item.Value, cas)
+	if err != nil {
+		return err
+	}
+	err = res.statusError()
+	// match the errors of the ASCII protocol
+	switch {
+	case verb == "add" && err == ErrCASConflict, verb == "replace" && err == ErrCacheMiss:
+		return ErrNotStored
+	}
+	return err
+}
+
+func binSetMulti(rw *bufio.ReadWriter, items []*Item) (MultiError, error) {
+	errs := make(MultiError)
+	err := binQuiet(rw, len(items), func(i int) error {
+		return writeBinRequest(rw, opSetQ, items[i].Key, storeExtras(items[i]), items[i].Value, 0, uint32(i))
+	}, func(res *binResponse) error {
+		if err := res.statusError(); err != nil {
+			errs[items[res.opaque].Key] = err
+		}
+		return nil
+	})
+	return errs, err
+}
+
+func binDelete(rw *bufio.ReadWriter, key string) error {
+	res, err := binRoundTrip(rw, opDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return res.statusError()
+}
+
+func binDeleteMulti(rw *bufio.ReadWriter, keys []string) (MultiError, error) {
+	errs := make(MultiError)
+	err := binQuiet(rw, len(keys), func(i int) error {
+		return writeBinRequest(rw, opDeleteQ, keys[i], nil, nil, 0, uint32(i))
+	}, func(res *binResponse) error {
+		if err := res.statusError(); err != nil {
+			errs[keys[res.opaque]] = err
+		}
+		return nil
+	})
+	return errs, err
+}
+
+func binTouch(rw *bufio.ReadWriter, key string, expiration int32) error {
+	extras := make([]byte, 4)
+	binary.BigEndian.PutUint32(extras, uint32(expiration))
+	res, err := binRoundTrip(rw, opTouch, key, extras, nil, 0)
+	if err != nil {
+		return err
+	}
+	return res.statusError()
+}
+
+func binIncrDecr(rw *bufio.ReadWriter, verb, key string, delta uint64) (uint64, error) {
+	op := byte(opIncrement)
+	if verb == "decr" {
+		op = opDecrement
+	}
+	extras := make([]byte, 20)
+	binary.BigEndian.PutUint64(extras, delta)
+	// an expiration of all ones fails on missing keys instead of
+	// creating them
+	binary.BigEndian.PutUint32(extras[16:], 0xffffffff)
+	res, err := binRoundTrip(rw, op, key, extras, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	if err := res.statusError(); err != nil {
+		return 0, err
+	}
+	if len(res.value) != 8 {
+		return 0, fmt.Errorf("memcache: corrupt %s response", verb)
+	}
+	return binary.BigEndian.Uint64(res.value), nil
+}
+
+func binFlush(rw *bufio.ReadWriter) error {
+	res, err := binRoundTrip(rw, opFlush, "", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return res.statusError()
+}
+
+// saslAuth authenticates a new connection with the PLAIN mechanism.
+func saslAuth(rw *bufio.ReadWriter, username, password string) error {
+	res, err := binRoundTrip(rw, opSASLAuth, "PLAIN", nil, []byte("\x00"+username+"\x00"+password), 0)
+	if err != nil {
+		return err
+	}
+	return res.statusError()
+}
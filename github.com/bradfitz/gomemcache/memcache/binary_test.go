@@ -0,0 +1,314 @@
+/*
+Copyright 2011 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBinServer is a tiny memcached speaking the binary protocol. If
+// password is not empty, connections must authenticate as user "u".
+// Keys starting with "fail" cannot be stored.
+func fakeBinServer(t *testing.T, password string) (addr string, stop func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lk sync.Mutex
+	items := make(map[string][]byte)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(nc net.Conn) {
+				defer nc.Close()
+				r := bufio.NewReader(nc)
+				w := bufio.NewWriter(nc)
+				authed := password == ""
+				reply := func(op byte, status uint16, opaque uint32, extras []byte, key string, value []byte) {
+					var h [binHeaderLen]byte
+					h[0] = binResMagic
+					h[1] = op
+					binary.BigEndian.PutUint16(h[2:], uint16(len(key)))
+					h[4] = byte(len(extras))
+					binary.BigEndian.PutUint16(h[6:], status)
+					binary.BigEndian.PutUint32(h[8:], uint32(len(extras)+len(key)+len(value)))
+					binary.BigEndian.PutUint32(h[12:], opaque)
+					w.Write(h[:])
+					w.Write(extras)
+					w.WriteString(key)
+					w.Write(value)
+				}
+				for {
+					var h [binHeaderLen]byte
+					if _, err := io.ReadFull(r, h[:]); err != nil {
+						return
+					}
+					op := h[1]
+					keyLen := int(binary.BigEndian.Uint16(h[2:]))
+					extLen := int(h[4])
+					opaque := binary.BigEndian.Uint32(h[12:])
+					body := make([]byte, binary.BigEndian.Uint32(h[8:]))
+					if _, err := io.ReadFull(r, body); err != nil {
+						return
+					}
+					key := string(body[extLen : extLen+keyLen])
+					value := body[extLen+keyLen:]
+
+					if !authed && op != opSASLAuth {
+						reply(op, statusAuthError, opaque, nil, "", nil)
+						w.Flush()
+						continue
+					}
+					lk.Lock()
+					switch op {
+					case opSASLAuth:
+						if string(value) == "\x00u\x00"+password {
+							authed = true
+							reply(op, statusOK, opaque, nil, "", nil)
+						} else {
+							reply(op, statusAuthError, opaque, nil, "", nil)
+						}
+					case opSet, opSetQ:
+						status := uint16(statusOK)
+						if len(key) >= 4 && key[:4] == "fail" {
+							status = statusNotStored
+						} else {
+							items[key] = append(body[:4:4], value...)
+						}
+						if op == opSet || status != statusOK {
+							reply(op, status, opaque, nil, "", nil)
+						}
+					case opGetKQ:
+						if v, ok := items[key]; ok {
+							reply(op, statusOK, opaque, v[:4], key, v[4:])
+						}
+					case opDelete, opDeleteQ:
+						_, ok := items[key]
+						delete(items, key)
+						if !ok {
+							reply(op, statusKeyNotFound, opaque, nil, "", nil)
+						} else if op == opDelete {
+							reply(op, statusOK, opaque, nil, "", nil)
+						}
+					case opNoop:
+						reply(op, statusOK, opaque, nil, "", nil)
+					default:
+						reply(op, 0x81, opaque, nil, "", []byte("Unknown command"))
+					}
+					lk.Unlock()
+					if op != opSetQ && op != opGetKQ && op != opDeleteQ {
+						w.Flush()
+					}
+				}
+			}(nc)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func TestBinaryProtocol(t *testing.T) {
+	addr, stop := fakeBinServer(t, "")
+	defer stop()
+	c := New(addr)
+	c.Protocol = Binary
+
+	if err := c.Set(&Item{Key: "foo", Value: []byte("fooval"), Flags: 123}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	it, err := c.Get("foo")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(it.Value) != "fooval" || it.Flags != 123 {
+		t.Errorf("Get = %q with flags %d; want %q with flags 123", it.Value, it.Flags, "fooval")
+	}
+	if _, err := c.Get("missing"); err != ErrCacheMiss {
+		t.Errorf("Get of a missing key = %v; want ErrCacheMiss", err)
+	}
+	if err := c.Delete("foo"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := c.Delete("foo"); err != ErrCacheMiss {
+		t.Errorf("second Delete = %v; want ErrCacheMiss", err)
+	}
+}
+
+func TestBinaryMulti(t *testing.T) {
+	addr, stop := fakeBinServer(t, "")
+	defer stop()
+	c := New(addr)
+	c.Protocol = Binary
+
+	err := c.SetMulti([]*Item{
+		{Key: "a", Value: []byte("1")},
+		{Key: "fail-b", Value: []byte("2")},
+		{Key: "c", Value: []byte("3")},
+	})
+	me, ok := err.(MultiError)
+	if !ok || len(me) != 1 || me["fail-b"] != ErrNotStored {
+		t.Fatalf("SetMulti = %v; want a MultiError for fail-b only", err)
+	}
+	m, err := c.GetMulti([]string{"a", "fail-b", "c"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(m) != 2 || string(m["a"].Value) != "1" || string(m["c"].Value) != "3" {
+		t.Errorf("GetMulti = %v; want a and c", m)
+	}
+
+	err = c.DeleteMulti([]string{"a", "missing", "c"})
+	me, ok = err.(MultiError)
+	if !ok || len(me) != 1 || me["missing"] != ErrCacheMiss {
+		t.Fatalf("DeleteMulti = %v; want a MultiError for missing only", err)
+	}
+	if err := c.DeleteMulti([]string{"a"}); err.(MultiError)["a"] != ErrCacheMiss {
+		t.Errorf("DeleteMulti of a deleted key = %v; want ErrCacheMiss", err)
+	}
+}
+
+func TestASCIIMulti(t *testing.T) {
+	addr, stop := fakeServer(t)
+	defer stop()
+	c := New(addr)
+
+	if err := c.SetMulti([]*Item{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}); err != nil {
+		t.Fatalf("SetMulti: %v", err)
+	}
+	m, err := c.GetMulti([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(m) != 2 || string(m["a"].Value) != "1" || string(m["b"].Value) != "2" {
+		t.Errorf("GetMulti = %v; want a and b", m)
+	}
+
+	err = c.DeleteMulti([]string{"a", "missing"})
+	me, ok := err.(MultiError)
+	if !ok || len(me) != 1 || me["missing"] != ErrCacheMiss {
+		t.Fatalf("DeleteMulti = %v; want a MultiError for missing only", err)
+	}
+}
+
+func TestSASLAuth(t *testing.T) {
+	addr, stop := fakeBinServer(t, "secret")
+	defer stop()
+
+	c := New(addr)
+	c.Username, c.Password = "u", "secret"
+	if err := c.Set(&Item{Key: "foo", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set with good credentials: %v", err)
+	}
+
+	c = New(addr)
+	c.Username, c.Password = "u", "wrong"
+	if err := c.Set(&Item{Key: "foo", Value: []byte("v")}); err != ErrAuthFailed {
+		t.Errorf("Set with bad credentials = %v; want ErrAuthFailed", err)
+	}
+	st := c.PoolStats()[addr]
+	if st.DialFailures != 1 || st.Open != 0 {
+		t.Errorf("PoolStats after failed auth = %+v; want 1 dial failure and no open connection", st)
+	}
+}
+
+func TestBinaryGetErrors(t *testing.T) {
+	addr, stop := fakeBinServer(t, "secret")
+	defer stop()
+
+	c := New(addr)
+	c.Protocol = Binary
+	if _, err := c.Get("foo"); err != ErrAuthFailed {
+		t.Errorf("Get without credentials = %v; want ErrAuthFailed", err)
+	}
+	if _, err := c.GetMulti([]string{"foo", "bar"}); err != ErrAuthFailed {
+		t.Errorf("GetMulti without credentials = %v; want ErrAuthFailed", err)
+	}
+
+	c.Username, c.Password = "u", "secret"
+	if _, err := c.Get("foo"); err != ErrCacheMiss {
+		t.Errorf("Get of a missing key = %v; want ErrCacheMiss", err)
+	}
+}
+
+func TestKetamaProbeSASL(t *testing.T) {
+	addr, stop := fakeBinServer(t, "secret")
+	defer stop()
+
+	k := &Ketama{FailureLimit: 1, RetryInterval: 10 * time.Millisecond}
+	k.SetServers(addr)
+	c := NewFromSelector(k)
+	c.Username, c.Password = "u", "secret"
+
+	a, _ := k.PickServer("foo")
+	c.reportHealth(a, io.EOF)
+	if _, err := k.PickServer("foo"); err != ErrNoServers {
+		t.Fatalf("PickServer after the failure = %v; want ErrNoServers", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := k.PickServer("foo"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.Set(&Item{Key: "foo", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set after the probe re-admitted the server: %v", err)
+	}
+
+	c.Password = "wrong"
+	if err := c.probe(a); err != ErrAuthFailed {
+		t.Errorf("probe with bad credentials = %v; want ErrAuthFailed", err)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	addr, stop := fakeServer(t)
+	defer stop()
+	c := New(addr)
+
+	for i := 0; i < 3; i++ {
+		if err := c.Set(&Item{Key: "foo", Value: []byte("v")}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	st := c.PoolStats()[addr]
+	if st.Dials != 1 || st.Open != 1 || st.Idle != 1 {
+		t.Errorf("PoolStats = %+v; want one dialed, open and idle connection", st)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := l.Addr().String()
+	l.Close()
+	c = New(dead)
+	if err := c.Set(&Item{Key: "foo", Value: []byte("v")}); err == nil {
+		t.Fatal("Set on a closed port succeeded")
+	}
+	st = c.PoolStats()[dead]
+	if st.DialFailures != 1 || st.Open != 0 || st.Dials != 0 {
+		t.Errorf("PoolStats for a closed port = %+v; want a single dial failure", st)
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2011 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureLimit is the number of consecutive failures after
+	// which a Ketama selector ejects a server.
+	DefaultFailureLimit = 3
+
+	// DefaultRetryInterval is how often a Ketama selector probes the
+	// servers it has ejected.
+	DefaultRetryInterval = 10 * time.Second
+)
+
+// HealthTracker is implemented by ServerSelectors that want to know
+// whether the servers they pick are working. A Client reports the
+// outcome of every operation it makes against such a selector's
+// servers.
+type HealthTracker interface {
+	ReportSuccess(addr net.Addr)
+	ReportFailure(addr net.Addr)
+}
+
+// Ketama is a ServerSelector that spreads keys over its servers with
+// consistent hashing, compatible with libketama. Adding or removing a
+// server only moves the keys that hashed onto it.
+//
+// Servers that fail FailureLimit times in a row are ejected: their
+// keys go to the next server on the ring until a background probe
+// finds them reachable again. Its zero value is usable.
+type Ketama struct {
+	// FailureLimit is the number of consecutive failures after which
+	// a server is ejected. If zero, DefaultFailureLimit is used.
+	FailureLimit int
+
+	// RetryInterval is how often ejected servers are probed.
+	// If zero, DefaultRetryInterval is used.
+	RetryInterval time.Duration
+
+	// Probe checks whether an ejected server is reachable again. If
+	// nil, servers are probed the way the Client that reported them
+	// failing talks to them, authenticating first if it does.
+	Probe func(addr net.Addr) error
+
+	lk          sync.RWMutex
+	servers     []*ketamaServer
+	ring        []ketamaPoint
+	checking    bool
+	clientProbe func(net.Addr) error
+}
+
+type ketamaServer struct {
+	addr     net.Addr
+	points   []uint32
+	failures int
+	ejected  bool
+}
+
+type ketamaPoint struct {
+	hash   uint32
+	server *ketamaServer
+}
+
+// SetServers changes the Ketama's set of servers at runtime and is
+// threadsafe. Each server is given equal weight.
+//
+// SetServers returns an error if any of the server names fail to
+// resolve. If any error is returned, no changes are made.
+func (k *Ketama) SetServers(servers ...string) error {
+	weights := make(map[string]int, len(servers))
+	for _, server := range servers {
+		weights[server]++
+	}
+	return k.SetWeightedServers(weights)
+}
+
+// SetWeightedServers is like SetServers, giving each server a share of
+// the keys proportional to its weight.
+func (k *Ketama) SetWeightedServers(weights map[string]int) error {
+	names := make([]string, 0, len(weights))
+	total := 0
+	for name, weight := range weights {
+		if weight <= 0 {
+			return fmt.Errorf("memcache: server %s has a weight of %d", name, weight)
+		}
+		names = append(names, name)
+		total += weight
+	}
+	sort.Strings(names)
+
+	servers := make([]*ketamaServer, len(names))
+	for i, name := range names {
+		addr, err := resolveAddr(name)
+		if err != nil {
+			return err
+		}
+		servers[i] = &ketamaServer{addr: addr}
+
+		// as in libketama: 40 hashes of 4 points each per server
+		// for an even share of the keys
+		share := float64(weights[name]) / float64(total)
+		n := int(math.Floor(share * 40 * float64(len(names))))
+		for j := 0; j < n; j++ {
+			d := md5.Sum([]byte(fmt.Sprintf("%s-%d", name, j)))
+			for h := 0; h < 4; h++ {
+				servers[i].points = append(servers[i].points, ketamaHash(d[h*4:]))
+			}
+		}
+	}
+
+	k.lk.Lock()
+	defer k.lk.Unlock()
+	k.servers = servers
+	k.buildRing()
+	return nil
+}
+
+func ketamaHash(d []byte) uint32 {
+	return uint32(d[3])<<24 | uint32(d[2])<<16 | uint32(d[1])<<8 | uint32(d[0])
+}
+
+// buildRing rebuilds the ring from the servers that are not ejected.
+// k.lk must be held.
+func (k *Ketama) buildRing() {
+	ring := make([]ketamaPoint, 0, len(k.ring))
+	for _, s := range k.servers {
+		if s.ejected {
+			continue
+		}
+		for _, h := range s.points {
+			ring = append(ring, ketamaPoint{h, s})
+		}
+	}
+	sort.Sort(byHash(ring))
+	k.ring = ring
+}
+
+type byHash []ketamaPoint
+
+func (p byHash) Len() int           { return len(p) }
+func (p byHash) Less(i, j int) bool { return p[i].hash < p[j].hash }
+func (p byHash) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (k *Ketama) PickServer(key string) (net.Addr, error) {
+	d := md5.Sum([]byte(key))
+	h := ketamaHash(d[:])
+
+	k.lk.RLock()
+	defer k.lk.RUnlock()
+	if len(k.ring) == 0 {
+		return nil, ErrNoServers
+	}
+	i := sort.Search(len(k.ring), func(i int) bool { return k.ring[i].hash >= h })
+	if i == len(k.ring) {
+		i = 0
+	}
+	return k.ring[i].server.addr, nil
+}
+
+// Each iterates over each server that is not ejected, calling the
+// given function.
+func (k *Ketama) Each(f func(net.Addr) error) error {
+	k.lk.RLock()
+	defer k.lk.RUnlock()
+	for _, s := range k.servers {
+		if s.ejected {
+			continue
+		}
+		if err := f(s.addr); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *Ketama) find(addr net.Addr) *ketamaServer {
+	for _, s := range k.servers {
+		if s.addr.String() == addr.String() {
+			return s
+		}
+	}
+	return nil
+}
+
+// ReportSuccess resets the failure count of the server.
+func (k *Ketama) ReportSuccess(addr net.Addr) {
+	k.lk.Lock()
+	defer k.lk.Unlock()
+	if s := k.find(addr); s != nil {
+		s.failures = 0
+	}
+}
+
+// ReportFailure counts a failure against the server, ejecting it once
+// it reaches the failure limit.
+func (k *Ketama) ReportFailure(addr net.Addr) {
+	k.lk.Lock()
+	defer k.lk.Unlock()
+	s := k.find(addr)
+	if s == nil || s.ejected {
+		return
+	}
+	s.failures++
+	limit := k.FailureLimit
+	if limit == 0 {
+		limit = DefaultFailureLimit
+	}
+	if s.failures < limit {
+		return
+	}
+
+	s.ejected = true
+	k.buildRing()
+	if !k.checking {
+		k.checking = true
+		go k.check()
+	}
+}
+
+// useClientProbe makes the background check probe servers with p,
+// unless Probe is set. The Client hands its own probe over before
+// reporting a failure.
+func (k *Ketama) useClientProbe(p func(net.Addr) error) {
+	k.lk.Lock()
+	k.clientProbe = p
+	k.lk.Unlock()
+}
+
+// check probes the ejected servers every RetryInterval and re-admits
+// those that answer. It returns once no server is ejected.
+func (k *Ketama) check() {
+	interval := k.RetryInterval
+	if interval == 0 {
+		interval = DefaultRetryInterval
+	}
+	for {
+		time.Sleep(interval)
+
+		k.lk.RLock()
+		probe := k.Probe
+		if probe == nil {
+			probe = k.clientProbe
+		}
+		if probe == nil {
+			probe = probeServer
+		}
+		var ejected []*ketamaServer
+		for _, s := range k.servers {
+			if s.ejected {
+				ejected = append(ejected, s)
+			}
+		}
+		k.lk.RUnlock()
+
+		var healthy []*ketamaServer
+		for _, s := range ejected {
+			if probe(s.addr) == nil {
+				healthy = append(healthy, s)
+			}
+		}
+
+		k.lk.Lock()
+		for _, s := range healthy {
+			s.ejected, s.failures = false, 0
+		}
+		if len(healthy) > 0 {
+			k.buildRing()
+		}
+		remaining := false
+		for _, s := range k.servers {
+			remaining = remaining || s.ejected
+		}
+		if !remaining {
+			k.checking = false
+			k.lk.Unlock()
+			return
+		}
+		k.lk.Unlock()
+	}
+}
+
+// probeServer checks that a memcached server answers a version
+// command. It is used when no Client has reported a failure yet.
+func probeServer(addr net.Addr) error {
+	nc, err := net.DialTimeout(addr.Network(), addr.String(), DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(DefaultTimeout))
+	return probeVersion(bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc)))
+}
+
+func probeVersion(rw *bufio.ReadWriter) error {
+	line, err := writeReadLine(rw, "version\r\n")
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(line, []byte("VERSION ")) {
+		return fmt.Errorf("memcache: unexpected response line from version: %q", string(line))
+	}
+	return nil
+}
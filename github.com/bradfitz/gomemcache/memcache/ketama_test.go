@@ -0,0 +1,250 @@
+/*
+Copyright 2011 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func pickAll(t *testing.T, k *Ketama, n int) map[string]string {
+	picks := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		addr, err := k.PickServer(key)
+		if err != nil {
+			t.Fatalf("PickServer(%q): %v", key, err)
+		}
+		picks[key] = addr.String()
+	}
+	return picks
+}
+
+func countByServer(picks map[string]string) map[string]int {
+	counts := make(map[string]int)
+	for _, addr := range picks {
+		counts[addr]++
+	}
+	return counts
+}
+
+func TestKetamaDistribution(t *testing.T) {
+	k := new(Ketama)
+	if _, err := k.PickServer("foo"); err != ErrNoServers {
+		t.Errorf("PickServer on an empty ring = %v; want ErrNoServers", err)
+	}
+
+	if err := k.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"); err != nil {
+		t.Fatal(err)
+	}
+	for addr, n := range countByServer(pickAll(t, k, 30000)) {
+		if n < 7000 || n > 13000 {
+			t.Errorf("%s got %d of 30000 keys; want about 10000", addr, n)
+		}
+	}
+}
+
+func TestKetamaWeights(t *testing.T) {
+	k := new(Ketama)
+	err := k.SetWeightedServers(map[string]int{"127.0.0.1:11211": 3, "127.0.0.1:11212": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := countByServer(pickAll(t, k, 20000))
+	if n := counts["127.0.0.1:11211"]; n < 13000 || n > 17000 {
+		t.Errorf("heavy server got %d of 20000 keys; want about 15000", n)
+	}
+
+	if err := k.SetWeightedServers(map[string]int{"127.0.0.1:11211": 0}); err == nil {
+		t.Error("expected an error for a zero weight")
+	}
+}
+
+func TestKetamaAddServer(t *testing.T) {
+	k := new(Ketama)
+	k.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213")
+	before := pickAll(t, k, 10000)
+	k.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213", "127.0.0.1:11214")
+	after := pickAll(t, k, 10000)
+
+	for key, addr := range after {
+		if addr != before[key] && addr != "127.0.0.1:11214" {
+			t.Fatalf("%s moved from %s to %s; only moves to the new server are expected", key, before[key], addr)
+		}
+	}
+}
+
+func TestKetamaEjection(t *testing.T) {
+	var lk sync.Mutex
+	healthy := false
+	k := &Ketama{
+		FailureLimit:  2,
+		RetryInterval: 10 * time.Millisecond,
+		Probe: func(net.Addr) error {
+			lk.Lock()
+			defer lk.Unlock()
+			if !healthy {
+				return io.EOF
+			}
+			return nil
+		},
+	}
+	k.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213")
+	before := pickAll(t, k, 3000)
+
+	dead, _ := resolveAddr("127.0.0.1:11212")
+	k.ReportFailure(dead)
+	k.ReportSuccess(dead)
+	k.ReportFailure(dead)
+	if got := pickAll(t, k, 3000); countByServer(got)[dead.String()] == 0 {
+		t.Fatal("server ejected before reaching the failure limit in a row")
+	}
+
+	k.ReportFailure(dead)
+	ejected := pickAll(t, k, 3000)
+	for key, addr := range ejected {
+		if addr == dead.String() {
+			t.Fatalf("%s still maps to the ejected server", key)
+		}
+		if before[key] != dead.String() && addr != before[key] {
+			t.Fatalf("%s moved from %s to %s although its server is healthy", key, before[key], addr)
+		}
+	}
+	n := 0
+	k.Each(func(net.Addr) error { n++; return nil })
+	if n != 2 {
+		t.Errorf("Each visited %d servers; want 2", n)
+	}
+
+	lk.Lock()
+	healthy = true
+	lk.Unlock()
+	for i := 0; i < 100; i++ {
+		if countByServer(pickAll(t, k, 3000))[dead.String()] > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	readmitted := pickAll(t, k, 3000)
+	for key, addr := range readmitted {
+		if addr != before[key] {
+			t.Fatalf("%s maps to %s after re-admission; want %s", key, addr, before[key])
+		}
+	}
+}
+
+// fakeServer is a tiny memcached that understands set, get and delete.
+func fakeServer(t *testing.T) (addr string, stop func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lk sync.Mutex
+	items := make(map[string]string)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(nc net.Conn) {
+				defer nc.Close()
+				rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+				for {
+					line, err := rw.ReadString('\n')
+					if err != nil {
+						return
+					}
+					f := strings.Fields(line)
+					switch f[0] {
+					case "set":
+						var size int
+						fmt.Sscan(f[4], &size)
+						buf := make([]byte, size+2)
+						io.ReadFull(rw, buf)
+						lk.Lock()
+						items[f[1]] = string(buf[:size])
+						lk.Unlock()
+						rw.WriteString("STORED\r\n")
+					case "gets":
+						lk.Lock()
+						for _, key := range f[1:] {
+							if v, ok := items[key]; ok {
+								fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(v), v)
+							}
+						}
+						lk.Unlock()
+						rw.WriteString("END\r\n")
+					case "delete":
+						lk.Lock()
+						_, ok := items[f[1]]
+						delete(items, f[1])
+						lk.Unlock()
+						if ok {
+							rw.WriteString("DELETED\r\n")
+						} else {
+							rw.WriteString("NOT_FOUND\r\n")
+						}
+					}
+					rw.Flush()
+				}
+			}(nc)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func TestClientRoutesAroundDeadServer(t *testing.T) {
+	live, stop := fakeServer(t)
+	defer stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := l.Addr().String()
+	l.Close()
+
+	k := &Ketama{FailureLimit: 1, RetryInterval: time.Hour}
+	k.SetServers(live, dead)
+	c := NewFromSelector(k)
+
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("key-%d", i)
+		if addr, _ := k.PickServer(key); addr.String() == dead {
+			break
+		}
+	}
+
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != nil {
+		t.Fatalf("Set on a dead server's key: %v", err)
+	}
+	it, err := c.Get(key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(it.Value) != "v" {
+		t.Errorf("Get = %q; want %q", it.Value, "v")
+	}
+}
@@ -62,6 +62,10 @@ var (
 
 	// ErrNoServers is returned when no servers are configured or available.
 	ErrNoServers = errors.New("memcache: no servers configured or available")
+
+	// ErrAuthFailed is returned when a server rejects the SASL
+	// credentials of the Client.
+	ErrAuthFailed = errors.New("memcache: authentication failed")
 )
 
 // DefaultTimeout is the default socket read/write timeout.
@@ -126,6 +130,17 @@ func NewFromSelector(ss ServerSelector) *Client {
 	return &Client{selector: ss}
 }
 
+// Protocol selects the wire protocol a Client speaks.
+type Protocol int
+
+const (
+	// ASCII is memcached's text protocol.
+	ASCII Protocol = iota
+	// Binary is memcached's binary protocol. It is required for SASL
+	// authentication.
+	Binary
+)
+
 // Client is a memcache client.
 // It is safe for unlocked use by multiple concurrent goroutines.
 type Client struct {
@@ -133,10 +148,68 @@ type Client struct {
 	// If zero, DefaultTimeout is used.
 	Timeout time.Duration
 
+	// Protocol is the wire protocol used to talk to the servers.
+	// If Username is set, Binary is always used.
+	Protocol Protocol
+
+	// Username and Password are the SASL PLAIN credentials sent on
+	// every new connection. Leave Username empty to skip
+	// authentication.
+	Username string
+	Password string
+
 	selector ServerSelector
 
 	lk       sync.Mutex
 	freeconn map[string][]*conn
+	stats    map[string]*PoolStats
+}
+
+// PoolStats describes the connections of a Client to one server.
+type PoolStats struct {
+	// Open is the number of open connections, in use or idle.
+	Open int
+	// Idle is the number of open connections waiting to be reused.
+	Idle int
+	// Dials is the number of connections opened.
+	Dials uint64
+	// DialFailures is the number of connections that could not be
+	// opened or authenticated.
+	DialFailures uint64
+	// Timeouts is the number of operations, including dials, that
+	// timed out.
+	Timeouts uint64
+}
+
+// PoolStats returns the connection statistics of every server the
+// Client has talked to, keyed by server address.
+func (c *Client) PoolStats() map[string]PoolStats {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	m := make(map[string]PoolStats, len(c.stats))
+	for addr, st := range c.stats {
+		ps := *st
+		ps.Idle = len(c.freeconn[addr])
+		m[addr] = ps
+	}
+	return m
+}
+
+// poolStats returns the statistics of addr. c.lk must be held.
+func (c *Client) poolStats(addr net.Addr) *PoolStats {
+	if c.stats == nil {
+		c.stats = make(map[string]*PoolStats)
+	}
+	st, ok := c.stats[addr.String()]
+	if !ok {
+		st = new(PoolStats)
+		c.stats[addr.String()] = st
+	}
+	return st
+}
+
+func (c *Client) binary() bool {
+	return c.Protocol == Binary || c.Username != ""
 }
 
 // Item is an item to be got or stored in a memcached server.
@@ -188,10 +261,17 @@ func (cn *conn) condRelease(err *error) {
 	if *err == nil || resumableError(*err) {
 		cn.release()
 	} else {
-		cn.nc.Close()
+		cn.close()
 	}
 }
 
+func (cn *conn) close() {
+	cn.nc.Close()
+	cn.c.lk.Lock()
+	cn.c.poolStats(cn.addr).Open--
+	cn.c.lk.Unlock()
+}
+
 func (c *Client) putFreeConn(addr net.Addr, cn *conn) {
 	c.lk.Lock()
 	defer c.lk.Unlock()
@@ -201,6 +281,7 @@ func (c *Client) putFreeConn(addr net.Addr, cn *conn) {
 	freelist := c.freeconn[addr.String()]
 	if len(freelist) >= maxIdleConnsPerAddr {
 		cn.nc.Close()
+		c.poolStats(addr).Open--
 		return
 	}
 	c.freeconn[addr.String()] = append(freelist, cn)
@@ -264,6 +345,15 @@ func (c *Client) getConn(addr net.Addr) (*conn, error) {
 		return cn, nil
 	}
 	nc, err := c.dial(addr)
+	c.lk.Lock()
+	st := c.poolStats(addr)
+	if err != nil {
+		st.DialFailures++
+	} else {
+		st.Dials++
+		st.Open++
+	}
+	c.lk.Unlock()
 	if err != nil {
 		return nil, err
 	}
@@ -274,23 +364,89 @@ func (c *Client) getConn(addr net.Addr) (*conn, error) {
 		c:    c,
 	}
 	cn.extendDeadline()
+	if c.Username != "" {
+		if err := saslAuth(cn.rw, c.Username, c.Password); err != nil {
+			cn.close()
+			c.lk.Lock()
+			c.poolStats(addr).DialFailures++
+			c.lk.Unlock()
+			return nil, err
+		}
+	}
 	return cn, nil
 }
 
-func (c *Client) onItem(item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
-	addr, err := c.selector.PickServer(item.Key)
+// probe checks that addr answers on a new connection, speaking the
+// Client's protocol and authenticating first if the Client does.
+func (c *Client) probe(addr net.Addr) error {
+	nc, err := c.dial(addr)
 	if err != nil {
 		return err
 	}
-	cn, err := c.getConn(addr)
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(c.netTimeout()))
+	rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+	if !c.binary() {
+		return probeVersion(rw)
+	}
+	if c.Username != "" {
+		if err := saslAuth(rw, c.Username, c.Password); err != nil {
+			return err
+		}
+	}
+	res, err := binRoundTrip(rw, opNoop, "", nil, nil, 0)
 	if err != nil {
 		return err
 	}
-	defer cn.condRelease(&err)
-	if err = fn(c, cn.rw, item); err != nil {
-		return err
+	return res.statusError()
+}
+
+// timeout returns true if err is a connect or read/write timeout.
+func timeout(err error) bool {
+	if _, ok := err.(*ConnectTimeoutError); ok {
+		return true
+	}
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func (c *Client) onItem(item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	return c.withKeyAddr(item.Key, func(addr net.Addr) error {
+		return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+			return fn(c, rw, item)
+		})
+	})
+}
+
+// serverFailure returns true if err means that the server could not be
+// reached or dropped the connection, as opposed to a protocol-level
+// error.
+func serverFailure(err error) bool {
+	if err == nil || resumableError(err) {
+		return false
+	}
+	switch err.(type) {
+	case net.Error, *ConnectTimeoutError:
+		return true
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// reportHealth tells the selector, if it tracks server health, how an
+// operation on addr went.
+func (c *Client) reportHealth(addr net.Addr, err error) {
+	ht, ok := c.selector.(HealthTracker)
+	if !ok {
+		return
+	}
+	if serverFailure(err) {
+		if k, ok := ht.(*Ketama); ok {
+			k.useClientProbe(c.probe)
+		}
+		ht.ReportFailure(addr)
+	} else {
+		ht.ReportSuccess(addr)
 	}
-	return nil
 }
 
 func (c *Client) FlushAll() error {
@@ -327,10 +483,29 @@ func (c *Client) withKeyAddr(key string, fn func(net.Addr) error) (err error) {
 	if err != nil {
 		return err
 	}
-	return fn(addr)
+	err = fn(addr)
+	if !serverFailure(err) {
+		return err
+	}
+
+	// try once more if the failure got the server ejected
+	if _, ok := c.selector.(HealthTracker); ok {
+		if next, perr := c.selector.PickServer(key); perr == nil && next.String() != addr.String() {
+			return fn(next)
+		}
+	}
+	return err
 }
 
 func (c *Client) withAddrRw(addr net.Addr, fn func(*bufio.ReadWriter) error) (err error) {
+	defer func() {
+		if timeout(err) {
+			c.lk.Lock()
+			c.poolStats(addr).Timeouts++
+			c.lk.Unlock()
+		}
+		c.reportHealth(addr, err)
+	}()
 	cn, err := c.getConn(addr)
 	if err != nil {
 		return err
@@ -347,6 +522,9 @@ func (c *Client) withKeyRw(key string, fn func(*bufio.ReadWriter) error) error {
 
 func (c *Client) getFromAddr(addr net.Addr, keys []string, cb func(*Item)) error {
 	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+		if c.binary() {
+			return binGet(rw, keys, cb)
+		}
 		if _, err := fmt.Fprintf(rw, "gets %s\r\n", strings.Join(keys, " ")); err != nil {
 			return err
 		}
@@ -363,6 +541,9 @@ func (c *Client) getFromAddr(addr net.Addr, keys []string, cb func(*Item)) error
 // flushAllFromAddr send the flush_all command to the given addr
 func (c *Client) flushAllFromAddr(addr net.Addr) error {
 	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+		if c.binary() {
+			return binFlush(rw)
+		}
 		if _, err := fmt.Fprintf(rw, "flush_all\r\n"); err != nil {
 			return err
 		}
@@ -386,6 +567,12 @@ func (c *Client) flushAllFromAddr(addr net.Addr) error {
 func (c *Client) touchFromAddr(addr net.Addr, keys []string, expiration int32) error {
 	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
 		for _, key := range keys {
+			if c.binary() {
+				if err := binTouch(rw, key, expiration); err != nil {
+					return err
+				}
+				continue
+			}
 			if _, err := fmt.Fprintf(rw, "touch %s %d\r\n", key, expiration); err != nil {
 				return err
 			}
@@ -450,6 +637,161 @@ func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
 	return m, err
 }
 
+// MultiError is returned by the batch operations when some of the keys
+// failed. It maps each of those keys to its error.
+type MultiError map[string]error
+
+func (m MultiError) Error() string {
+	for key, err := range m {
+		if len(m) == 1 {
+			return fmt.Sprintf("memcache: %s: %v", key, err)
+		}
+		return fmt.Sprintf("memcache: %s: %v (and %d more errors)", key, err, len(m)-1)
+	}
+	return "memcache: no errors"
+}
+
+// SetMulti is a batch version of Set. The items for each server are
+// sent in a single pipelined round trip. If only some of the items
+// could not be stored, the error is a MultiError naming them.
+func (c *Client) SetMulti(items []*Item) error {
+	keys := make([]string, len(items))
+	byKey := make(map[string]*Item, len(items))
+	for i, it := range items {
+		keys[i] = it.Key
+		byKey[it.Key] = it
+	}
+	return c.onKeysMulti(keys, func(rw *bufio.ReadWriter, keys []string) (MultiError, error) {
+		batch := make([]*Item, len(keys))
+		for i, key := range keys {
+			batch[i] = byKey[key]
+		}
+		if c.binary() {
+			return binSetMulti(rw, batch)
+		}
+		return setMulti(rw, batch)
+	})
+}
+
+// DeleteMulti is a batch version of Delete. The keys for each server
+// are sent in a single pipelined round trip. If only some of the keys
+// could not be deleted, the error is a MultiError naming them, with
+// ErrCacheMiss for the keys that did not exist.
+func (c *Client) DeleteMulti(keys []string) error {
+	return c.onKeysMulti(keys, func(rw *bufio.ReadWriter, keys []string) (MultiError, error) {
+		if c.binary() {
+			return binDeleteMulti(rw, keys)
+		}
+		return deleteMulti(rw, keys)
+	})
+}
+
+// onKeysMulti groups keys by server and calls fn concurrently on each
+// group, merging the per-key errors.
+func (c *Client) onKeysMulti(keys []string, fn func(*bufio.ReadWriter, []string) (MultiError, error)) error {
+	keyMap := make(map[net.Addr][]string)
+	for _, key := range keys {
+		if !legalKey(key) {
+			return ErrMalformedKey
+		}
+		addr, err := c.selector.PickServer(key)
+		if err != nil {
+			return err
+		}
+		keyMap[addr] = append(keyMap[addr], key)
+	}
+
+	var lk sync.Mutex
+	failed := make(MultiError)
+	ch := make(chan error, buffered)
+	for addr, keys := range keyMap {
+		go func(addr net.Addr, keys []string) {
+			ch <- c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+				errs, err := fn(rw, keys)
+				lk.Lock()
+				defer lk.Unlock()
+				for key, err := range errs {
+					failed[key] = err
+				}
+				return err
+			})
+		}(addr, keys)
+	}
+
+	var err error
+	for _ = range keyMap {
+		if ge := <-ch; ge != nil {
+			err = ge
+		}
+	}
+	if err == nil && len(failed) > 0 {
+		err = failed
+	}
+	return err
+}
+
+// setMulti writes all the set commands before reading the replies.
+func setMulti(rw *bufio.ReadWriter, items []*Item) (MultiError, error) {
+	for _, item := range items {
+		if _, err := fmt.Fprintf(rw, "set %s %d %d %d\r\n", item.Key, item.Flags, item.Expiration, len(item.Value)); err != nil {
+			return nil, err
+		}
+		if _, err := rw.Write(item.Value); err != nil {
+			return nil, err
+		}
+		if _, err := rw.Write(crlf); err != nil {
+			return nil, err
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+
+	errs := make(MultiError)
+	for _, item := range items {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return errs, err
+		}
+		switch {
+		case bytes.Equal(line, resultStored):
+		case bytes.Equal(line, resultNotStored):
+			errs[item.Key] = ErrNotStored
+		default:
+			errs[item.Key] = fmt.Errorf("memcache: unexpected response line from set: %q", string(line))
+		}
+	}
+	return errs, nil
+}
+
+// deleteMulti writes all the delete commands before reading the replies.
+func deleteMulti(rw *bufio.ReadWriter, keys []string) (MultiError, error) {
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(rw, "delete %s\r\n", key); err != nil {
+			return nil, err
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+
+	errs := make(MultiError)
+	for _, key := range keys {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return errs, err
+		}
+		switch {
+		case bytes.Equal(line, resultDeleted):
+		case bytes.Equal(line, resultNotFound):
+			errs[key] = ErrCacheMiss
+		default:
+			errs[key] = fmt.Errorf("memcache: unexpected response line from delete: %q", string(line))
+		}
+	}
+	return errs, nil
+}
+
 // parseGetResponse reads a GET response from r and calls cb for each
 // read and allocated Item
 func parseGetResponse(r *bufio.Reader, cb func(*Item)) error {
@@ -542,6 +884,9 @@ func (c *Client) populateOne(rw *bufio.ReadWriter, verb string, item *Item) erro
 	if !legalKey(item.Key) {
 		return ErrMalformedKey
 	}
+	if c.binary() {
+		return binStore(rw, verb, item)
+	}
 	var err error
 	if verb == "cas" {
 		_, err = fmt.Fprintf(rw, "%s %s %d %d %d %d\r\n",
@@ -615,6 +960,9 @@ func writeExpectf(rw *bufio.ReadWriter, expect []byte, format string, args ...in
 // returned if the item didn't already exist in the cache.
 func (c *Client) Delete(key string) error {
 	return c.withKeyRw(key, func(rw *bufio.ReadWriter) error {
+		if c.binary() {
+			return binDelete(rw, key)
+		}
 		return writeExpectf(rw, resultDeleted, "delete %s\r\n", key)
 	})
 }
@@ -622,6 +970,9 @@ func (c *Client) Delete(key string) error {
 // DeleteAll deletes all items in the cache.
 func (c *Client) DeleteAll() error {
 	return c.withKeyRw("", func(rw *bufio.ReadWriter) error {
+		if c.binary() {
+			return binFlush(rw)
+		}
 		return writeExpectf(rw, resultDeleted, "flush_all\r\n")
 	})
 }
@@ -648,6 +999,11 @@ func (c *Client) Decrement(key string, delta uint64) (newValue uint64, err error
 func (c *Client) incrDecr(verb, key string, delta uint64) (uint64, error) {
 	var val uint64
 	err := c.withKeyRw(key, func(rw *bufio.ReadWriter) error {
+		if c.binary() {
+			var err error
+			val, err = binIncrDecr(rw, verb, key, delta)
+			return err
+		}
 		line, err := writeReadLine(rw, "%s %s %d\r\n", verb, key, delta)
 		if err != nil {
 			return err
@@ -52,19 +52,11 @@ type ServerList struct {
 func (ss *ServerList) SetServers(servers ...string) error {
 	naddr := make([]net.Addr, len(servers))
 	for i, server := range servers {
-		if strings.Contains(server, "/") {
-			addr, err := net.ResolveUnixAddr("unix", server)
-			if err != nil {
-				return err
-			}
-			naddr[i] = addr
-		} else {
-			tcpaddr, err := net.ResolveTCPAddr("tcp", server)
-			if err != nil {
-				return err
-			}
-			naddr[i] = tcpaddr
+		addr, err := resolveAddr(server)
+		if err != nil {
+			return err
 		}
+		naddr[i] = addr
 	}
 
 	ss.lk.Lock()
@@ -73,6 +65,15 @@ func (ss *ServerList) SetServers(servers ...string) error {
 	return nil
 }
 
+// resolveAddr resolves a server name, which is a unix socket path if it
+// contains a slash and a TCP address otherwise.
+func resolveAddr(server string) (net.Addr, error) {
+	if strings.Contains(server, "/") {
+		return net.ResolveUnixAddr("unix", server)
+	}
+	return net.ResolveTCPAddr("tcp", server)
+}
+
 // Each iterates over each server calling the given function
 func (ss *ServerList) Each(f func(net.Addr) error) error {
 	ss.lk.RLock()
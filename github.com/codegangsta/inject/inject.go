@@ -4,6 +4,8 @@ package inject
 import (
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 )
 
 // Injector represents an interface for mapping and injecting dependencies into structs
@@ -21,8 +23,9 @@ type Injector interface {
 // Applicator represents an interface for mapping dependencies to a struct.
 type Applicator interface {
 	// Maps dependencies in the Type map to each field in the struct
-	// that is tagged with 'inject'. Returns an error if the injection
-	// fails.
+	// that is tagged with 'inject' or 'inject:""'. Fields tagged with 'inject:"name=x"'
+	// get the value mapped under the name x. Returns an error if the
+	// injection fails.
 	Apply(interface{}) error
 }
 
@@ -50,11 +53,64 @@ type TypeMapper interface {
 	// Returns the Value that is mapped to the current type. Returns a zeroed Value if
 	// the Type has not been mapped.
 	Get(reflect.Type) reflect.Value
+	// Maps the interface{} value based on its immediate type and the given name.
+	// Named values are only injected into struct fields that ask for the name.
+	MapNamed(string, interface{}) TypeMapper
+	// Returns the Value that is mapped to the type and name. Returns a zeroed Value if
+	// nothing has been mapped under that name.
+	GetNamed(string, reflect.Type) reflect.Value
+	// Registers a constructor for the type of its first return value, which is called
+	// with injected arguments the first time the type is needed. The value is then kept
+	// for the life of the injector. The constructor may return an error as its second
+	// return value. It panics if the constructor is not such a function.
+	Provide(interface{}) TypeMapper
+	// Registers a constructor like Provide, with the given Lifetime.
+	ProvideWith(Lifetime, interface{}) TypeMapper
+}
+
+// Lifetime tells how long a value built by a constructor registered with
+// TypeMapper.ProvideWith is kept.
+type Lifetime int
+
+const (
+	// Singleton values are built once, from the injector the constructor is
+	// registered in, and shared by all its children.
+	Singleton Lifetime = iota
+	// Scoped values are built once for each injector they are requested from,
+	// such as the child injector of a single request.
+	Scoped
+	// Transient values are built every time they are requested.
+	Transient
+)
+
+type key struct {
+	typ  reflect.Type
+	name string
+}
+
+func (k key) String() string {
+	if k.name != "" {
+		return fmt.Sprintf("%v %q", k.typ, k.name)
+	}
+	return k.typ.String()
+}
+
+type provider struct {
+	fn       reflect.Value
+	lifetime Lifetime
+	owner    *injector
+
+	mu    sync.Mutex
+	done  bool
+	value reflect.Value
 }
 
 type injector struct {
-	values map[reflect.Type]reflect.Value
-	parent Injector
+	values    map[reflect.Type]reflect.Value
+	named     map[string]map[reflect.Type]reflect.Value
+	providers map[key]*provider
+	scoped    map[*provider]reflect.Value
+	parent    Injector
 }
 
 // InterfaceOf dereferences a pointer to an Interface type.
@@ -76,7 +132,10 @@ func InterfaceOf(value interface{}) reflect.Type {
 // New returns a new Injector.
 func New() Injector {
 	return &injector{
-		values: make(map[reflect.Type]reflect.Value),
+		values:    make(map[reflect.Type]reflect.Value),
+		named:     make(map[string]map[reflect.Type]reflect.Value),
+		providers: make(map[key]*provider),
+		scoped:    make(map[*provider]reflect.Value),
 	}
 }
 
@@ -90,10 +149,9 @@ func (inj *injector) Invoke(f interface{}) ([]reflect.Value, error) {
 
 	var in = make([]reflect.Value, t.NumIn()) //Panic if t is not kind of Func
 	for i := 0; i < t.NumIn(); i++ {
-		argType := t.In(i)
-		val := inj.Get(argType)
-		if !val.IsValid() {
-			return nil, fmt.Errorf("Value not found for type %v", argType)
+		val, err := inj.resolve(key{typ: t.In(i)}, nil, inj)
+		if err != nil {
+			return nil, err
 		}
 
 		in[i] = val
@@ -121,11 +179,15 @@ func (inj *injector) Apply(val interface{}) error {
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
 		structField := t.Field(i)
-		if f.CanSet() && (structField.Tag == "inject" || structField.Tag.Get("inject") != "") {
-			ft := f.Type()
-			v := inj.Get(ft)
-			if !v.IsValid() {
-				return fmt.Errorf("Value not found for type %v", ft)
+		tag, tagged := structField.Tag.Lookup("inject")
+		if f.CanSet() && (structField.Tag == "inject" || tagged) {
+			k := key{typ: f.Type()}
+			if strings.HasPrefix(tag, "name=") {
+				k.name = tag[len("name="):]
+			}
+			v, err := inj.resolve(k, nil, inj)
+			if err != nil {
+				return err
 			}
 
 			f.Set(v)
@@ -155,31 +217,203 @@ func (i *injector) Set(typ reflect.Type, val reflect.Value) TypeMapper {
 	return i
 }
 
+// Maps the concrete value of val to its dynamic type and the given name.
+// It returns the TypeMapper registered in.
+func (i *injector) MapNamed(name string, val interface{}) TypeMapper {
+	if i.named[name] == nil {
+		i.named[name] = make(map[reflect.Type]reflect.Value)
+	}
+	i.named[name][reflect.TypeOf(val)] = reflect.ValueOf(val)
+	return i
+}
+
+func (i *injector) Provide(fn interface{}) TypeMapper {
+	return i.ProvideWith(Singleton, fn)
+}
+
+func (i *injector) ProvideWith(lifetime Lifetime, fn interface{}) TypeMapper {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() < 1 || t.NumOut() > 2 ||
+		(t.NumOut() == 2 && t.Out(1) != reflect.TypeOf((*error)(nil)).Elem()) {
+		panic("inject: a provider must be a func returning a value and optionally an error")
+	}
+	i.providers[key{typ: t.Out(0)}] = &provider{fn: reflect.ValueOf(fn), lifetime: lifetime, owner: i}
+	return i
+}
+
 func (i *injector) Get(t reflect.Type) reflect.Value {
-	val := i.values[t]
+	val, _ := i.resolve(key{typ: t}, nil, i)
+	return val
+}
+
+func (i *injector) GetNamed(name string, t reflect.Type) reflect.Value {
+	val, _ := i.resolve(key{t, name}, nil, i)
+	return val
+}
+
+// resolve looks k up in the injector and its parents, building it if a
+// provider is found. path holds the keys being built that led to k, and scope
+// is the injector the lookup started from.
+func (i *injector) resolve(k key, path []key, scope *injector) (reflect.Value, error) {
+	for _, p := range path {
+		if p == k {
+			return reflect.Value{}, fmt.Errorf("Dependency cycle: %s", formatPath(append(path, k)))
+		}
+	}
 
+	val, p := i.lookup(k)
 	if val.IsValid() {
-		return val
+		return val, nil
+	}
+	if p != nil {
+		return p.get(append(path, k), scope)
+	}
+
+	if len(path) > 0 {
+		return reflect.Value{}, fmt.Errorf("Value not found for type %v, required by %s", k, formatPath(path))
+	}
+	return reflect.Value{}, fmt.Errorf("Value not found for type %v", k)
+}
+
+// lookup returns the value or provider mapped to k in the injector or its
+// parents.
+func (i *injector) lookup(k key) (reflect.Value, *provider) {
+	for j := i; j != nil; {
+		val, p := j.find(k)
+		if val.IsValid() || p != nil {
+			return val, p
+		}
+
+		// Still no type found, try to look it up on the parent
+		parent, ok := j.parent.(*injector)
+		if !ok && j.parent != nil && k.name == "" {
+			if val := j.parent.Get(k.typ); val.IsValid() {
+				return val, nil
+			}
+		}
+		j = parent
+	}
+	return reflect.Value{}, nil
+}
+
+// find returns the value or provider mapped to k in this injector alone.
+func (i *injector) find(k key) (reflect.Value, *provider) {
+	values := i.values
+	if k.name != "" {
+		values = i.named[k.name]
+	}
+
+	if val := values[k.typ]; val.IsValid() {
+		return val, nil
+	}
+	if p := i.providers[k]; p != nil {
+		return reflect.Value{}, p
 	}
 
 	// no concrete types found, try to find implementors
 	// if t is an interface
-	if t.Kind() == reflect.Interface {
-		for k, v := range i.values {
-			if k.Implements(t) {
-				val = v
-				break
+	if k.typ.Kind() == reflect.Interface {
+		for t, v := range values {
+			if t.Implements(k.typ) {
+				return v, nil
+			}
+		}
+		for pk, p := range i.providers {
+			if pk.name == k.name && pk.typ.Implements(k.typ) {
+				return reflect.Value{}, p
+			}
+		}
+	}
+	return reflect.Value{}, nil
+}
+
+func (p *provider) get(path []key, scope *injector) (reflect.Value, error) {
+	switch p.lifetime {
+	case Singleton:
+		p.mu.Lock()
+		if p.done {
+			p.mu.Unlock()
+			return p.value, nil
+		}
+		p.mu.Unlock()
+
+		// Singletons lock while they are built. Without cycles they are
+		// always locked in dependency order, which cannot deadlock.
+		if err := p.cycle(path, p.owner); err != nil {
+			return reflect.Value{}, err
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.done {
+			val, err := p.call(path, p.owner)
+			if err != nil {
+				return val, err
+			}
+			p.value, p.done = val, true
+		}
+		return p.value, nil
+	case Scoped:
+		if val, ok := scope.scoped[p]; ok {
+			return val, nil
+		}
+		val, err := p.call(path, scope)
+		if err == nil {
+			scope.scoped[p] = val
+		}
+		return val, err
+	}
+	return p.call(path, scope)
+}
+
+// cycle walks the constructors p depends on, without calling them, and
+// returns an error if one of them depends on a key in path.
+func (p *provider) cycle(path []key, in *injector) error {
+	t := p.fn.Type()
+	for n := 0; n < t.NumIn(); n++ {
+		k := key{typ: t.In(n)}
+		for _, pk := range path {
+			if pk == k {
+				return fmt.Errorf("Dependency cycle: %s", formatPath(append(path, k)))
+			}
+		}
+		if _, dep := in.lookup(k); dep != nil {
+			scope := in
+			if dep.lifetime == Singleton {
+				scope = dep.owner
+			}
+			if err := dep.cycle(append(path, k), scope); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
+}
 
-	// Still no type found, try to look it up on the parent
-	if !val.IsValid() && i.parent != nil {
-		val = i.parent.Get(t)
+// call invokes the constructor with arguments resolved from the injector in.
+func (p *provider) call(path []key, in *injector) (reflect.Value, error) {
+	t := p.fn.Type()
+	args := make([]reflect.Value, t.NumIn())
+	for n := range args {
+		val, err := in.resolve(key{typ: t.In(n)}, path, in)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		args[n] = val
 	}
 
-	return val
+	out := p.fn.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("Provider for %s failed: %v", formatPath(path), out[1].Interface())
+	}
+	return out[0], nil
+}
 
+func formatPath(path []key) string {
+	names := make([]string, len(path))
+	for n, k := range path {
+		names[n] = k.String()
+	}
+	return strings.Join(names, " -> ")
 }
 
 func (i *injector) SetParent(parent Injector) {
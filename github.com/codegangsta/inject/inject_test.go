@@ -1,10 +1,13 @@
 package inject_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/codegangsta/inject"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type SpecialString interface {
@@ -157,3 +160,164 @@ func TestInjectImplementors(t *testing.T) {
 
 	expect(t, injector.Get(inject.InterfaceOf((*fmt.Stringer)(nil))).IsValid(), true)
 }
+
+type Config struct {
+	DSN string
+}
+
+type Store struct {
+	Config *Config
+}
+
+type Service struct {
+	Store *Store
+}
+
+type Chicken struct{}
+type Egg struct{}
+
+func Test_InjectorProvide(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.Map(&Config{"db://"})
+	injector.Provide(func(c *Config) *Store {
+		calls++
+		return &Store{c}
+	})
+	injector.Provide(func(s *Store) (*Service, error) {
+		return &Service{s}, nil
+	})
+	expect(t, calls, 0)
+
+	child := inject.New()
+	child.SetParent(injector)
+	var first, second *Service
+	_, err := child.Invoke(func(s *Service) { first = s })
+	expect(t, err, nil)
+	_, err = injector.Invoke(func(s *Service) { second = s })
+	expect(t, err, nil)
+
+	expect(t, first.Store.Config.DSN, "db://")
+	expect(t, first, second)
+	expect(t, calls, 1)
+}
+
+func Test_InjectorProvideScoped(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.ProvideWith(inject.Scoped, func() *Config {
+		calls++
+		return &Config{}
+	})
+
+	request1 := inject.New()
+	request1.SetParent(injector)
+	request2 := inject.New()
+	request2.SetParent(injector)
+
+	typ := reflect.TypeOf(&Config{})
+	a := request1.Get(typ).Interface()
+	expect(t, request1.Get(typ).Interface(), a)
+	refute(t, request2.Get(typ).Interface(), a)
+	expect(t, calls, 2)
+}
+
+func Test_InjectorProvideTransient(t *testing.T) {
+	injector := inject.New()
+	calls := 0
+	injector.ProvideWith(inject.Transient, func() *Config {
+		calls++
+		return &Config{}
+	})
+
+	typ := reflect.TypeOf(&Config{})
+	refute(t, injector.Get(typ).Interface(), injector.Get(typ).Interface())
+	expect(t, calls, 2)
+}
+
+func Test_InjectorProvideInterface(t *testing.T) {
+	injector := inject.New()
+	injector.Provide(func() *Greeter { return &Greeter{"Jeremy"} })
+
+	expect(t, injector.Get(inject.InterfaceOf((*fmt.Stringer)(nil))).IsValid(), true)
+}
+
+func Test_InjectorProvideErrors(t *testing.T) {
+	injector := inject.New()
+	injector.Provide(func(c *Config) *Store { return &Store{c} })
+	injector.Provide(func(s *Store) *Service { return &Service{s} })
+
+	_, err := injector.Invoke(func(s *Service) {})
+	expect(t, err.Error(), "Value not found for type *inject_test.Config, required by *inject_test.Service -> *inject_test.Store")
+
+	injector.Provide(func(e Egg) Chicken { return Chicken{} })
+	injector.Provide(func(c Chicken) Egg { return Egg{} })
+	_, err = injector.Invoke(func(c Chicken) {})
+	expect(t, err.Error(), "Dependency cycle: inject_test.Chicken -> inject_test.Egg -> inject_test.Chicken")
+
+	injector.Provide(func() (*Config, error) { return nil, errors.New("no database") })
+	_, err = injector.Invoke(func(s *Service) {})
+	if err == nil || !strings.Contains(err.Error(), "no database") {
+		t.Errorf("Expected the constructor error, got %v", err)
+	}
+
+	defer func() {
+		refute(t, recover(), nil)
+	}()
+	injector.Provide("not a func")
+}
+
+type NamedStruct struct {
+	Primary   string `inject:"name=primary"`
+	Secondary string `inject:"name=secondary"`
+	Plain     string `inject:""`
+}
+
+func Test_InjectorApplyNamed(t *testing.T) {
+	injector := inject.New()
+	injector.Map("plain")
+	injector.MapNamed("primary", "first")
+
+	child := inject.New()
+	child.SetParent(injector)
+	child.MapNamed("secondary", "second")
+
+	s := NamedStruct{}
+	err := child.Apply(&s)
+	expect(t, err, nil)
+	expect(t, s.Primary, "first")
+	expect(t, s.Secondary, "second")
+	expect(t, s.Plain, "plain")
+	expect(t, child.GetNamed("secondary", reflect.TypeOf("")).String(), "second")
+	expect(t, injector.GetNamed("secondary", reflect.TypeOf("")).IsValid(), false)
+
+	err = injector.Apply(&s)
+	expect(t, err.Error(), `Value not found for type string "secondary"`)
+}
+
+type Hen struct{}
+type Nest struct{}
+
+func Test_InjectorProvideConcurrentCycle(t *testing.T) {
+	injector := inject.New()
+	injector.Provide(func(n Nest) Hen { return Hen{} })
+	injector.Provide(func(h Hen) Nest { return Nest{} })
+
+	errs := make(chan error)
+	for _, f := range []interface{}{func(Hen) {}, func(Nest) {}} {
+		go func(f interface{}) {
+			_, err := injector.Invoke(f)
+			errs <- err
+		}(f)
+	}
+	for n := 0; n < 2; n++ {
+		select {
+		case err := <-errs:
+			if err == nil || !strings.HasPrefix(err.Error(), "Dependency cycle") {
+				t.Errorf("Expected a dependency cycle, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Deadlocked building singletons that depend on each other")
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package martini
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compress returns a middleware handler that compresses responses with gzip or deflate, whichever the client
+// prefers. Responses that already carry a Content-Encoding, partial content and media that is compressed by
+// nature are written as they are.
+func Compress() Handler {
+	return func(res http.ResponseWriter, req *http.Request, c Context) {
+		res.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+		if encoding == "" || req.Method == "HEAD" {
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: res.(ResponseWriter), encoding: encoding}
+		c.MapTo(cw, (*http.ResponseWriter)(nil))
+		c.Next()
+		cw.Close()
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header, or returns "" if neither is acceptable.
+func negotiateEncoding(accept string) string {
+	gzipQ, deflateQ := encodingQ(accept, "gzip"), encodingQ(accept, "deflate")
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows the given encoding.
+func acceptsEncoding(accept, encoding string) bool {
+	return encodingQ(accept, encoding) > 0
+}
+
+// encodingQ returns the quality an Accept-Encoding header gives to encoding, falling back to that of "*".
+func encodingQ(accept, encoding string) float64 {
+	q, wildcard := -1.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		name, value := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			if v := strings.TrimSpace(part[i+1:]); strings.HasPrefix(v, "q=") {
+				if f, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					value = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encoding:
+			q = value
+		case "*":
+			wildcard = value
+		}
+	}
+	if q < 0 {
+		return wildcard
+	}
+	return q
+}
+
+// compressible reports whether content of the given type is worth compressing.
+func compressible(contentType string) bool {
+	t := strings.ToLower(contentType)
+	if i := strings.Index(t, ";"); i >= 0 {
+		t = t[:i]
+	}
+	switch {
+	case strings.HasPrefix(t, "image/svg"):
+		return true
+	case strings.HasPrefix(t, "image/"), strings.HasPrefix(t, "audio/"), strings.HasPrefix(t, "video/"):
+		return false
+	case strings.HasSuffix(t, "zip"), strings.HasSuffix(t, "compressed"), t == "application/octet-stream":
+		return false
+	}
+	return true
+}
+
+// compressWriter is a ResponseWriter that compresses the body it is given. Whether to compress is decided when
+// the header is written.
+type compressWriter struct {
+	ResponseWriter
+	encoding string
+	writer   io.WriteCloser
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(s int) {
+	if !cw.decided {
+		cw.decided = true
+		h := cw.Header()
+		if h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && s != http.StatusNoContent &&
+			s != http.StatusNotModified && s >= http.StatusOK && compressible(h.Get("Content-Type")) {
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length")
+			if cw.encoding == "gzip" {
+				cw.writer = gzip.NewWriter(cw.ResponseWriter)
+			} else {
+				cw.writer, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(s)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.Written() {
+		// sniff before compressing, or the compressed bytes would be sniffed instead
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.writer.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.writer.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	cw.ResponseWriter.Flush()
+}
+
+// Close flushes any compressed data that is still buffered.
+func (cw *compressWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+	return cw.writer.Close()
+}
@@ -0,0 +1,86 @@
+package martini
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Compress(t *testing.T) {
+	m := New()
+	m.Use(Compress())
+	m.Use(func(res http.ResponseWriter) {
+		res.Write([]byte("<html>hello</html>"))
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, req)
+
+	expect(t, recorder.Header().Get("Content-Encoding"), "gzip")
+	expect(t, recorder.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	expect(t, recorder.Header().Get("Vary"), "Accept-Encoding")
+	r, err := gzip.NewReader(recorder.Body)
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(r)
+	expect(t, string(body), "<html>hello</html>")
+}
+
+func Test_Compress_Deflate(t *testing.T) {
+	m := New()
+	m.Use(Compress())
+	m.Use(func(res http.ResponseWriter) {
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(`{"ok":true}`))
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, deflate")
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, req)
+
+	expect(t, recorder.Code, http.StatusCreated)
+	expect(t, recorder.Header().Get("Content-Encoding"), "deflate")
+	body, _ := ioutil.ReadAll(flate.NewReader(recorder.Body))
+	expect(t, string(body), `{"ok":true}`)
+}
+
+func Test_Compress_Skipped(t *testing.T) {
+	for _, tt := range []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "text/plain"},
+		{"identity", "text/plain"},
+		{"gzip", "image/png"},
+	} {
+		m := New()
+		m.Use(Compress())
+		m.Use(func(res http.ResponseWriter) {
+			res.Header().Set("Content-Type", tt.contentType)
+			res.Write([]byte("raw"))
+		})
+
+		req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+		req.Header.Set("Accept-Encoding", tt.accept)
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, req)
+
+		expect(t, recorder.Header().Get("Content-Encoding"), "")
+		expect(t, recorder.Body.String(), "raw")
+	}
+}
+
+func Test_NegotiateEncoding(t *testing.T) {
+	expect(t, negotiateEncoding("gzip, deflate"), "gzip")
+	expect(t, negotiateEncoding("deflate, gzip;q=0.8"), "deflate")
+	expect(t, negotiateEncoding("*"), "gzip")
+	expect(t, negotiateEncoding("*;q=0, br"), "")
+	expect(t, acceptsEncoding("br, *;q=0.1", "gzip"), true)
+	expect(t, acceptsEncoding("gzip;q=0", "gzip"), false)
+}
@@ -1,8 +1,19 @@
 package martini
 
 import (
+	stdcontext "context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -31,3 +42,173 @@ func Logger() Handler {
 		}
 	}
 }
+
+// LogFormat selects how martini.AccessLogger writes its records.
+type LogFormat int
+
+const (
+	// LogJSON writes one JSON object per line.
+	LogJSON LogFormat = iota
+	// Logfmt writes one line of space separated key=value pairs.
+	Logfmt
+)
+
+// RequestID identifies a request. An instance of martini.RequestID is available to be injected into any handler
+// that runs after martini.AccessLogger.
+type RequestID string
+
+type requestIDKey struct{}
+
+// RequestIDFrom returns the ID martini.AccessLogger gave the request that ctx belongs to, if any.
+func RequestIDFrom(ctx stdcontext.Context) (RequestID, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(RequestID)
+	return id, ok
+}
+
+// AccessLogOptions is a struct for specifying configuration options for the martini.AccessLogger middleware.
+type AccessLogOptions struct {
+	// Format of the records. Defaults to LogJSON.
+	Format LogFormat
+	// Out is where records are written. Defaults to os.Stdout.
+	Out io.Writer
+	// Header carries the request ID in and out. Defaults to X-Request-ID.
+	Header string
+}
+
+func prepareAccessLogOptions(options []AccessLogOptions) AccessLogOptions {
+	var opt AccessLogOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	// Defaults
+	if opt.Out == nil {
+		opt.Out = os.Stdout
+	}
+	if len(opt.Header) == 0 {
+		opt.Header = "X-Request-ID"
+	}
+	return opt
+}
+
+// accessRecord is a single access log entry. Its fields are written in this order.
+type accessRecord struct {
+	Time      string  `json:"time"`
+	RequestID string  `json:"request_id"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	Size      int     `json:"size"`
+	Latency   float64 `json:"latency_ms"`
+	IP        string  `json:"ip"`
+	UserAgent string  `json:"user_agent"`
+}
+
+// AccessLogger returns a middleware handler that writes a structured record for every request once it has been
+// handled. Each request gets an ID, taken from the incoming request ID header when present or generated otherwise.
+// The ID is sent back in the same header, mapped as a martini.RequestID and stored in the request's context.
+func AccessLogger(options ...AccessLogOptions) Handler {
+	opt := prepareAccessLogOptions(options)
+	var mu sync.Mutex
+
+	return func(res http.ResponseWriter, req *http.Request, c Context) {
+		start := time.Now()
+
+		id := RequestID(req.Header.Get(opt.Header))
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		res.Header().Set(opt.Header, string(id))
+		c.Map(id)
+		c.Map(req.WithContext(stdcontext.WithValue(req.Context(), requestIDKey{}, id)))
+
+		rw := res.(ResponseWriter)
+		c.Next()
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		r := accessRecord{
+			Time:      start.UTC().Format(time.RFC3339Nano),
+			RequestID: string(id),
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			Status:    status,
+			Size:      rw.Size(),
+			Latency:   float64(time.Since(start)) / float64(time.Millisecond),
+			IP:        clientIP(req),
+			UserAgent: req.UserAgent(),
+		}
+
+		var line []byte
+		if opt.Format == Logfmt {
+			line = r.logfmt()
+		} else {
+			line, _ = json.Marshal(r)
+			line = append(line, '\n')
+		}
+		mu.Lock()
+		opt.Out.Write(line)
+		mu.Unlock()
+	}
+}
+
+func (r accessRecord) logfmt() []byte {
+	var b []byte
+	pair := func(k, v string) {
+		if len(b) > 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, k...)
+		b = append(b, '=')
+		if v == "" || strings.ContainsAny(v, " =\"\\") || strings.IndexFunc(v, func(r rune) bool { return r < ' ' }) >= 0 {
+			b = strconv.AppendQuote(b, v)
+		} else {
+			b = append(b, v...)
+		}
+	}
+	pair("time", r.Time)
+	pair("request_id", r.RequestID)
+	pair("method", r.Method)
+	pair("path", r.Path)
+	pair("status", strconv.Itoa(r.Status))
+	pair("size", strconv.Itoa(r.Size))
+	pair("latency_ms", fmt.Sprintf("%.3f", r.Latency))
+	pair("ip", r.IP)
+	pair("user_agent", r.UserAgent)
+	return append(b, '\n')
+}
+
+// clientIP returns the address the request originates from, preferring the headers set by proxies.
+func clientIP(req *http.Request) string {
+	if addr := req.Header.Get("X-Real-IP"); addr != "" {
+		return addr
+	}
+	if addr := req.Header.Get("X-Forwarded-For"); addr != "" {
+		return strings.TrimSpace(strings.Split(addr, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// validRequestID reports whether an incoming request ID is safe to propagate into responses and logs.
+func validRequestID(id RequestID) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() RequestID {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return RequestID(hex.EncodeToString(b))
+}
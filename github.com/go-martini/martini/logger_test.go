@@ -2,9 +2,11 @@ package martini
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -29,3 +31,60 @@ func Test_Logger(t *testing.T) {
 	expect(t, recorder.Code, http.StatusNotFound)
 	refute(t, len(buff.String()), 0)
 }
+
+func Test_AccessLogger(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	recorder := httptest.NewRecorder()
+
+	m := New()
+	m.Use(AccessLogger(AccessLogOptions{Out: buff}))
+	m.Use(func(res http.ResponseWriter, req *http.Request, id RequestID) {
+		fromCtx, _ := RequestIDFrom(req.Context())
+		expect(t, fromCtx, id)
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte("hello"))
+	})
+
+	req, _ := http.NewRequest("POST", "http://localhost:3000/foobar", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "192.168.1.2, 10.0.0.1")
+	req.Header.Set("User-Agent", "tester")
+	m.ServeHTTP(recorder, req)
+
+	var r map[string]interface{}
+	expect(t, json.Unmarshal(buff.Bytes(), &r), nil)
+	expect(t, r["method"], "POST")
+	expect(t, r["path"], "/foobar")
+	expect(t, r["status"], float64(201))
+	expect(t, r["size"], float64(5))
+	expect(t, r["ip"], "192.168.1.2")
+	expect(t, r["user_agent"], "tester")
+	expect(t, len(r["request_id"].(string)), 32)
+	expect(t, recorder.Header().Get("X-Request-ID"), r["request_id"])
+}
+
+func Test_AccessLogger_Logfmt(t *testing.T) {
+	buff := bytes.NewBufferString("")
+	recorder := httptest.NewRecorder()
+
+	m := New()
+	m.Use(AccessLogger(AccessLogOptions{Format: Logfmt, Out: buff}))
+	m.Use(func(res http.ResponseWriter, id RequestID) {
+		res.Write([]byte(id))
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/foo", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Request-ID", "abc-123")
+	req.Header.Set("User-Agent", "a b")
+	m.ServeHTTP(recorder, req)
+
+	expect(t, recorder.Body.String(), "abc-123")
+	expect(t, recorder.Header().Get("X-Request-ID"), "abc-123")
+	line := buff.String()
+	for _, want := range []string{"request_id=abc-123 method=GET path=/foo status=200 size=7 ", ` ip=10.0.0.1 user_agent="a b"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sync"
+	"syscall"
 
 	"github.com/codegangsta/inject"
 )
@@ -32,6 +34,11 @@ type Martini struct {
 	handlers []Handler
 	action   Handler
 	logger   *log.Logger
+
+	mu      sync.Mutex
+	server  *http.Server
+	done    chan struct{}
+	signals []os.Signal
 }
 
 // New creates a bare bones Martini instance. Use this method if you want to have full control over the middleware that is used.
@@ -69,15 +76,10 @@ func (m *Martini) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	m.createContext(res, req).run()
 }
 
-// Run the http server on a given host and port.
+// Run the http server on a given host and port. It returns once the server has been stopped with Shutdown.
 func (m *Martini) RunOnAddr(addr string) {
-	// TODO: Should probably be implemented using a new instance of http.Server in place of
-	// calling http.ListenAndServer directly, so that it could be stored in the martini struct for later use.
-	// This would also allow to improve testing when a custom host and port are passed.
-
-	logger := m.Injector.Get(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
-	logger.Printf("listening on %s (%s)\n", addr, Env)
-	logger.Fatalln(http.ListenAndServe(addr, m))
+	srv := &http.Server{Addr: addr, Handler: m}
+	m.serve(srv, srv.ListenAndServe)
 }
 
 // Run the http server. Listening on os.GetEnv("PORT") or 3000 by default.
@@ -108,7 +110,7 @@ type ClassicMartini struct {
 }
 
 // Classic creates a classic Martini with some basic default middleware - martini.Logger, martini.Recovery and martini.Static.
-// Classic also maps martini.Routes as a service.
+// Classic also maps martini.Routes as a service, and shuts the server down gracefully on os.Interrupt and SIGTERM.
 func Classic() *ClassicMartini {
 	r := NewRouter()
 	m := New()
@@ -117,6 +119,7 @@ func Classic() *ClassicMartini {
 	m.Use(Static("public"))
 	m.MapTo(r, (*Routes)(nil))
 	m.Action(r.Handle)
+	m.ShutdownOn(os.Interrupt, syscall.SIGTERM)
 	return &ClassicMartini{m, r}
 }
 
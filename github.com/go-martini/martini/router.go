@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 // Params is a map of name/value pairs for named routes. An instance of martini.Params is available to be injected into any route handler.
@@ -35,6 +36,7 @@ type Router interface {
 	Any(string, ...Handler) Route
 
 	// NotFound sets the handlers that are called when a no route matches a request. Throws a basic 404 by default.
+	// Requests whose path matches a route registered for another method are answered with a 405 and an Allow header instead.
 	NotFound(...Handler)
 
 	// Handle is the entry point for routing. This is used as a martini.Handler
@@ -43,6 +45,7 @@ type Router interface {
 
 type router struct {
 	routes    []*route
+	tree      *tree
 	notFounds []Handler
 	groups    []group
 }
@@ -62,7 +65,7 @@ type group struct {
 //
 // If you are using ClassicMartini, then this is done for you.
 func NewRouter() Router {
-	return &router{notFounds: []Handler{http.NotFound}, groups: make([]group, 0)}
+	return &router{tree: newTree(), notFounds: []Handler{http.NotFound}, groups: make([]group, 0)}
 }
 
 func (r *router) Group(pattern string, fn func(Router), h ...Handler) {
@@ -104,16 +107,23 @@ func (r *router) Any(pattern string, h ...Handler) Route {
 }
 
 func (r *router) Handle(res http.ResponseWriter, req *http.Request, context Context) {
-	for _, route := range r.routes {
-		ok, vals := route.Match(req.Method, req.URL.Path)
-		if ok {
-			params := Params(vals)
-			context.Map(params)
+	matched, vals := r.tree.lookup(r.routes, req.URL.Path)
+	for _, i := range matched {
+		route := r.routes[i]
+		if route.MatchMethod(req.Method) {
+			context.Map(Params(vals[i]))
 			route.Handle(context, res)
 			return
 		}
 	}
 
+	// the path exists for other methods, 405
+	if len(matched) > 0 {
+		res.Header().Set("Allow", strings.Join(r.methods(matched), ","))
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
 	// no routes exist, 404
 	c := &routeContext{context, 0, r.notFounds}
 	context.MapTo(c, (*Context)(nil))
@@ -140,6 +150,7 @@ func (r *router) addRoute(method string, pattern string, handlers []Handler) *ro
 
 	route := newRoute(method, pattern, handlers)
 	route.Validate()
+	route.keys, _ = r.tree.add(len(r.routes), route.pattern)
 	r.routes = append(r.routes, route)
 	return route
 }
@@ -174,10 +185,14 @@ type route struct {
 	handlers []Handler
 	pattern  string
 	name     string
+
+	// keys names the values captured when the route is matched through the
+	// routing tree.
+	keys []string
 }
 
 func newRoute(method string, pattern string, handlers []Handler) *route {
-	route := route{method, nil, handlers, pattern, ""}
+	route := route{method: method, handlers: handlers, pattern: pattern}
 	r := regexp.MustCompile(`:[^/#?()\.\\]+`)
 	pattern = r.ReplaceAllStringFunc(pattern, func(m string) string {
 		return fmt.Sprintf(`(?P<%s>[^/#?]+)`, m[1:])
@@ -202,7 +217,10 @@ func (r route) Match(method string, path string) (bool, map[string]string) {
 	if !r.MatchMethod(method) {
 		return false, nil
 	}
+	return r.matchPath(path)
+}
 
+func (r route) matchPath(path string) (bool, map[string]string) {
 	matches := r.regex.FindStringSubmatch(path)
 	if len(matches) > 0 && matches[0] == path {
 		params := make(map[string]string)
@@ -323,11 +341,16 @@ func hasMethod(methods []string, method string) bool {
 
 // MethodsFor returns all methods available for path
 func (r *router) MethodsFor(path string) []string {
+	matched, _ := r.tree.lookup(r.routes, path)
+	return r.methods(matched)
+}
+
+// methods returns the distinct methods of the given routes, in order.
+func (r *router) methods(matched []int) []string {
 	methods := []string{}
-	for _, route := range r.routes {
-		matches := route.regex.FindStringSubmatch(path)
-		if len(matches) > 0 && matches[0] == path && !hasMethod(methods, route.method) {
-			methods = append(methods, route.method)
+	for _, i := range matched {
+		if !hasMethod(methods, r.routes[i].method) {
+			methods = append(methods, r.routes[i].method)
 		}
 	}
 	return methods
@@ -459,3 +459,49 @@ func Test_ActiveRoute(t *testing.T) {
 	context.MapTo(router, (*Routes)(nil))
 	router.Handle(recorder, req, context)
 }
+
+func Test_MethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+	recorder := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "http://localhost:3000/foo/bar", nil)
+	context := New().createContext(recorder, req)
+
+	router.Get("/foo/:id", func() {})
+	router.Put("/foo/:id", func() {})
+	router.Get("/foo/bar", func() {})
+	router.NotFound(func() {
+		t.Error("NotFound should not be called")
+	})
+
+	router.Handle(recorder, req, context)
+	expect(t, recorder.Code, http.StatusMethodNotAllowed)
+	expect(t, recorder.Header().Get("Allow"), "GET,PUT")
+}
+
+func Test_RouteOrdering(t *testing.T) {
+	router := NewRouter()
+	result := ""
+	router.Get("/users/**", func(params Params) {
+		result += "glob:" + params["_1"] + ";"
+	})
+	router.Get("/users/:id", func(params Params) {
+		result += "param:" + params["id"] + ";"
+	})
+	router.Get("/files/:name.json", func(params Params) {
+		result += "json:" + params["name"] + ";"
+	})
+	router.Get("/files/:name", func(params Params) {
+		result += "file:" + params["name"] + ";"
+	})
+	router.Get("/items/(?P<id>[0-9]+)", func(params Params) {
+		result += "item:" + params["id"] + ";"
+	})
+
+	for _, path := range []string{"/users/42", "/users/42/posts/", "/files/a.json", "/files/a.xml", "/items/7/", "/items/x"} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://localhost:3000"+path, nil)
+		router.Handle(recorder, req, New().createContext(recorder, req))
+	}
+	expect(t, result, "glob:42;glob:42/posts/;json:a;file:a.xml;item:7;")
+}
@@ -0,0 +1,163 @@
+package martini
+
+import (
+	stdcontext "context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ShutdownTimeout is how long a shutdown triggered by a signal waits for in-flight requests before giving up.
+var ShutdownTimeout = 30 * time.Second
+
+// CertCheckInterval is how often the certificate files given to RunTLS are checked for changes.
+var CertCheckInterval = 10 * time.Second
+
+// RunTLS runs an HTTPS server on the given host and port, with HTTP/2 enabled. The certificate and key files are
+// reloaded when they change on disk, so renewed certificates are picked up without a restart.
+func (m *Martini) RunTLS(addr, certFile, keyFile string) {
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		m.log().Fatalln(err)
+	}
+
+	srv := &http.Server{Addr: addr, Handler: m, TLSConfig: &tls.Config{GetCertificate: certs.GetCertificate}}
+	m.serve(srv, func() error {
+		return srv.ListenAndServeTLS("", "")
+	})
+}
+
+// Shutdown stops the server started by Run, RunOnAddr or RunTLS. It stops accepting new connections and waits for
+// in-flight requests to finish or for ctx to be done, whichever comes first. The Run method then returns.
+func (m *Martini) Shutdown(ctx stdcontext.Context) error {
+	m.mu.Lock()
+	srv, done := m.server, m.done
+	m.server = nil
+	m.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	defer close(done)
+	return srv.Shutdown(ctx)
+}
+
+// ShutdownOn makes the server shut down gracefully when the process receives one of the given signals.
+// ClassicMartini does this for os.Interrupt and SIGTERM.
+func (m *Martini) ShutdownOn(signals ...os.Signal) {
+	m.mu.Lock()
+	m.signals = signals
+	m.mu.Unlock()
+}
+
+func (m *Martini) log() *log.Logger {
+	return m.Injector.Get(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
+}
+
+func (m *Martini) serve(srv *http.Server, listen func() error) {
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.server, m.done = srv, done
+	signals := m.signals
+	m.mu.Unlock()
+
+	if len(signals) > 0 {
+		stop := m.notify(signals)
+		defer stop()
+	}
+
+	logger := m.log()
+	logger.Printf("listening on %s (%s)\n", srv.Addr, Env)
+	if err := listen(); err != http.ErrServerClosed {
+		logger.Fatalln(err)
+	}
+	<-done
+}
+
+func (m *Martini) notify(signals []os.Signal) func() {
+	c := make(chan os.Signal, 1)
+	quit := make(chan struct{})
+	signal.Notify(c, signals...)
+	go func() {
+		select {
+		case s := <-c:
+			logger := m.log()
+			logger.Printf("received %s, shutting down\n", s)
+			ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), ShutdownTimeout)
+			defer cancel()
+			if err := m.Shutdown(ctx); err != nil {
+				logger.Println(err)
+			}
+		case <-quit:
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(quit)
+	}
+}
+
+// certReloader serves a certificate from files, loading it again when either of them is modified.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) load() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.modTime, r.checked = &cert, modTime, time.Now()
+	return nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate. If reloading fails, the previous certificate is kept.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= CertCheckInterval {
+		r.checked = time.Now()
+		if modTime, err := r.lastModified(); err == nil && !modTime.Equal(r.modTime) {
+			r.load()
+		}
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,124 @@
+package martini
+
+import (
+	stdcontext "context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Martini_Shutdown(t *testing.T) {
+	m := New()
+	started := make(chan bool)
+	release := make(chan bool)
+	m.Action(func(res http.ResponseWriter) {
+		started <- true
+		<-release
+		res.Write([]byte("done"))
+	})
+
+	stopped := make(chan bool)
+	go func() {
+		m.RunOnAddr("127.0.0.1:8091")
+		stopped <- true
+	}()
+
+	var res *http.Response
+	requested := make(chan error)
+	go func() {
+		var err error
+		for i := 0; i < 50; i++ {
+			if res, err = http.Get("http://127.0.0.1:8091/"); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		requested <- err
+	}()
+	<-started
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- m.Shutdown(stdcontext.Background())
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("server stopped before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	expect(t, <-requested, nil)
+	expect(t, res.StatusCode, http.StatusOK)
+	expect(t, <-shutdown, nil)
+	<-stopped
+
+	expect(t, m.Shutdown(stdcontext.Background()), nil)
+}
+
+func writeCert(t *testing.T, dir, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func Test_CertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "martini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(d time.Duration) { CertCheckInterval = d }(CertCheckInterval)
+	CertCheckInterval = 0
+
+	writeCert(t, dir, "first")
+	r, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	expect(t, err, nil)
+	cert, _ := r.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	expect(t, leaf.Subject.CommonName, "first")
+
+	writeCert(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "cert.pem"), later, later)
+	cert, _ = r.GetCertificate(nil)
+	leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	expect(t, leaf.Subject.CommonName, "second")
+
+	// a broken pair keeps the previous certificate
+	ioutil.WriteFile(filepath.Join(dir, "key.pem"), []byte("garbage"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "key.pem"), later, later)
+	cert, _ = r.GetCertificate(nil)
+	leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	expect(t, leaf.Subject.CommonName, "second")
+}
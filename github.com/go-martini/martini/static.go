@@ -1,12 +1,21 @@
 package martini
 
 import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // StaticOptions is a struct for specifying configuration options for the martini.Static middleware.
@@ -25,6 +34,10 @@ type StaticOptions struct {
 	Fallback string
 	// Exclude defines a pattern for URLs this handler should never process.
 	Exclude string
+	// CacheSize is the number of bytes of file content kept in memory. Zero disables the cache.
+	CacheSize int64
+	// CacheMaxFileSize is the size of the largest file kept in the cache. Defaults to 64KB.
+	CacheMaxFileSize int64
 }
 
 func prepareStaticOptions(options []StaticOptions) StaticOptions {
@@ -37,6 +50,9 @@ func prepareStaticOptions(options []StaticOptions) StaticOptions {
 	if len(opt.IndexFile) == 0 {
 		opt.IndexFile = "index.html"
 	}
+	if opt.CacheMaxFileSize == 0 {
+		opt.CacheMaxFileSize = 64 << 10
+	}
 	// Normalize the prefix if provided
 	if opt.Prefix != "" {
 		// Ensure we have a leading '/'
@@ -50,12 +66,15 @@ func prepareStaticOptions(options []StaticOptions) StaticOptions {
 }
 
 // Static returns a middleware handler that serves static files in the given directory.
+// Files get an ETag derived from their content. A file.gz sibling, if present, is served in place of file to
+// clients that accept gzip.
 func Static(directory string, staticOpt ...StaticOptions) Handler {
 	if !filepath.IsAbs(directory) {
 		directory = filepath.Join(Root, directory)
 	}
 	dir := http.Dir(directory)
 	opt := prepareStaticOptions(staticOpt)
+	cache := newStaticCache(opt.CacheSize, opt.CacheMaxFileSize)
 
 	return func(res http.ResponseWriter, req *http.Request, log *log.Logger) {
 		if req.Method != "GET" && req.Method != "HEAD" {
@@ -130,6 +149,120 @@ func Static(directory string, staticOpt ...StaticOptions) Handler {
 			res.Header().Set("Expires", opt.Expires())
 		}
 
-		http.ServeContent(res, req, file, fi.ModTime(), f)
+		// Prefer a precompressed sibling
+		name := file
+		if gz, err := dir.Open(file + ".gz"); err == nil {
+			defer gz.Close()
+			if gzi, err := gz.Stat(); err == nil && !gzi.IsDir() {
+				res.Header().Add("Vary", "Accept-Encoding")
+				if acceptsEncoding(req.Header.Get("Accept-Encoding"), "gzip") {
+					res.Header().Set("Content-Encoding", "gzip")
+					name, f, fi = file+".gz", gz, gzi
+				}
+			}
+		}
+
+		etag, content, err := cache.get(name, fi, f)
+		if err != nil {
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if res.Header().Get("ETag") == "" {
+			res.Header().Set("ETag", etag)
+		}
+		http.ServeContent(res, req, file, fi.ModTime(), content)
+	}
+}
+
+// staticEntry is what the static cache knows about a file. data is only kept for files small enough to cache.
+type staticEntry struct {
+	name    string
+	modTime time.Time
+	size    int64
+	etag    string
+	data    []byte
+	elem    *list.Element
+}
+
+// staticCache remembers the ETag of every file served and the content of the most recently served small ones.
+// Entries are checked against the file's size and modification time on every request.
+type staticCache struct {
+	mu      sync.Mutex
+	entries map[string]*staticEntry
+	lru     *list.List
+	size    int64
+	max     int64
+	maxFile int64
+}
+
+func newStaticCache(max, maxFile int64) *staticCache {
+	return &staticCache{entries: make(map[string]*staticEntry), lru: list.New(), max: max, maxFile: maxFile}
+}
+
+// get returns the ETag of the named file and a reader for its content, which is f itself unless the file is
+// cached.
+func (c *staticCache) get(name string, fi os.FileInfo, f http.File) (string, io.ReadSeeker, error) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	if ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		etag, data := e.etag, e.data
+		if e.elem != nil {
+			c.lru.MoveToFront(e.elem)
+		}
+		c.mu.Unlock()
+		if data != nil {
+			return etag, bytes.NewReader(data), nil
+		}
+		return etag, f, nil
+	}
+	c.mu.Unlock()
+
+	e = &staticEntry{name: name, modTime: fi.ModTime(), size: fi.Size()}
+	h := sha1.New()
+	if c.max > 0 && fi.Size() <= c.maxFile && fi.Size() <= c.max {
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return "", nil, err
+		}
+		h.Write(data)
+		e.data = data
+	} else {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+	}
+	e.etag = `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+
+	c.mu.Lock()
+	c.remove(c.entries[name])
+	c.entries[name] = e
+	if e.data != nil {
+		e.elem = c.lru.PushFront(e)
+		c.size += int64(len(e.data))
+		for c.size > c.max {
+			c.remove(c.lru.Back().Value.(*staticEntry))
+		}
+	}
+	c.mu.Unlock()
+
+	if e.data != nil {
+		return e.etag, bytes.NewReader(e.data), nil
+	}
+	return e.etag, f, nil
+}
+
+func (c *staticCache) remove(e *staticEntry) {
+	if e == nil {
+		return
+	}
+	if e.elem != nil {
+		c.lru.Remove(e.elem)
+		c.size -= int64(len(e.data))
+	}
+	if c.entries[e.name] == e {
+		delete(c.entries, e.name)
 	}
 }
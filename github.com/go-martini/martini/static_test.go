@@ -2,13 +2,16 @@ package martini
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/codegangsta/inject"
 )
@@ -252,3 +255,110 @@ func Test_Static_Redirect(t *testing.T) {
 	expect(t, response.Code, http.StatusFound)
 	expect(t, response.Header().Get("Location"), "/public/?param=foo#bar")
 }
+
+func Test_Static_ETag(t *testing.T) {
+	m := New()
+	m.Use(Static(currentRoot, StaticOptions{SkipLogging: true}))
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/martini.go", nil)
+	response := httptest.NewRecorder()
+	m.ServeHTTP(response, req)
+	expect(t, response.Code, http.StatusOK)
+	etag := response.Header().Get("ETag")
+	refute(t, etag, "")
+
+	req.Header.Set("If-None-Match", etag)
+	response = httptest.NewRecorder()
+	m.ServeHTTP(response, req)
+	expect(t, response.Code, http.StatusNotModified)
+	expect(t, response.Body.Len(), 0)
+}
+
+func Test_Static_Precompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("plain"), 0644)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("compressed"))
+	w.Close()
+	ioutil.WriteFile(filepath.Join(dir, "app.js.gz"), gz.Bytes(), 0644)
+
+	m := New()
+	m.Use(Static(dir, StaticOptions{SkipLogging: true}))
+
+	req, _ := http.NewRequest("GET", "http://localhost:3000/app.js", nil)
+	response := httptest.NewRecorder()
+	m.ServeHTTP(response, req)
+	expect(t, response.Body.String(), "plain")
+	expect(t, response.Header().Get("Content-Encoding"), "")
+	expect(t, response.Header().Get("Vary"), "Accept-Encoding")
+	plainTag := response.Header().Get("ETag")
+
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	response = httptest.NewRecorder()
+	m.ServeHTTP(response, req)
+	expect(t, response.Header().Get("Content-Encoding"), "gzip")
+	expect(t, response.Header().Get("Content-Type"), "text/javascript; charset=utf-8")
+	refute(t, response.Header().Get("ETag"), plainTag)
+	r, err := gzip.NewReader(response.Body)
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(r)
+	expect(t, string(body), "compressed")
+}
+
+func Test_Static_Cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "hot.txt")
+	ioutil.WriteFile(name, []byte("first"), 0644)
+
+	m := New()
+	m.Use(Static(dir, StaticOptions{SkipLogging: true, CacheSize: 1 << 20}))
+
+	get := func() string {
+		req, _ := http.NewRequest("GET", "http://localhost:3000/hot.txt", nil)
+		response := httptest.NewRecorder()
+		m.ServeHTTP(response, req)
+		return response.Body.String()
+	}
+	expect(t, get(), "first")
+	expect(t, get(), "first")
+
+	// a modified file is read again
+	ioutil.WriteFile(name, []byte("second"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(name, later, later)
+	expect(t, get(), "second")
+}
+
+func Test_StaticCache_Eviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newStaticCache(10, 8)
+	fs := http.Dir(dir)
+	for _, name := range []string{"a", "b", "c"} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"1234"), 0644)
+		f, _ := fs.Open("/" + name)
+		fi, _ := f.Stat()
+		_, _, err := c.get("/"+name, fi, f)
+		expect(t, err, nil)
+		f.Close()
+	}
+	expect(t, c.size, int64(10))
+	expect(t, c.lru.Len(), 2)
+	_, ok := c.entries["/a"]
+	expect(t, ok, false)
+}
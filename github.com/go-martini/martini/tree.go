@@ -0,0 +1,213 @@
+package martini
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// paramSegment matches a path segment that is a single named parameter.
+var paramSegment = regexp.MustCompile(`^:[^/#?()\.\\]+$`)
+
+// complexSegment matches a path segment that mixes literal text, dots and
+// named parameters, such as ":id.json" or "v:version".
+var complexSegment = regexp.MustCompile(`^[^()\[\]{}*+?|^$\\]*$`)
+
+// node is a node of the routing tree. Each level of the tree matches a single
+// segment of the request path.
+type node struct {
+	static  map[string]*node
+	param   *node
+	complex []*complexNode
+	glob    *node
+
+	// routes holds the indexes of the routes whose pattern ends at this node.
+	routes []int
+}
+
+type complexNode struct {
+	source string
+	regex  *regexp.Regexp
+	*node
+}
+
+func newNode() *node {
+	return &node{static: make(map[string]*node)}
+}
+
+// tree routes requests to the routes registered in a router. Patterns that
+// cannot be split into segments, such as those holding arbitrary regular
+// expressions, are kept aside and matched one by one as before.
+type tree struct {
+	root   *node
+	linear []int
+}
+
+func newTree() *tree {
+	return &tree{root: newNode()}
+}
+
+// add inserts the route with the given index. It returns the names of the
+// parameters captured along the way, in the order they are matched, and
+// false if the pattern has to be matched with its regular expression instead.
+func (t *tree) add(index int, pattern string) ([]string, bool) {
+	segments, ok := splitPattern(pattern)
+	if !ok {
+		t.linear = append(t.linear, index)
+		return nil, false
+	}
+
+	var keys []string
+	globs := 0
+	n := t.root
+	for _, s := range segments {
+		switch {
+		case s == "**":
+			globs++
+			keys = append(keys, fmt.Sprintf("_%d", globs))
+			if n.glob == nil {
+				n.glob = newNode()
+			}
+			n = n.glob
+		case paramSegment.MatchString(s):
+			keys = append(keys, s[1:])
+			if n.param == nil {
+				n.param = newNode()
+			}
+			n = n.param
+		case strings.Contains(s, ":") || strings.Contains(s, "."):
+			child := n.complexChild(s)
+			for _, name := range child.regex.SubexpNames()[1:] {
+				keys = append(keys, name)
+			}
+			n = child.node
+		default:
+			child, ok := n.static[s]
+			if !ok {
+				child = newNode()
+				n.static[s] = child
+			}
+			n = child
+		}
+	}
+	n.routes = append(n.routes, index)
+	return keys, true
+}
+
+func (n *node) complexChild(segment string) *complexNode {
+	for _, c := range n.complex {
+		if c.source == segment {
+			return c
+		}
+	}
+	r := regexp.MustCompile(`:[^/#?()\.\\]+`)
+	expr := r.ReplaceAllStringFunc(segment, func(m string) string {
+		return fmt.Sprintf(`(?P<%s>[^/#?]+)`, m[1:])
+	})
+	c := &complexNode{segment, regexp.MustCompile(`^` + expr + `$`), newNode()}
+	n.complex = append(n.complex, c)
+	return c
+}
+
+// splitPattern splits a pattern into its path segments. It reports false if
+// the pattern does not start with a slash or if any of its segments is more
+// than literal text, named parameters and globs.
+func splitPattern(pattern string) ([]string, bool) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, false
+	}
+	segments := strings.Split(pattern[1:], "/")
+	for _, s := range segments {
+		if s == "**" || paramSegment.MatchString(s) {
+			continue
+		}
+		if strings.Contains(s, "**") || !complexSegment.MatchString(s) {
+			return nil, false
+		}
+	}
+	return segments, true
+}
+
+// splitPath splits a request path into its segments. Paths that do not
+// start with a slash have none.
+func splitPath(path string) ([]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	return strings.Split(path[1:], "/"), true
+}
+
+// match calls fn with every node that a route pattern could end at for the
+// given path, along with the values captured on the way there. A single
+// trailing slash is allowed after any pattern.
+func (n *node) match(segments []string, values []string, fn func(*node, []string)) {
+	if len(segments) == 0 || (len(segments) == 1 && segments[0] == "") {
+		fn(n, values)
+	}
+	if len(segments) == 0 {
+		return
+	}
+
+	s := segments[0]
+	if child, ok := n.static[s]; ok {
+		child.match(segments[1:], values, fn)
+	}
+	if n.param != nil && s != "" && !strings.ContainsAny(s, "#?") {
+		n.param.match(segments[1:], appendValue(values, s), fn)
+	}
+	for _, c := range n.complex {
+		if m := c.regex.FindStringSubmatch(s); m != nil {
+			c.match(segments[1:], appendValue(values, m[1:]...), fn)
+		}
+	}
+	if n.glob != nil {
+		end := 0
+		for end < len(segments) && !strings.ContainsAny(segments[end], "#?") {
+			end++
+		}
+		for i := end; i > 0; i-- {
+			n.glob.match(segments[i:], appendValue(values, strings.Join(segments[:i], "/")), fn)
+		}
+	}
+}
+
+// appendValue appends to a copy of values so that sibling branches never
+// share a backing array.
+func appendValue(values []string, v ...string) []string {
+	out := make([]string, len(values), len(values)+len(v))
+	copy(out, values)
+	return append(out, v...)
+}
+
+// lookup returns the indexes of the routes matching path, regardless of
+// method, in the order they were added, with the parameters each captured.
+func (t *tree) lookup(routes []*route, path string) ([]int, map[int]map[string]string) {
+	params := make(map[int]map[string]string)
+	if segments, ok := splitPath(path); ok {
+		t.root.match(segments, nil, func(n *node, values []string) {
+			for _, i := range n.routes {
+				if _, seen := params[i]; seen {
+					continue
+				}
+				p := make(map[string]string, len(values))
+				for k, name := range routes[i].keys {
+					p[name] = values[k]
+				}
+				params[i] = p
+			}
+		})
+	}
+	for _, i := range t.linear {
+		if ok, p := routes[i].matchPath(path); ok {
+			params[i] = p
+		}
+	}
+
+	matched := make([]int, 0, len(params))
+	for i := range params {
+		matched = append(matched, i)
+	}
+	sort.Ints(matched)
+	return matched, params
+}
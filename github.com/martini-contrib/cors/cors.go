@@ -20,7 +20,10 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/go-martini/martini"
 )
 
 const (
@@ -34,12 +37,12 @@ const (
 	headerOrigin         = "Origin"
 	headerRequestMethod  = "Access-Control-Request-Method"
 	headerRequestHeaders = "Access-Control-Request-Headers"
+	headerVary           = "Vary"
 )
 
 var (
 	defaultAllowHeaders = []string{"Origin", "Accept", "Content-Type", "Authorization"}
-	// Regex patterns are generated from AllowOrigins. These are used and generated internally.
-	allowOriginPatterns = []string{}
+	corsHeaders         = []string{headerAllowOrigin, headerAllowCredentials, headerAllowHeaders, headerAllowMethods, headerExposeHeaders, headerMaxAge}
 )
 
 // Options represents Access Control options.
@@ -48,6 +51,9 @@ type Options struct {
 	AllowAllOrigins bool
 	// A list of allowed origins. Wild cards and FQDNs are supported.
 	AllowOrigins []string
+	// If set, it is called for origins not matched by AllowOrigins and
+	// allows them when it returns true.
+	AllowOriginFunc func(origin string) bool
 	// If set, allows to share auth credentials such as cookies.
 	AllowCredentials bool
 	// A list of allowed HTTP methods.
@@ -58,6 +64,11 @@ type Options struct {
 	ExposeHeaders []string
 	// Max age of the CORS headers.
 	MaxAge time.Duration
+
+	// Regex patterns generated from AllowOrigins by Allow.
+	allowOriginPatterns []*regexp.Regexp
+	// Routes with their own options, registered by Attach.
+	attached *attachedRoutes
 }
 
 // Header converts options into CORS headers.
@@ -70,11 +81,7 @@ func (o *Options) Header(origin string) (headers map[string]string) {
 	}
 
 	// add allow origin
-	if o.AllowAllOrigins {
-		headers[headerAllowOrigin] = "*"
-	} else {
-		headers[headerAllowOrigin] = origin
-	}
+	o.setAllowOrigin(headers, origin)
 
 	// add allow credentials
 	headers[headerAllowCredentials] = strconv.FormatBool(o.AllowCredentials)
@@ -131,11 +138,7 @@ func (o *Options) PreflightHeader(origin, rMethod, rHeaders string) (headers map
 
 	headers[headerAllowCredentials] = strconv.FormatBool(o.AllowCredentials)
 	// add allow origin
-	if o.AllowAllOrigins {
-		headers[headerAllowOrigin] = "*"
-	} else {
-		headers[headerAllowOrigin] = origin
-	}
+	o.setAllowOrigin(headers, origin)
 
 	// add allowed headers
 	if len(allowed) > 0 {
@@ -153,16 +156,117 @@ func (o *Options) PreflightHeader(origin, rMethod, rHeaders string) (headers map
 	return
 }
 
+// setAllowOrigin adds the allow origin header. The wildcard is only used
+// when credentials are not shared, as browsers reject it otherwise.
+func (o *Options) setAllowOrigin(headers map[string]string, origin string) {
+	if o.AllowAllOrigins && !o.AllowCredentials {
+		headers[headerAllowOrigin] = "*"
+	} else if origin != "" {
+		headers[headerAllowOrigin] = origin
+	}
+}
+
+// variesByOrigin tells if the response headers depend on the request origin.
+func (o *Options) variesByOrigin() bool {
+	return !o.AllowAllOrigins || o.AllowCredentials
+}
+
 // IsOriginAllowed looks up if the origin matches one of the patterns
-// generated from Options.AllowOrigins patterns.
+// generated from Options.AllowOrigins patterns, or is accepted by
+// Options.AllowOriginFunc.
 func (o *Options) IsOriginAllowed(origin string) (allowed bool) {
-	for _, pattern := range allowOriginPatterns {
-		allowed, _ = regexp.MatchString(pattern, origin)
-		if allowed {
-			return
+	patterns := o.allowOriginPatterns
+	if patterns == nil {
+		patterns = originPatterns(o.AllowOrigins)
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(origin) {
+			return true
 		}
 	}
-	return
+	return o.AllowOriginFunc != nil && o.AllowOriginFunc(origin)
+}
+
+// originPatterns converts origins with wild cards into regex patterns.
+func originPatterns(origins []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(origins))
+	for _, origin := range origins {
+		pattern := regexp.QuoteMeta(origin)
+		pattern = strings.Replace(pattern, "\\*", ".*", -1)
+		pattern = strings.Replace(pattern, "\\?", ".", -1)
+		patterns = append(patterns, regexp.MustCompile("^"+pattern+"$"))
+	}
+	return patterns
+}
+
+// Attach applies the provided options to the routes registered for pattern
+// only. It registers an OPTIONS route for pattern to answer preflight
+// requests, and returns the handler to put in front of the other handlers
+// of those routes. Its headers replace those set by an app-wide Allow; use
+// Options.Attach on the app-wide options instead when there is one, so that
+// it leaves preflight requests for pattern to the OPTIONS route.
+//
+//	books := cors.Attach(m, "/api/books", &cors.Options{AllowOrigins: []string{"https://*.foo.com"}})
+//	m.Put("/api/books", books, putBook)
+func Attach(r martini.Router, pattern string, opts *Options) http.HandlerFunc {
+	_, h := attach(r, pattern, opts)
+	return h
+}
+
+// Attach is like the Attach function, for an app that also uses Allow with
+// o. The handler Allow returned for o then skips the preflight requests the
+// attached route answers.
+//
+//	app := &cors.Options{AllowAllOrigins: true}
+//	m.Use(cors.Allow(app))
+//	m.Patch("/api/books/:id", app.Attach(m, "/api/books/:id", &cors.Options{AllowMethods: []string{"PATCH"}}), patchBook)
+func (o *Options) Attach(r martini.Router, pattern string, opts *Options) http.HandlerFunc {
+	route, h := attach(r, pattern, opts)
+	if o.attached == nil {
+		o.attached = new(attachedRoutes)
+	}
+	o.attached.Lock()
+	o.attached.routes = append(o.attached.routes, route)
+	o.attached.Unlock()
+	return h
+}
+
+func attach(r martini.Router, pattern string, opts *Options) (martini.Route, http.HandlerFunc) {
+	h := Allow(opts)
+	route := r.Options(pattern, h)
+	return route, func(res http.ResponseWriter, req *http.Request) {
+		for _, key := range corsHeaders {
+			res.Header().Del(key)
+		}
+		h(res, req)
+	}
+}
+
+// attachedRoutes holds the OPTIONS routes registered by Options.Attach.
+type attachedRoutes struct {
+	sync.RWMutex
+	routes []martini.Route
+}
+
+// has tells if a preflight request is for one of the routes.
+func (a *attachedRoutes) has(req *http.Request) bool {
+	if a == nil {
+		return false
+	}
+	a.RLock()
+	defer a.RUnlock()
+	for _, route := range a.routes {
+		if m, ok := route.(interface {
+			Match(method, path string) (bool, map[string]string)
+		}); ok {
+			if matched, _ := m.Match(req.Method, req.URL.Path); matched {
+				return true
+			}
+		} else if route.Pattern() == req.URL.Path {
+			return true
+		}
+	}
+	return false
 }
 
 // Allow enables CORS for requests those match the provided options.
@@ -171,12 +275,9 @@ func Allow(opts *Options) http.HandlerFunc {
 	if len(opts.AllowHeaders) == 0 {
 		opts.AllowHeaders = defaultAllowHeaders
 	}
-
-	for _, origin := range opts.AllowOrigins {
-		pattern := regexp.QuoteMeta(origin)
-		pattern = strings.Replace(pattern, "\\*", ".*", -1)
-		pattern = strings.Replace(pattern, "\\?", ".", -1)
-		allowOriginPatterns = append(allowOriginPatterns, "^"+pattern+"$")
+	opts.allowOriginPatterns = originPatterns(opts.AllowOrigins)
+	if opts.attached == nil {
+		opts.attached = new(attachedRoutes)
 	}
 
 	return func(res http.ResponseWriter, req *http.Request) {
@@ -189,8 +290,17 @@ func Allow(opts *Options) http.HandlerFunc {
 			headers map[string]string
 		)
 
-		if req.Method == "OPTIONS" &&
-			(requestedMethod != "" || requestedHeaders != "") {
+		preflight := req.Method == "OPTIONS" &&
+			(requestedMethod != "" || requestedHeaders != "")
+		if preflight && opts.attached.has(req) {
+			return
+		}
+
+		if opts.variesByOrigin() {
+			res.Header().Add(headerVary, headerOrigin)
+		}
+
+		if preflight {
 			// TODO: if preflight, respond with exact headers if allowed
 			headers = opts.PreflightHeader(origin, requestedMethod, requestedHeaders)
 			for key, value := range headers {
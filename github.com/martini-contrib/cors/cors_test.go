@@ -210,6 +210,193 @@ func Test_Preflight(t *testing.T) {
 	}
 }
 
+func Test_OptionsAreIndependent(t *testing.T) {
+	foo := Allow(&Options{AllowOrigins: []string{"https://*.foo.com"}})
+	bar := Allow(&Options{AllowOrigins: []string{"https://*.bar.com"}})
+
+	recorder := httptest.NewRecorder()
+	r, _ := http.NewRequest("PUT", "foo", nil)
+	r.Header.Add("Origin", "https://www.bar.com")
+	foo(recorder, r)
+
+	if headerValue := recorder.HeaderMap.Get(headerAllowOrigin); headerValue != "" {
+		t.Errorf("Allow-Origin header should not exist, found %v", headerValue)
+	}
+
+	recorder = httptest.NewRecorder()
+	bar(recorder, r)
+	if headerValue := recorder.HeaderMap.Get(headerAllowOrigin); headerValue != "https://www.bar.com" {
+		t.Errorf("Allow-Origin header should be https://www.bar.com, found %v", headerValue)
+	}
+}
+
+func Test_AllowOriginFunc(t *testing.T) {
+	m := martini.New()
+	m.Use(Allow(&Options{
+		AllowOrigins: []string{"https://aaa.com"},
+		AllowOriginFunc: func(origin string) bool {
+			return strings.HasSuffix(origin, ".trusted.net")
+		},
+	}))
+
+	for origin, want := range map[string]string{
+		"https://aaa.com":         "https://aaa.com",
+		"https://api.trusted.net": "https://api.trusted.net",
+		"https://evil.com":        "",
+	} {
+		recorder := httptest.NewRecorder()
+		r, _ := http.NewRequest("PUT", "foo", nil)
+		r.Header.Add("Origin", origin)
+		m.ServeHTTP(recorder, r)
+
+		if headerValue := recorder.HeaderMap.Get(headerAllowOrigin); headerValue != want {
+			t.Errorf("Allow-Origin header should be %q for %v, found %q", want, origin, headerValue)
+		}
+		if varyVal := recorder.HeaderMap.Get(headerVary); varyVal != "Origin" {
+			t.Errorf("Vary is expected to be Origin, found %v", varyVal)
+		}
+	}
+}
+
+func Test_AllowAllWithCredentials(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	m := martini.New()
+	m.Use(Allow(&Options{
+		AllowAllOrigins:  true,
+		AllowCredentials: true,
+	}))
+
+	origin := "https://bar.foo.com"
+	r, _ := http.NewRequest("PUT", "foo", nil)
+	r.Header.Add("Origin", origin)
+	m.ServeHTTP(recorder, r)
+
+	if headerValue := recorder.HeaderMap.Get(headerAllowOrigin); headerValue != origin {
+		t.Errorf("Allow-Origin header should be %v, found %v", origin, headerValue)
+	}
+	if varyVal := recorder.HeaderMap.Get(headerVary); varyVal != "Origin" {
+		t.Errorf("Vary is expected to be Origin, found %v", varyVal)
+	}
+}
+
+func Test_Attach(t *testing.T) {
+	m := martini.Classic()
+	books := Attach(m, "/api/books", &Options{
+		AllowOrigins: []string{"https://*.foo.com"},
+		AllowMethods: []string{"PUT"},
+	})
+	m.Put("/api/books", books, func() string {
+		return "ok"
+	})
+
+	recorder := NewRecorder()
+	r, _ := http.NewRequest("OPTIONS", "/api/books", nil)
+	r.Header.Add("Origin", "https://www.foo.com")
+	r.Header.Add(headerRequestMethod, "PUT")
+	m.ServeHTTP(recorder, r)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status code is expected to be 200, found %d", recorder.Code)
+	}
+	if methodsVal := recorder.Header().Get(headerAllowMethods); methodsVal != "PUT" {
+		t.Errorf("Allow-Methods is expected to be PUT, found %v", methodsVal)
+	}
+
+	for origin, want := range map[string]string{
+		"https://www.foo.com": "https://www.foo.com",
+		"https://evil.com":    "",
+	} {
+		recorder := httptest.NewRecorder()
+		m := martini.Classic()
+		m.Use(Allow(&Options{AllowAllOrigins: true}))
+		m.Put("/api/books", Attach(m, "/api/books", &Options{AllowOrigins: []string{"https://*.foo.com"}}), func() string {
+			return "ok"
+		})
+		r, _ := http.NewRequest("PUT", "/api/books", nil)
+		r.Header.Add("Origin", origin)
+		m.ServeHTTP(recorder, r)
+
+		if headerValue := recorder.HeaderMap.Get(headerAllowOrigin); headerValue != want {
+			t.Errorf("Allow-Origin header should be %q for %v, found %q", want, origin, headerValue)
+		}
+	}
+}
+
+func Test_AttachWithAllow(t *testing.T) {
+	m := martini.Classic()
+	app := &Options{
+		AllowAllOrigins: true,
+		AllowMethods:    []string{"GET", "PUT"},
+	}
+	m.Use(Allow(app))
+	m.Patch("/api/books/:id", app.Attach(m, "/api/books/:id", &Options{
+		AllowOrigins: []string{"https://*.foo.com"},
+		AllowMethods: []string{"PATCH"},
+	}), func() string {
+		return "ok"
+	})
+
+	recorder := httptest.NewRecorder()
+	r, _ := http.NewRequest("OPTIONS", "/api/books/1", nil)
+	r.Header.Add("Origin", "https://www.foo.com")
+	r.Header.Add(headerRequestMethod, "PATCH")
+	m.ServeHTTP(recorder, r)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status code is expected to be 200, found %d", recorder.Code)
+	}
+	if methodsVal := recorder.Header().Get(headerAllowMethods); methodsVal != "PATCH" {
+		t.Errorf("Allow-Methods is expected to be PATCH, found %v", methodsVal)
+	}
+	if originVal := recorder.Header().Get(headerAllowOrigin); originVal != "https://www.foo.com" {
+		t.Errorf("Allow-Origin is expected to be https://www.foo.com, found %v", originVal)
+	}
+
+	recorder = httptest.NewRecorder()
+	r, _ = http.NewRequest("OPTIONS", "/api/authors", nil)
+	r.Header.Add("Origin", "https://www.bar.com")
+	r.Header.Add(headerRequestMethod, "PUT")
+	m.ServeHTTP(recorder, r)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status code is expected to be 200, found %d", recorder.Code)
+	}
+	if methodsVal := recorder.Header().Get(headerAllowMethods); methodsVal != "GET,PUT" {
+		t.Errorf("Allow-Methods is expected to be GET,PUT, found %v", methodsVal)
+	}
+}
+
+func Test_AttachIsPerApp(t *testing.T) {
+	a := martini.Classic()
+	appA := &Options{AllowAllOrigins: true, AllowMethods: []string{"GET"}}
+	a.Use(Allow(appA))
+	a.Put("/x", appA.Attach(a, "/x", &Options{AllowOrigins: []string{"https://a.com"}, AllowMethods: []string{"PUT"}}), func() string {
+		return "ok"
+	})
+
+	b := martini.Classic()
+	b.Use(Allow(&Options{AllowAllOrigins: true, AllowMethods: []string{"PUT"}}))
+	b.Put("/x", func() string {
+		return "ok"
+	})
+
+	recorder := httptest.NewRecorder()
+	r, _ := http.NewRequest("OPTIONS", "/x", nil)
+	r.Header.Add("Origin", "https://b.com")
+	r.Header.Add(headerRequestMethod, "PUT")
+	b.ServeHTTP(recorder, r)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status code is expected to be 200, found %d", recorder.Code)
+	}
+	if methodsVal := recorder.Header().Get(headerAllowMethods); methodsVal != "PUT" {
+		t.Errorf("Allow-Methods is expected to be PUT, found %v", methodsVal)
+	}
+	if originVal := recorder.Header().Get(headerAllowOrigin); originVal != "*" {
+		t.Errorf("Allow-Origin is expected to be *, found %v", originVal)
+	}
+}
+
 func Benchmark_WithoutCORS(b *testing.B) {
 	recorder := httptest.NewRecorder()
 	m := martini.New()
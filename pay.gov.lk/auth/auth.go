@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"duov6.com/gorest"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Realm is the gorest realm every pay.gov.lk service is registered in.
+const Realm = "paygov"
+
+const (
+	RolePayer   = "payer"
+	RoleBank    = "bank-officer"
+	RoleCustoms = "customs-officer"
+	RoleAdmin   = "admin"
+)
+
+var ErrInvalidToken = errors.New("session token is not valid")
+
+// Session is what a validated token resolves to. It is handed to the
+// services as their gorest.SessionData.
+type Session struct {
+	Token    string
+	UserID   string
+	Username string
+	Domain   string
+	Roles    []string
+	Expires  time.Time
+}
+
+// SessionId names the user rather than the token, so that it can be written
+// to the audit trail without leaking a credential.
+func (s *Session) SessionId() string {
+	if s.Username != "" {
+		return s.Username
+	}
+	return s.UserID
+}
+
+// HasRole reports whether the session holds any of the comma-separated roles
+// in role. Admins hold every role, and an empty role only requires a valid
+// session.
+func (s *Session) HasRole(role string) bool {
+	if role == "" {
+		return true
+	}
+	for _, have := range s.Roles {
+		if have == RoleAdmin {
+			return true
+		}
+		for _, want := range strings.Split(role, ",") {
+			if have == strings.TrimSpace(want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validator resolves a session token to the session it belongs to.
+type Validator interface {
+	Validate(token string) (*Session, error)
+}
+
+// Authorizer adapts v to gorest, for use with gorest.RegisterRealmAuthorizer.
+// The role tag of an endpoint may list several roles separated by commas.
+func Authorizer(v Validator) gorest.Authorizer {
+	return func(token string, role string) (bool, bool, gorest.SessionData) {
+		s, err := v.Validate(token)
+		if err != nil {
+			return false, false, nil
+		}
+		return true, s.HasRole(role), s
+	}
+}
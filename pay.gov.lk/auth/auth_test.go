@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthorizer(t *testing.T) {
+	l := NewLocalIssuer()
+	l.AddUser("payer", "p", RolePayer)
+	l.AddUser("officer", "o", RoleBank, RoleCustoms)
+	l.AddUser("admin", "a", RoleAdmin)
+	l.AddUser("nobody", "n")
+	authorize := Authorizer(l)
+
+	login := func(user, password string) string {
+		s, err := l.Login(user, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Token
+	}
+	payer, officer, admin, nobody := login("payer", "p"), login("officer", "o"), login("admin", "a"), login("nobody", "n")
+
+	tests := []struct {
+		name          string
+		token         string
+		role          string
+		valid, inRole bool
+	}{
+		{"no token", "", "", false, false},
+		{"unknown token", "0123", RolePayer, false, false},
+		{"any session", nobody, "", true, true},
+		{"missing role", nobody, RolePayer, true, false},
+		{"own role", payer, RolePayer, true, true},
+		{"other role", payer, RoleBank, true, false},
+		{"one of several", payer, "payer,bank-officer,customs-officer", true, true},
+		{"spaced list", officer, "payer, customs-officer", true, true},
+		{"none of several", payer, "bank-officer,customs-officer", true, false},
+		{"admin holds every role", admin, RoleBank, true, true},
+		{"admin-only endpoint", officer, RoleAdmin, true, false},
+	}
+	for _, tt := range tests {
+		valid, inRole, data := authorize(tt.token, tt.role)
+		if valid != tt.valid || inRole != tt.inRole {
+			t.Errorf("%s: Authorizer = %v, %v; want %v, %v", tt.name, valid, inRole, tt.valid, tt.inRole)
+		}
+		if valid && data.SessionId() == "" {
+			t.Errorf("%s: session has no user", tt.name)
+		}
+	}
+
+	l.Revoke(payer)
+	if valid, _, _ := authorize(payer, RolePayer); valid {
+		t.Error("a revoked token is still valid")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	l := NewLocalIssuer()
+	l.AddUser("officer", "secret", RoleBank)
+
+	tests := []struct {
+		user, password string
+		err            error
+	}{
+		{"officer", "secret", nil},
+		{"officer", "Secret", ErrInvalidCredentials},
+		{"officer", "", ErrInvalidCredentials},
+		{"admin", "secret", ErrInvalidCredentials},
+		{"admin", "", ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		s, err := l.Login(tt.user, tt.password)
+		if err != tt.err {
+			t.Errorf("Login(%q, %q) = %v; want %v", tt.user, tt.password, err, tt.err)
+			continue
+		}
+		if err == nil && (len(s.Roles) != 1 || s.Roles[0] != RoleBank || s.Username != "officer") {
+			t.Errorf("Login(%q) started %+v", tt.user, s)
+		}
+	}
+
+	l.TTL = -time.Second
+	s, err := l.Login("officer", "secret")
+	if err != ErrInvalidToken || s != nil {
+		t.Errorf("Login with an expired TTL = %v, %v; want ErrInvalidToken", s, err)
+	}
+}
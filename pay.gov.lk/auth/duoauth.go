@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certificate is the part of the DuoAuth AuthCertificate that is used here.
+// Roles are read from Otherdata["Roles"] as a comma-separated list.
+type certificate struct {
+	UserID        string
+	Username      string
+	SecurityToken string
+	Domain        string
+	Otherdata     map[string]string
+}
+
+// DuoAuthValidator checks tokens against the DuoAuth GetSession endpoint.
+// Valid sessions are cached for TTL so that every request does not cost a
+// round trip. At most MaxEntries sessions are kept; expired ones are dropped
+// first when the cache is full.
+type DuoAuthValidator struct {
+	BaseURL    string
+	Domain     string
+	TTL        time.Duration
+	MaxEntries int
+	Client     *http.Client
+
+	mu    sync.Mutex
+	cache map[string]*Session
+}
+
+func NewDuoAuthValidator(baseURL, domain string) *DuoAuthValidator {
+	return &DuoAuthValidator{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Domain:     domain,
+		TTL:        time.Minute,
+		MaxEntries: 10000,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		cache:      make(map[string]*Session),
+	}
+}
+
+func (v *DuoAuthValidator) Validate(token string) (*Session, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+
+	v.mu.Lock()
+	s, ok := v.cache[token]
+	if ok && !now.Before(s.Expires) {
+		delete(v.cache, token)
+		ok = false
+	}
+	v.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	s, err := v.fetch(token)
+	if err != nil {
+		v.mu.Lock()
+		delete(v.cache, token)
+		v.mu.Unlock()
+		return nil, err
+	}
+	s.Expires = now.Add(v.TTL)
+
+	v.mu.Lock()
+	v.makeRoom(now)
+	v.cache[token] = s
+	v.mu.Unlock()
+	return s, nil
+}
+
+// makeRoom drops sessions from a full cache, the expired ones first and then
+// arbitrary ones. v.mu must be held.
+func (v *DuoAuthValidator) makeRoom(now time.Time) {
+	if len(v.cache) < v.MaxEntries {
+		return
+	}
+	for token, s := range v.cache {
+		if !now.Before(s.Expires) {
+			delete(v.cache, token)
+		}
+	}
+	for token := range v.cache {
+		if len(v.cache) < v.MaxEntries {
+			break
+		}
+		delete(v.cache, token)
+	}
+}
+
+func (v *DuoAuthValidator) fetch(token string) (*Session, error) {
+	u := v.BaseURL + "/GetSession/" + url.QueryEscape(token) + "/" + url.QueryEscape(v.Domain)
+	res, err := v.Client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode >= 500:
+		return nil, fmt.Errorf("DuoAuth returned %s", res.Status)
+	default:
+		return nil, ErrInvalidToken
+	}
+
+	var c certificate
+	if err := json.NewDecoder(res.Body).Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.UserID == "" || c.SecurityToken != token {
+		return nil, ErrInvalidToken
+	}
+
+	s := &Session{Token: token, UserID: c.UserID, Username: c.Username, Domain: c.Domain}
+	for _, r := range strings.Split(c.Otherdata["Roles"], ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			s.Roles = append(s.Roles, r)
+		}
+	}
+	return s, nil
+}
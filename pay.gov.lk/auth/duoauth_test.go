@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newDuoAuth serves GetSession for every token starting with "ok", with the
+// roles payer and bank-officer, and counts the calls.
+func newDuoAuth() (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 3 || parts[1] != "GetSession" || !strings.HasPrefix(parts[2], "ok") {
+			http.Error(w, "no session", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"UserID":"u1","Username":"officer","SecurityToken":%q,"Domain":"pay.gov.lk","Otherdata":{"Roles":"payer, bank-officer"}}`, parts[2])
+	}))
+	return srv, &calls
+}
+
+func TestDuoAuthValidate(t *testing.T) {
+	srv, calls := newDuoAuth()
+	defer srv.Close()
+	v := NewDuoAuthValidator(srv.URL+"/", "pay.gov.lk")
+
+	tests := []struct {
+		token string
+		role  string
+		err   error
+		ok    bool
+	}{
+		{"", "", ErrInvalidToken, false},
+		{"bad", "", ErrInvalidToken, false},
+		{"ok1", RolePayer, nil, true},
+		{"ok1", RoleBank, nil, true},
+		{"ok1", RoleAdmin, nil, false},
+	}
+	for _, tt := range tests {
+		s, err := v.Validate(tt.token)
+		if err != tt.err {
+			t.Errorf("Validate(%q) = %v; want %v", tt.token, err, tt.err)
+			continue
+		}
+		if err == nil && s.HasRole(tt.role) != tt.ok {
+			t.Errorf("Validate(%q).HasRole(%q) = %v; want %v", tt.token, tt.role, !tt.ok, tt.ok)
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("DuoAuth was called %d times; want 2, the valid session cached", n)
+	}
+}
+
+func TestDuoAuthCache(t *testing.T) {
+	srv, calls := newDuoAuth()
+	defer srv.Close()
+	v := NewDuoAuthValidator(srv.URL, "pay.gov.lk")
+	v.TTL = 50 * time.Millisecond
+	v.MaxEntries = 3
+
+	for i := 0; i < 10; i++ {
+		if _, err := v.Validate(fmt.Sprintf("ok%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(v.cache); n > v.MaxEntries {
+		t.Errorf("cache holds %d sessions; want at most %d", n, v.MaxEntries)
+	}
+
+	atomic.StoreInt32(calls, 0)
+	v.Validate("ok9")
+	if n := atomic.LoadInt32(calls); n != 0 {
+		t.Error("the latest session was not cached")
+	}
+	time.Sleep(60 * time.Millisecond)
+	v.Validate("ok9")
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Error("an expired session was served from the cache")
+	}
+
+	// Expired sessions make room before live ones are dropped.
+	v.MaxEntries = 2
+	v.cache = make(map[string]*Session)
+	v.Validate("ok1")
+	time.Sleep(60 * time.Millisecond)
+	v.Validate("ok2")
+	v.Validate("ok3")
+	if _, ok := v.cache["ok1"]; ok || len(v.cache) != 2 {
+		t.Errorf("cache holds %d sessions, the expired one %v", len(v.cache), ok)
+	}
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/go-ini/ini"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config is the typed configuration of the payment service. It is read from
+// a JSON or INI file, then every field with an env tag can be overridden by
+// that environment variable.
+type Config struct {
+	ListenAddr    string `json:"ListenAddr" ini:"ListenAddr" env:"PAYGOV_LISTEN_ADDR"`
+	Https_Enabled bool   `json:"Https_Enabled" ini:"Https_Enabled" env:"PAYGOV_HTTPS_ENABLED"`
+	Certificate   string `json:"Certificate" ini:"Certificate" env:"PAYGOV_CERTIFICATE"`
+	PrivateKey    string `json:"PrivateKey" ini:"PrivateKey" env:"PAYGOV_PRIVATE_KEY"`
+
+	StoreID     string `json:"StoreID" ini:"StoreID" env:"PAYGOV_STORE_ID"`
+	DatabaseDSN string `json:"DatabaseDSN" ini:"DatabaseDSN" env:"PAYGOV_DATABASE_DSN"`
+	DocumentKey string `json:"DocumentKey" ini:"DocumentKey" env:"PAYGOV_DOCUMENT_KEY"`
+	CardKey     string `json:"CardKey" ini:"CardKey" env:"PAYGOV_CARD_KEY"`
+	ReportDir   string `json:"ReportDir" ini:"ReportDir" env:"PAYGOV_REPORT_DIR"`
+
+	Smtpserver   string `json:"Smtpserver" ini:"Smtpserver" env:"PAYGOV_SMTP_SERVER"`
+	Smtpusername string `json:"Smtpusername" ini:"Smtpusername" env:"PAYGOV_SMTP_USERNAME"`
+	Smtppassword string `json:"Smtppassword" ini:"Smtppassword" env:"PAYGOV_SMTP_PASSWORD"`
+	Smtpfrom     string `json:"Smtpfrom" ini:"Smtpfrom" env:"PAYGOV_SMTP_FROM"`
+
+	TwilioAccountSid string `json:"TwilioAccountSid" ini:"TwilioAccountSid" env:"PAYGOV_TWILIO_ACCOUNT_SID"`
+	TwilioAuthToken  string `json:"TwilioAuthToken" ini:"TwilioAuthToken" env:"PAYGOV_TWILIO_AUTH_TOKEN"`
+	TwilioFrom       string `json:"TwilioFrom" ini:"TwilioFrom" env:"PAYGOV_TWILIO_FROM"`
+
+	AuthService string `json:"AuthService" ini:"AuthService" env:"PAYGOV_AUTH_SERVICE"`
+	AuthDomain  string `json:"AuthDomain" ini:"AuthDomain" env:"PAYGOV_AUTH_DOMAIN"`
+	AuthLocal   bool   `json:"AuthLocal" ini:"AuthLocal" env:"PAYGOV_AUTH_LOCAL"`
+	// AuthLocalUsers lists the users of the local token issuer as
+	// user:password:role,role entries separated by semicolons.
+	AuthLocalUsers string `json:"AuthLocalUsers" ini:"AuthLocalUsers" env:"PAYGOV_AUTH_LOCAL_USERS"`
+
+	UserName string `json:"UserName" ini:"UserName" env:"PAYGOV_USERNAME"`
+	Password string `json:"Password" ini:"Password" env:"PAYGOV_PASSWORD"`
+}
+
+// legacyCertificateKey is the misspelt key older config files use for
+// Certificate. It is still read when Certificate is not set.
+const legacyCertificateKey = "Cirtifcate"
+
+func defaults() Config {
+	return Config{ListenAddr: ":4048"}
+}
+
+var (
+	mu        sync.RWMutex
+	path      string
+	current   Config
+	listeners []func(Config)
+)
+
+// Load reads and validates the configuration file at p and makes it the
+// current configuration. Files ending in .ini are read as INI, anything else
+// as JSON.
+func Load(p string) (Config, error) {
+	c, err := read(p)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	mu.Lock()
+	path = p
+	current = c
+	mu.Unlock()
+	return c, nil
+}
+
+// Get returns the current configuration.
+func Get() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// OnChange registers f to be called with the new configuration after every
+// successful Reload.
+func OnChange(f func(Config)) {
+	mu.Lock()
+	listeners = append(listeners, f)
+	mu.Unlock()
+}
+
+// Reload re-reads the file given to Load, applies the overrides on top of it
+// (environment variables still win) and, if the result is valid, makes it
+// current. An invalid result is rejected and the old configuration kept.
+func Reload(overrides map[string]interface{}) (Config, error) {
+	mu.RLock()
+	p := path
+	mu.RUnlock()
+
+	c, err := readFile(p)
+	if err != nil {
+		return Config{}, err
+	}
+	if len(overrides) > 0 {
+		b, err := json.Marshal(overrides)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := decodeJSON(b, &c); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := applyEnv(&c); err != nil {
+		return Config{}, err
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	mu.Lock()
+	current = c
+	fs := make([]func(Config), len(listeners))
+	copy(fs, listeners)
+	mu.Unlock()
+
+	for _, f := range fs {
+		f(c)
+	}
+	return c, nil
+}
+
+// Changed returns the names of the fields whose values differ between a and
+// b, in declaration order.
+func Changed(a, b Config) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var names []string
+	for i := 0; i < va.NumField(); i++ {
+		if va.Field(i).Interface() != vb.Field(i).Interface() {
+			names = append(names, va.Type().Field(i).Name)
+		}
+	}
+	return names
+}
+
+func read(p string) (Config, error) {
+	c, err := readFile(p)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := applyEnv(&c); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func readFile(p string) (Config, error) {
+	c := defaults()
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if strings.EqualFold(filepath.Ext(p), ".ini") {
+		f, err := ini.Load(b)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := f.MapTo(&c); err != nil {
+			return Config{}, err
+		}
+		if c.Certificate == "" {
+			c.Certificate = f.Section("").Key(legacyCertificateKey).String()
+		}
+		return c, nil
+	}
+
+	if err := decodeJSON(b, &c); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func decodeJSON(b []byte, c *Config) error {
+	if err := json.Unmarshal(b, c); err != nil {
+		return err
+	}
+	if c.Certificate == "" {
+		var legacy map[string]interface{}
+		if err := json.Unmarshal(b, &legacy); err != nil {
+			return err
+		}
+		if s, ok := legacy[legacyCertificateKey].(string); ok {
+			c.Certificate = s
+		}
+	}
+	return nil
+}
+
+func applyEnv(c *Config) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(s)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return &ValidationError{[]string{name + ": " + err.Error()}}
+			}
+			f.SetBool(b)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const validJSON = `{"ListenAddr":":8080","AuthService":"http://auth.example/","Cirtifcate":"old.pem"}`
+
+func writeConfig(t *testing.T, name, body string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	p := writeConfig(t, "pay.config", validJSON)
+	defer os.RemoveAll(filepath.Dir(p))
+
+	c, err := Load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ListenAddr != ":8080" || c.Certificate != "old.pem" {
+		t.Errorf("Load = %+v; want ListenAddr :8080 and the legacy certificate", c)
+	}
+
+	ini := writeConfig(t, "pay.ini", "AuthService = http://auth.example/\nCirtifcate = old.pem\n")
+	defer os.RemoveAll(filepath.Dir(ini))
+	if c, err = Load(ini); err != nil {
+		t.Fatal(err)
+	}
+	if c.ListenAddr != ":4048" || c.Certificate != "old.pem" {
+		t.Errorf("Load of INI = %+v; want the default ListenAddr and the legacy certificate", c)
+	}
+
+	os.Setenv("PAYGOV_LISTEN_ADDR", ":9090")
+	defer os.Unsetenv("PAYGOV_LISTEN_ADDR")
+	if c, err = Load(p); err != nil || c.ListenAddr != ":9090" {
+		t.Errorf("Load with PAYGOV_LISTEN_ADDR = %q, %v; want :9090", c.ListenAddr, err)
+	}
+	os.Setenv("PAYGOV_HTTPS_ENABLED", "maybe")
+	defer os.Unsetenv("PAYGOV_HTTPS_ENABLED")
+	if _, err = Load(p); err == nil {
+		t.Error("Load accepted a malformed boolean from the environment")
+	}
+}
+
+// The service starts from the config file it ships with, so that file has to
+// pass validation as it is.
+func TestLoadShipped(t *testing.T) {
+	c, err := Load(filepath.Join("..", "service", "Auth.config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Https_Enabled || c.AuthLocal || c.AuthService == "" {
+		t.Errorf("shipped config = %+v; want plain HTTP against DuoAuth", c)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ok := Config{ListenAddr: ":4048", AuthService: "http://auth.example/"}
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		problem string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"listen address", func(c *Config) { c.ListenAddr = "4048" }, "ListenAddr"},
+		{"https without keys", func(c *Config) { c.Https_Enabled = true }, "Https_Enabled requires"},
+		{"https with missing keys", func(c *Config) { c.Https_Enabled, c.Certificate, c.PrivateKey = true, "y", "y" }, "Certificate/PrivateKey"},
+		{"no auth", func(c *Config) { c.AuthService = "" }, "AuthService is required"},
+		{"relative auth", func(c *Config) { c.AuthService = "auth" }, "absolute URL"},
+		{"smtp", func(c *Config) { c.Smtpserver = "mail:25" }, "Smtpfrom"},
+		{"twilio", func(c *Config) { c.TwilioAccountSid = "sid" }, "TwilioAuthToken"},
+		{"document key", func(c *Config) { c.DocumentKey = "short" }, "DocumentKey"},
+		{"card key", func(c *Config) { c.CardKey = "short" }, "CardKey"},
+		{"database without card key", func(c *Config) { c.DatabaseDSN = "pay@/pay" }, "DatabaseDSN requires CardKey"},
+	}
+	for _, tt := range tests {
+		c := ok
+		tt.change(&c)
+		err := c.Validate()
+		if tt.problem == "" {
+			if err != nil {
+				t.Errorf("%s: Validate = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.problem) {
+			t.Errorf("%s: Validate = %v; want a problem with %q", tt.name, err, tt.problem)
+		}
+	}
+
+	c := Config{ListenAddr: "x", DocumentKey: "short"}
+	if err, ok := c.Validate().(*ValidationError); !ok || len(err.Problems) != 3 {
+		t.Errorf("Validate = %v; want all three problems", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	p := writeConfig(t, "pay.config", validJSON)
+	defer os.RemoveAll(filepath.Dir(p))
+	if _, err := Load(p); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []Config
+	OnChange(func(c Config) { seen = append(seen, c) })
+
+	c, err := Reload(map[string]interface{}{"DocumentKey": "0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Get().DocumentKey != "0123456789abcdef" || len(seen) != 1 || seen[0] != c {
+		t.Errorf("Reload did not apply the override: current %+v, listeners saw %d", Get(), len(seen))
+	}
+
+	if _, err := Reload(map[string]interface{}{"DocumentKey": "short"}); err == nil {
+		t.Error("Reload accepted an invalid configuration")
+	}
+	if Get().DocumentKey != "0123456789abcdef" || len(seen) != 1 {
+		t.Error("a rejected Reload replaced the configuration")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	a := Config{ListenAddr: ":4048", DocumentKey: "one"}
+	b := a
+	if got := Changed(a, b); len(got) != 0 {
+		t.Errorf("Changed of equal configs = %v", got)
+	}
+	b.ListenAddr, b.Https_Enabled, b.DocumentKey = ":443", true, "two"
+	if got, want := Changed(a, b), []string{"ListenAddr", "Https_Enabled", "DocumentKey"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed = %v; want %v", got, want)
+	}
+}
+
+func TestValidateLocalAuth(t *testing.T) {
+	defer func(allowed bool) { allowLocalAuth = allowed }(allowLocalAuth)
+
+	c := Config{ListenAddr: ":4048", AuthLocal: true, AuthLocalUsers: "officer:secret:bank-officer"}
+	allowLocalAuth = false
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "builds tagged dev") {
+		t.Errorf("Validate outside a dev build = %v; want AuthLocal refused", err)
+	}
+
+	allowLocalAuth = true
+	tests := []struct {
+		users   string
+		problem string
+	}{
+		{"officer:secret:bank-officer", ""},
+		{"", "requires AuthLocalUsers"},
+		{"officer:secret", "user:password:role"},
+		{"officer::admin", "user:password:role"},
+	}
+	for _, tt := range tests {
+		c.AuthLocalUsers = tt.users
+		err := c.Validate()
+		if tt.problem == "" && err != nil || tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)) {
+			t.Errorf("Validate with users %q = %v; want %q", tt.users, err, tt.problem)
+		}
+	}
+}
+
+func TestLocalUsers(t *testing.T) {
+	c := Config{AuthLocalUsers: "payer:p:payer; officer:o:bank-officer, customs-officer;nobody:n:;"}
+	got, err := c.LocalUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LocalUser{
+		{"payer", "p", []string{"payer"}},
+		{"officer", "o", []string{"bank-officer", "customs-officer"}},
+		{"nobody", "n", nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LocalUsers = %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// allowLocalAuth is set in builds tagged dev. Other builds refuse AuthLocal,
+// so that a production config cannot turn on the local token issuer.
+var allowLocalAuth = false
+
+// LocalUser is one entry of AuthLocalUsers.
+type LocalUser struct {
+	Name     string
+	Password string
+	Roles    []string
+}
+
+var errLocalUsers = errors.New("AuthLocalUsers entries must be user:password:role,role")
+
+// LocalUsers parses AuthLocalUsers.
+func (c Config) LocalUsers() ([]LocalUser, error) {
+	var users []LocalUser
+	for _, entry := range strings.Split(c.AuthLocalUsers, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, errLocalUsers
+		}
+		u := LocalUser{Name: parts[0], Password: parts[1]}
+		for _, r := range strings.Split(parts[2], ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				u.Roles = append(u.Roles, r)
+			}
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
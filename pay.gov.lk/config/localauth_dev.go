@@ -0,0 +1,8 @@
+//go:build dev
+// +build dev
+
+package config
+
+func init() {
+	allowLocalAuth = true
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+type certCache struct {
+	mu       sync.Mutex
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+var certs certCache
+
+// TLSConfig returns a tls.Config that always serves the certificate named by
+// the current configuration. The files are loaded again whenever their paths
+// change on Reload or the certificate file is replaced on disk, so renewed
+// certificates are picked up without a restart.
+func TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: getCertificate}
+}
+
+func getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c := Get()
+
+	info, err := os.Stat(c.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	certs.mu.Lock()
+	defer certs.mu.Unlock()
+
+	if certs.cert != nil && certs.certFile == c.Certificate && certs.keyFile == c.PrivateKey && certs.modTime.Equal(info.ModTime()) {
+		return certs.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.Certificate, c.PrivateKey)
+	if err != nil {
+		if certs.cert != nil {
+			// Keep serving the old pair while a renewal is half written.
+			return certs.cert, nil
+		}
+		return nil, err
+	}
+	certs.certFile, certs.keyFile, certs.modTime, certs.cert = c.Certificate, c.PrivateKey, info.ModTime(), &cert
+	return certs.cert, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ValidationError lists every problem found in a configuration, so that all
+// of them can be fixed before the next start.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+func (c Config) Validate() error {
+	var problems []string
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		problems = append(problems, "ListenAddr: "+err.Error())
+	}
+
+	if c.Https_Enabled {
+		if c.Certificate == "" || c.PrivateKey == "" {
+			problems = append(problems, "Https_Enabled requires Certificate and PrivateKey")
+		} else if _, err := tls.LoadX509KeyPair(c.Certificate, c.PrivateKey); err != nil {
+			problems = append(problems, "Certificate/PrivateKey: "+err.Error())
+		}
+	}
+
+	switch {
+	case c.AuthService != "" && c.AuthLocal:
+		problems = append(problems, "AuthService and AuthLocal cannot both be set")
+	case c.AuthService != "":
+		if u, err := url.Parse(c.AuthService); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, "AuthService must be an absolute URL")
+		}
+	case !c.AuthLocal:
+		problems = append(problems, "AuthService is required unless AuthLocal is set")
+	case !allowLocalAuth:
+		problems = append(problems, "AuthLocal is only allowed in builds tagged dev")
+	default:
+		if users, err := c.LocalUsers(); err != nil {
+			problems = append(problems, err.Error())
+		} else if len(users) == 0 {
+			problems = append(problems, "AuthLocal requires AuthLocalUsers")
+		}
+	}
+
+	if c.Smtpserver != "" {
+		if _, _, err := net.SplitHostPort(c.Smtpserver); err != nil {
+			problems = append(problems, "Smtpserver must be host:port: "+err.Error())
+		}
+		if c.Smtpfrom == "" {
+			problems = append(problems, "Smtpserver requires Smtpfrom")
+		}
+	}
+
+	if c.TwilioAccountSid != "" && (c.TwilioAuthToken == "" || c.TwilioFrom == "") {
+		problems = append(problems, "TwilioAccountSid requires TwilioAuthToken and TwilioFrom")
+	}
+
+	if c.DocumentKey != "" && len(c.DocumentKey) < 16 {
+		problems = append(problems, "DocumentKey must be at least 16 characters")
+	}
+	if c.ReportDir != "" {
+		if fi, err := os.Stat(c.ReportDir); err != nil {
+			problems = append(problems, "ReportDir: "+err.Error())
+		} else if !fi.IsDir() {
+			problems = append(problems, "ReportDir is not a directory")
+		}
+	}
+
+	if c.CardKey != "" && len(c.CardKey) < 32 {
+		problems = append(problems, "CardKey must be at least 32 characters")
+	}
+	if c.DatabaseDSN != "" && c.CardKey == "" {
+		// Cards stored under a random key could not be read after a restart.
+		problems = append(problems, "DatabaseDSN requires CardKey")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
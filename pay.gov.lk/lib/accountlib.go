@@ -2,48 +2,77 @@ package lib
 
 import (
 	"duov6.com/gorest"
+	"pay.gov.lk/auth"
 	"pay.gov.lk/model"
 	"pay.gov.lk/logic"
+	"pay.gov.lk/repository"
 )
 
 type AccountService struct {
-	gorest.RestService
+	gorest.RestService `realm:"paygov"`
 
-	getStatus gorest.EndPoint `method:"GET" path:"/account/status/{Id:string}/" output:"AccountStatus"`
-	setStatus gorest.EndPoint `method:"POST" path:"/account/status/" postdata:"AccountStatus"`
+	getStatus gorest.EndPoint `method:"GET" path:"/account/status/{Id:string}/" output:"AccountStatus" role:"payer,bank-officer"`
+	setStatus gorest.EndPoint `method:"POST" path:"/account/status/" postdata:"AccountStatus" role:"bank-officer"`
 
-	getAll gorest.EndPoint `method:"GET" path:"/account/" output:"[]Account"`
-	getAccount gorest.EndPoint `method:"GET" path:"/account/{Id:string}/" output:"Account"`
+	getAll gorest.EndPoint `method:"GET" path:"/account/?{offset:int}&{limit:int}&{status:string}&{name:string}" output:"AccountPage" role:"bank-officer"`
+	getAccount gorest.EndPoint `method:"GET" path:"/account/{Id:string}/" output:"Account" role:"payer,bank-officer"`
 
-	addAccount gorest.EndPoint `method:"POST" path:"/account/" postdata:"Account"`
-	deactivateAccount gorest.EndPoint `method:"DELETE" path:"/account/{Id:string}/"`
+	addAccount gorest.EndPoint `method:"POST" path:"/account/" postdata:"Account" role:"payer"`
+	deactivateAccount gorest.EndPoint `method:"DELETE" path:"/account/{Id:string}/" role:"bank-officer"`
 }
 
 func (p AccountService) GetStatus(Id string) model.AccountStatus {
-	return  model.AccountStatus {}
+	h := logic.NewAccountHandler();
+	if err := h.CheckOwner(Id, payerOf(p.RestService, auth.RoleBank)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return model.AccountStatus{}
+	}
+	s, err := h.GetStatus(Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return s
 }
 
 func (p AccountService) SetStatus(u model.AccountStatus) {
-
+	h := logic.NewAccountHandler();
+	if err := h.SetStatus(u, actorOf(p.RestService)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
 }
 
-func (p AccountService) GetAll() []model.Account {
-	//h := logic.NewAccountHandler();
-	a :=make([]model.Account,0)
+func (p AccountService) GetAll(offset int, limit int, status string, name string) model.AccountPage {
+	h := logic.NewAccountHandler();
+	a, err := h.ListAccounts(repository.AccountFilter{Status: status, Name: name}, offset, limit)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
 	return a
 }
 
 func (p AccountService) GetAccount(Id string) model.Account {
 	h := logic.NewAccountHandler();
-	return  h.GetAccount(Id)
+	if err := h.CheckOwner(Id, payerOf(p.RestService, auth.RoleBank)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return model.Account{}
+	}
+	a, err := h.GetAccount(Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return a
 }
 
 func (p AccountService) AddAccount(u model.Account) {
 	h := logic.NewAccountHandler();
-	h.AddAccount(u)
+	if err := h.AddAccount(u, actorOf(p.RestService)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
 }
 
 func (p AccountService) DeactivateAccount(Id string) {
 	h := logic.NewAccountHandler();
-	h.DeactivateAccount(Id)
+	if err := h.DeactivateAccount(Id, actorOf(p.RestService)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
 }
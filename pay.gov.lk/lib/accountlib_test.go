@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"net/http"
+	"pay.gov.lk/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayerOwnership(t *testing.T) {
+	startTestServer()
+	if err := testRepo.SaveAccount(model.Account{Number: "OWN1", Status: model.AccountActive, Owner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := testRepo.SaveTransaction(model.Trnasction{TransactionID: "OWNT1", CUSDECNumber: "OWNC1", AccountID: "OWN1", Amount: 10,
+		PaymentState: model.PaymentSettled, CreatedAt: now, UpdatedAt: now, SettledAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		"/account/OWN1/",
+		"/account/status/OWN1/",
+		"/accounts/pay/OWNT1/",
+		"/documents/confirmacc/OWN1/",
+		"/documents/tranreciept/OWNT1/",
+	} {
+		if res, body := get(t, path, "alice", "application/json", "payer"); res.StatusCode != 200 {
+			t.Errorf("owner GET %s = %d %s; want 200", path, res.StatusCode, body)
+		}
+		if res, _ := get(t, path, "bob", "application/json", "payer"); res.StatusCode != 403 {
+			t.Errorf("other payer GET %s = %d; want 403", path, res.StatusCode)
+		}
+		if res, _ := get(t, path, "officer", "application/json", "payer", "bank-officer"); res.StatusCode != 200 {
+			t.Errorf("bank officer GET %s = %d; want 200", path, res.StatusCode)
+		}
+	}
+	if res, _ := get(t, "/documents/confirmacc/OWN1/", "bob", "application/pdf", "payer"); res.StatusCode != 403 {
+		t.Errorf("other payer PDF confirmation = %d; want 403", res.StatusCode)
+	}
+
+	body := `{"CUSDECNumber":"OWNC2","AccountID":"OWN1","AmountToPay":10}`
+	req, err := http.NewRequest("POST", testServer.URL+"/accounts/pay/?xsrft="+testIssuer.Issue("bob", "payer"), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 403 {
+		t.Errorf("other payer paying from the account = %d; want 403", res.StatusCode)
+	}
+}
@@ -0,0 +1,22 @@
+package lib
+
+import (
+	"duov6.com/gorest"
+	"pay.gov.lk/model"
+	"pay.gov.lk/logic"
+)
+
+type AuditService struct {
+	gorest.RestService `realm:"paygov"`
+
+	getTrail gorest.EndPoint `method:"GET" path:"/audit/{Entity:string}/{Id:string}/" output:"[]AuditRecord" role:"admin"`
+}
+
+func (p AuditService) GetTrail(Entity string, Id string) []model.AuditRecord {
+	h := logic.NewAuditHandler()
+	a, err := h.GetTrail(Entity, Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return a
+}
@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"duov6.com/gorest"
+	"pay.gov.lk/auth"
+	"pay.gov.lk/model"
+)
+
+var issuer *auth.LocalIssuer
+
+// UseLocalIssuer makes TokenService issue sessions from l. Register
+// TokenService only when running against the local stand-in, never next to
+// DuoAuth.
+func UseLocalIssuer(l *auth.LocalIssuer) {
+	issuer = l
+}
+
+// TokenService hands out local session tokens for offline testing in
+// exchange for the password of a user set up with AuthLocalUsers. The
+// session gets that user's roles. Pass the token as the xsrft query
+// parameter on every other request.
+type TokenService struct {
+	gorest.RestService
+
+	issue gorest.EndPoint `method:"POST" path:"/auth/token/" postdata:"TokenRequest"`
+	revoke gorest.EndPoint `method:"DELETE" path:"/auth/token/{Token:string}/"`
+}
+
+func (p TokenService) Issue(u model.TokenRequest) {
+	if issuer == nil || u.User == "" || u.Password == "" {
+		p.ResponseBuilder().SetResponseCode(400).WriteAndOveride([]byte("a user and password are required"))
+		return
+	}
+	s, err := issuer.Login(u.User, u.Password)
+	if err != nil {
+		p.ResponseBuilder().SetResponseCode(401).WriteAndOveride([]byte(err.Error()))
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 201, model.IssuedToken{Token: s.Token, User: s.Username, Roles: s.Roles})
+}
+
+func (p TokenService) Revoke(Token string) {
+	if issuer != nil {
+		issuer.Revoke(Token)
+	}
+}
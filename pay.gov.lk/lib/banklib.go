@@ -4,35 +4,81 @@ import (
 	"duov6.com/gorest"
 	"pay.gov.lk/model"
 	"pay.gov.lk/logic"
+	"pay.gov.lk/repository"
 )
 
 type BankService struct {
-	gorest.RestService
+	gorest.RestService `realm:"paygov"`
 
-	confirmAcc gorest.EndPoint `method:"GET" path:"/bank/confirmacc/{Id:string}/" output:"ConfirmedDetails"`
-	rejectAcc gorest.EndPoint `method:"GET" path:"/bank/rejectacc/{Id:string}/" output:"ConfirmedDetails"`
+	confirmAcc gorest.EndPoint `method:"POST" path:"/bank/confirmacc/{Id:string}/" postdata:"StateChange" role:"bank-officer"`
+	rejectAcc gorest.EndPoint `method:"POST" path:"/bank/rejectacc/{Id:string}/" postdata:"StateChange" role:"bank-officer"`
 
-	getAll gorest.EndPoint `method:"GET" path:"/bank/" output:"[]Institute"`
+	getAll gorest.EndPoint `method:"GET" path:"/bank/?{offset:int}&{limit:int}&{type:string}&{name:string}" output:"InstitutePage"`
 	getOne gorest.EndPoint `method:"GET" path:"/bank/{Id:string}/" output:"Institute"`
+
+	addInstitute gorest.EndPoint `method:"POST" path:"/bank/" postdata:"Institute" role:"admin"`
+	updateInstitute gorest.EndPoint `method:"PUT" path:"/bank/{Id:string}/" postdata:"Institute" role:"admin"`
+	deleteInstitute gorest.EndPoint `method:"DELETE" path:"/bank/{Id:string}/" role:"admin"`
 }
 
-func (p BankService) ConfirmAcc(Id string) model.ConfirmedDetails {
+func (p BankService) ConfirmAcc(u model.StateChange, Id string) {
 	h := logic.NewBankHandler()
-	return h.ConfirmAcc(Id)
+	d, err := h.ConfirmAcc(Id, actorOf(p.RestService))
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 200, d)
 }
 
-func (p BankService) RejectAcc(Id string) model.ConfirmedDetails {
+func (p BankService) RejectAcc(u model.StateChange, Id string) {
 	h := logic.NewBankHandler()
-	return h.ConfirmAcc(Id)
+	d, err := h.RejectAcc(Id, actorOf(p.RestService))
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 200, d)
 }
 
-func (p BankService) GetAll() []model.Institute {
-	//h := logic.NewBankHandler()
-	a :=make([]model.Institute,0)
-	return a
+func (p BankService) GetAll(offset int, limit int, typ string, name string) model.InstitutePage {
+	h := logic.NewBankHandler()
+	pg, err := h.ListInstitutes(repository.InstituteFilter{Type: typ, Name: name}, offset, limit)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return pg
 }
 
 func (p BankService) GetOne(Id string) model.Institute {
-	//h := logic.NewBankHandler()
-	return model.Institute{}
-}
\ No newline at end of file
+	h := logic.NewBankHandler()
+	i, err := h.GetInstitute(Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return i
+}
+
+func (p BankService) AddInstitute(u model.Institute) {
+	h := logic.NewBankHandler()
+	if err := h.AddInstitute(u); err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	p.ResponseBuilder().Created("/bank/" + u.InstituteID + "/")
+}
+
+func (p BankService) UpdateInstitute(u model.Institute, Id string) {
+	h := logic.NewBankHandler()
+	u.InstituteID = Id
+	if err := h.UpdateInstitute(u); err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+}
+
+func (p BankService) DeleteInstitute(Id string) {
+	h := logic.NewBankHandler()
+	if err := h.DeleteInstitute(Id); err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+}
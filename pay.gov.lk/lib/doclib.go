@@ -2,23 +2,78 @@ package lib
 
 import (
 	"duov6.com/gorest"
+	"pay.gov.lk/auth"
 	"pay.gov.lk/model"
 	"pay.gov.lk/logic"
+	"strconv"
+	"strings"
 )
 
 type DocService struct {
-	gorest.RestService
+	gorest.RestService `realm:"paygov"`
 
-	documentAccConfirm gorest.EndPoint `method:"GET" path:"/documents/confirmacc/{Id:string}/" output:"PrintDocument"`
-	documentTranReciept gorest.EndPoint `method:"GET" path:"/documents/tranreciept/{Id:string}/" output:"PrintDocument"`
+	documentAccConfirm gorest.EndPoint `method:"GET" path:"/documents/confirmacc/{Id:string}/" output:"PrintDocument" produces:"application/pdf" role:"payer,bank-officer"`
+	documentTranReciept gorest.EndPoint `method:"GET" path:"/documents/tranreciept/{Id:string}/" output:"PrintDocument" produces:"application/pdf" role:"payer,bank-officer,customs-officer"`
+
+	verify gorest.EndPoint `method:"GET" path:"/documents/verify/{Kind:string}/{Id:string}/{Code:string}/" output:"DocumentVerification"`
 }
 
+// Both document endpoints answer with the JSON structure unless the client
+// asks for application/pdf in its Accept header.
 func (p DocService) DocumentAccConfirm(Id string) model.PrintDocument {
+	if err := logic.NewAccountHandler().CheckOwner(Id, payerOf(p.RestService, auth.RoleBank)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return model.PrintDocument{}
+	}
 	h := logic.NewDocumentHandler();
-	return h.DocumentAccConfirm(Id)
+	if p.wantsPDF() {
+		p.writePDF(h.DocumentAccConfirmPDF(Id))
+		return model.PrintDocument{}
+	}
+	d, err := h.DocumentAccConfirm(Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return d
 }
 
 func (p DocService) DocumentTranReciept(Id string) model.PrintDocument {
+	if err := logic.NewPaymentHandler().CheckOwner(Id, payerOf(p.RestService, auth.RoleBank+","+auth.RoleCustoms)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return model.PrintDocument{}
+	}
 	h := logic.NewDocumentHandler();
-	return h.DocumentTranReciept(Id)
-}
\ No newline at end of file
+	if p.wantsPDF() {
+		p.writePDF(h.DocumentTranRecieptPDF(Id))
+		return model.PrintDocument{}
+	}
+	d, err := h.DocumentTranReciept(Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return d
+}
+
+func (p DocService) Verify(Kind string, Id string, Code string) model.DocumentVerification {
+	h := logic.NewDocumentHandler();
+	v, err := h.Verify(Kind, Id, Code)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return v
+}
+
+func (p DocService) wantsPDF() bool {
+	return strings.Contains(p.Context.Request().Header.Get("Accept"), gorest.Application_Pdf)
+}
+
+func (p DocService) writePDF(b []byte, err error) {
+	rb := p.ResponseBuilder()
+	if err != nil {
+		writeError(rb, err)
+		return
+	}
+	rb.SetContentType(gorest.Application_Pdf)
+	rb.SetHeader("Content-Length", strconv.Itoa(len(b)))
+	rb.SetResponseCode(200).WriteAndOveride(b)
+}
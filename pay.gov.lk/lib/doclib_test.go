@@ -0,0 +1,27 @@
+package lib
+
+import (
+	"bytes"
+	"pay.gov.lk/model"
+	"testing"
+)
+
+func TestDocumentPDF(t *testing.T) {
+	startTestServer()
+	if err := testRepo.SaveAccount(model.Account{Number: "DOC1", Name: "Importer", Status: model.AccountActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, body := get(t, "/documents/confirmacc/DOC1/", "officer", "application/pdf", "bank-officer")
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != "application/pdf" {
+		t.Fatalf("PDF confirmation = %d %s; want 200 application/pdf", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(body, []byte("%PDF")) {
+		t.Errorf("PDF confirmation starts with %.8q", body)
+	}
+
+	res, _ = get(t, "/documents/confirmacc/DOC1/", "officer", "application/json", "bank-officer")
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("JSON confirmation = %d %s; want 200 application/json", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+}
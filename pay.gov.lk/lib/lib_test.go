@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"duov6.com/gorest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"pay.gov.lk/auth"
+	"pay.gov.lk/logic"
+	"pay.gov.lk/repository"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testOnce   sync.Once
+	testServer *httptest.Server
+	testIssuer *auth.LocalIssuer
+	testRepo   repository.Repository
+)
+
+// startTestServer serves every pay.gov.lk service from a memory repository,
+// with sessions from a local issuer. gorest keeps its services in package
+// state, so they are registered once and shared by the tests.
+func startTestServer() {
+	testOnce.Do(func() {
+		testRepo = repository.NewMemoryRepository()
+		logic.UseRepository(testRepo)
+		logic.SetDocumentKey([]byte("test document key"))
+
+		testIssuer = auth.NewLocalIssuer()
+		gorest.RegisterRealmAuthorizer(auth.Realm, auth.Authorizer(testIssuer))
+		gorest.RegisterService(new(PayService))
+		gorest.RegisterService(new(DocService))
+		gorest.RegisterService(new(AccountService))
+		gorest.RegisterService(new(ReconciliationService))
+		testServer = httptest.NewServer(gorest.Handle())
+	})
+}
+
+// get sends a GET for path in a session of user with the given roles.
+func get(t *testing.T, path, user, accept string, roles ...string) (*http.Response, []byte) {
+	startTestServer()
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequest("GET", testServer.URL+path+sep+"xsrft="+testIssuer.Issue(user, roles...), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, body
+}
@@ -2,17 +2,93 @@ package lib
 
 import (
 	"duov6.com/gorest"
+	"pay.gov.lk/auth"
 	"pay.gov.lk/model"
 	"pay.gov.lk/logic"
 )
 
+// IdempotencyHeader lets clients that cannot set PaymentInfo.IdempotencyKey
+// pass the key with the request instead.
+const IdempotencyHeader = "Idempotency-Key"
+
 type PayService struct {
-	gorest.RestService
+	gorest.RestService `realm:"paygov"`
+
+	pay gorest.EndPoint `method:"POST" path:"/accounts/pay/" postdata:"PaymentInfo" role:"payer"`
+	getTransaction gorest.EndPoint `method:"GET" path:"/accounts/pay/{Id:string}/" output:"Trnasction" role:"payer,bank-officer,customs-officer"`
 
-	pay gorest.EndPoint `method:"POST" path:"/accounts/pay/" postdata:"PaymentInfo"`
+	settle gorest.EndPoint `method:"POST" path:"/accounts/pay/settle/{Id:string}/" postdata:"StateChange" role:"bank-officer"`
+	fail gorest.EndPoint `method:"POST" path:"/accounts/pay/fail/{Id:string}/" postdata:"StateChange" role:"bank-officer"`
+	refund gorest.EndPoint `method:"POST" path:"/accounts/pay/refund/{Id:string}/" postdata:"StateChange" role:"bank-officer"`
 }
 
 func (p PayService) Pay(u model.PaymentInfo) {
+	if key := p.Context.Request().Header.Get(IdempotencyHeader); key != "" {
+		u.IdempotencyKey = key
+	}
+
+	// payers may only pay from their own accounts; a missing account is
+	// left to Pay to reject
+	if u.AccountID != "" {
+		if err := logic.NewAccountHandler().CheckOwner(u.AccountID, payerOf(p.RestService, auth.RoleAdmin)); err != nil {
+			writeError(p.ResponseBuilder(), err)
+			return
+		}
+	}
+
+	h := logic.NewPaymentHandler();
+	t, replayed, err := h.Pay(u, actorOf(p.RestService))
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+
+	code := 201
+	if replayed {
+		code = 200
+	}
+	writeJSON(p.ResponseBuilder(), code, t)
+}
+
+func (p PayService) GetTransaction(Id string) model.Trnasction {
+	h := logic.NewPaymentHandler();
+	if err := h.CheckOwner(Id, payerOf(p.RestService, auth.RoleBank+","+auth.RoleCustoms)); err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return model.Trnasction{}
+	}
+	t, err := h.GetTransaction(Id)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+	}
+	return t
+}
+
+func (p PayService) Settle(u model.StateChange, Id string) {
+	h := logic.NewPaymentHandler();
+	t, err := h.Settle(Id, actorOf(p.RestService))
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 200, t)
+}
+
+func (p PayService) Fail(u model.StateChange, Id string) {
 	h := logic.NewPaymentHandler();
-	h.Pay(u)
-}
\ No newline at end of file
+	t, err := h.Fail(Id, u.Reason, actorOf(p.RestService))
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 200, t)
+}
+
+func (p PayService) Refund(u model.StateChange, Id string) {
+	h := logic.NewPaymentHandler();
+	t, err := h.Refund(Id, u.Reason, actorOf(p.RestService))
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 200, t)
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"bytes"
+	"duov6.com/gorest"
+	"io"
+	"pay.gov.lk/logic"
+	"pay.gov.lk/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	textCSV         = "text/csv"
+	applicationXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+type ReconciliationService struct {
+	gorest.RestService `realm:"paygov"`
+
+	reconcile gorest.EndPoint `method:"GET" path:"/reconciliation/?{from:string}&{to:string}" output:"Reconciliation" produces:"text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" role:"bank-officer,customs-officer"`
+	matchStatement gorest.EndPoint `method:"POST" path:"/reconciliation/statement/" postdata:"BankStatement" role:"bank-officer"`
+}
+
+// Reconcile covers the days from and to inclusive, yesterday by default.
+// The report is JSON unless the Accept header asks for CSV or XLSX.
+func (p ReconciliationService) Reconcile(from string, to string) model.Reconciliation {
+	rb := p.ResponseBuilder()
+	start, end, err := logic.DayRange(from, to, time.Local)
+	if err != nil {
+		writeError(rb, err)
+		return model.Reconciliation{}
+	}
+	h := logic.NewReconciliationHandler()
+	r, err := h.Reconcile(start, end)
+	if err != nil {
+		writeError(rb, err)
+		return model.Reconciliation{}
+	}
+
+	accept := p.Context.Request().Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, applicationXLSX):
+		p.writeReport(applicationXLSX, func(w io.Writer) error { return logic.WriteReconciliationXLSX(w, r) })
+	case strings.Contains(accept, textCSV):
+		p.writeReport(textCSV, func(w io.Writer) error { return logic.WriteReconciliationCSV(w, r) })
+	}
+	return r
+}
+
+// MatchStatement takes the statement lines either as Lines or as the CSV
+// file exported by the bank, and answers with what could not be matched.
+func (p ReconciliationService) MatchStatement(s model.BankStatement) {
+	h := logic.NewReconciliationHandler()
+	m, err := h.MatchStatement(s)
+	if err != nil {
+		writeError(p.ResponseBuilder(), err)
+		return
+	}
+	writeJSON(p.ResponseBuilder(), 200, m)
+}
+
+func (p ReconciliationService) writeReport(mime string, write func(w io.Writer) error) {
+	rb := p.ResponseBuilder()
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		writeError(rb, err)
+		return
+	}
+	rb.SetContentType(mime)
+	rb.SetHeader("Content-Length", strconv.Itoa(buf.Len()))
+	rb.SetResponseCode(200).WriteAndOveride(buf.Bytes())
+}
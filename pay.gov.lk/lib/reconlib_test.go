@@ -0,0 +1,26 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReconcileExports(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		prefix string
+	}{
+		{textCSV, "From"},
+		{applicationXLSX, "PK"},
+		{"application/json", "{"},
+	} {
+		res, body := get(t, "/reconciliation/?from=2030-03-10&to=2030-03-10", "officer", tt.accept, "bank-officer")
+		if res.StatusCode != 200 || res.Header.Get("Content-Type") != tt.accept {
+			t.Errorf("%s report = %d %s", tt.accept, res.StatusCode, res.Header.Get("Content-Type"))
+			continue
+		}
+		if !bytes.HasPrefix(body, []byte(tt.prefix)) {
+			t.Errorf("%s report starts with %.8q; want %q", tt.accept, body, tt.prefix)
+		}
+	}
+}
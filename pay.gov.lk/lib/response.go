@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"duov6.com/gorest"
+	"encoding/json"
+	"pay.gov.lk/auth"
+	"pay.gov.lk/logic"
+	"pay.gov.lk/repository"
+)
+
+// writeError maps a handler error to an HTTP status and writes it, discarding
+// whatever the service method returns afterwards.
+func writeError(rb *gorest.ResponseBuilder, err error) {
+	code := 500
+	switch err {
+	case repository.ErrNotFound:
+		code = 404
+	case repository.ErrDuplicate, logic.ErrInactiveAccount, logic.ErrUnconfirmedAccount, logic.ErrUnsettledPayment, logic.ErrAlreadyPaid:
+		code = 409
+	case logic.ErrInvalidAccount, logic.ErrInvalidPayment, logic.ErrInvalidInstitute,
+		logic.ErrInvalidCard, logic.ErrInvalidExpiry, logic.ErrCardExpired,
+		logic.ErrInvalidDate, logic.ErrInvalidRange, logic.ErrInvalidStatement:
+		code = 400
+	case logic.ErrIdempotencyMismatch:
+		code = 422
+	case logic.ErrNotOwner:
+		code = 403
+	}
+	if _, ok := err.(*logic.TransitionError); ok {
+		code = 409
+	}
+	rb.SetResponseCode(code).WriteAndOveride([]byte(err.Error()))
+}
+
+// writeJSON is used by POST endpoints, which gorest does not allow to return
+// a value, to send a JSON body with the given status.
+func writeJSON(rb *gorest.ResponseBuilder, code int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(rb, err)
+		return
+	}
+	rb.SetContentType(gorest.Application_Json)
+	rb.SetResponseCode(code).WriteAndOveride(b)
+}
+
+// actorOf names the caller for the audit trail: the user of the session the
+// realm authorizer resolved.
+func actorOf(s gorest.RestService) string {
+	if sess := s.Session(); sess != nil {
+		return sess.SessionId()
+	}
+	return "anonymous"
+}
+
+// payerOf names the caller when they may only see their own accounts and
+// payments, that is when the session holds none of the staff roles, and is
+// empty otherwise.
+func payerOf(s gorest.RestService, staff string) string {
+	if sess, ok := s.Session().(*auth.Session); ok && sess.HasRole(staff) {
+		return ""
+	}
+	return actorOf(s)
+}
@@ -2,28 +2,86 @@ package logic
 
 import (
 	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"time"
 )
 
 type AccountHandler struct{
+	repo repository.Repository
+}
+
+func (p AccountHandler) ListAccounts(f repository.AccountFilter, offset int, limit int) (model.AccountPage, error) {
+	offset, limit = page(offset, limit)
+	items, total, err := p.repo.ListAccounts(f, offset, limit)
+	if err != nil {
+		return model.AccountPage{}, err
+	}
+	for i := range items {
+		items[i] = redactCard(items[i])
+	}
+	return model.AccountPage{Total: total, Offset: offset, Limit: limit, Items: items}, nil
+}
+
+// GetAccount returns account Id without the name and expiry of its card.
+func (p AccountHandler) GetAccount(Id string) (model.Account, error) {
+	a, err := p.repo.GetAccount(Id)
+	return redactCard(a), err
+}
+
+// AddAccount registers u as an account of actor. A card number is validated
+// and encrypted, and only its masked form and token are kept in the clear.
+func (p AccountHandler) AddAccount(u model.Account, actor string) error {
+	if u.Number == "" {
+		return ErrInvalidAccount
+	}
+	u.Owner = actor
+	if err := tokenizeCard(&u, time.Now()); err != nil {
+		return err
+	}
+	u.Status = model.AccountRegistered
+	if err := p.repo.SaveAccount(u); err != nil {
+		return err
+	}
+	return audit(p.repo, accountStates.entity, u.Number, "", u.Status, actor)
+}
 
+// CheckOwner fails with ErrNotOwner unless account Id was registered by
+// payer. Staff pass an empty payer and may see every account.
+func (p AccountHandler) CheckOwner(Id string, payer string) error {
+	return checkOwner(p.repo, Id, payer)
 }
 
-func (p AccountHandler) GetAllAccounts() model.Account {
-	return model.Account {}
+func checkOwner(r repository.Repository, accountID string, payer string) error {
+	if payer == "" {
+		return nil
+	}
+	a, err := r.GetAccount(accountID)
+	if err != nil {
+		return err
+	}
+	if a.Owner != payer {
+		return ErrNotOwner
+	}
+	return nil
 }
 
-func (p AccountHandler) GetAccount(Id string) model.Account {
-	return model.Account {}
+func (p AccountHandler) DeactivateAccount(Id string, actor string) error {
+	return transitionAccount(p.repo, Id, model.AccountDeactivated, actor)
 }
 
-func (p AccountHandler) AddAccount(u model.Account) {
-	//return Account{"", "", "", "", "", false}
+func (p AccountHandler) GetStatus(Id string) (model.AccountStatus, error) {
+	return p.repo.GetStatus(Id)
 }
 
-func (p AccountHandler) DeactivateAccount(Id string) {
-	
+// SetStatus moves the account to u.Status, which must be reachable from its
+// current status.
+func (p AccountHandler) SetStatus(u model.AccountStatus, actor string) error {
+	if u.Number == "" {
+		return ErrInvalidAccount
+	}
+	return transitionAccount(p.repo, u.Number, u.Status, actor)
 }
 
 func NewAccountHandler() AccountHandler{
-	return AccountHandler{}
-}
\ No newline at end of file
+	return AccountHandler{repo: repo}
+}
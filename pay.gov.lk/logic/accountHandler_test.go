@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"testing"
+)
+
+func TestAddAccount(t *testing.T) {
+	h := AccountHandler{repo: repository.NewMemoryRepository()}
+
+	if err := h.AddAccount(model.Account{}, "test"); err != ErrInvalidAccount {
+		t.Errorf("AddAccount without a number = %v; want ErrInvalidAccount", err)
+	}
+	if err := h.AddAccount(model.Account{Number: "A1", Status: model.AccountActive}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := h.GetStatus("A1"); s.Status != model.AccountRegistered {
+		t.Errorf("new account has status %q; want %q", s.Status, model.AccountRegistered)
+	}
+	if err := h.CheckOwner("A1", "test"); err != nil {
+		t.Errorf("CheckOwner for the payer who added the account = %v", err)
+	}
+	if err := h.CheckOwner("A1", "other"); err != ErrNotOwner {
+		t.Errorf("CheckOwner for another payer = %v; want ErrNotOwner", err)
+	}
+
+	if err := h.SetStatus(model.AccountStatus{Number: "A1", Status: model.AccountConfirmed}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddAccount(model.Account{Number: "A1"}, "test"); err != repository.ErrDuplicate {
+		t.Errorf("AddAccount of a registered number = %v; want ErrDuplicate", err)
+	}
+	if s, _ := h.GetStatus("A1"); s.Status != model.AccountConfirmed {
+		t.Errorf("duplicate AddAccount reset the status to %q", s.Status)
+	}
+}
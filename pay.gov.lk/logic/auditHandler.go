@@ -0,0 +1,20 @@
+package logic
+
+import (
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+)
+
+type AuditHandler struct{
+	repo repository.Repository
+}
+
+// GetTrail returns the recorded state transitions of an account or payment,
+// oldest first.
+func (p AuditHandler) GetTrail(Entity string, Id string) ([]model.AuditRecord, error) {
+	return p.repo.GetAudit(Entity, Id)
+}
+
+func NewAuditHandler() AuditHandler{
+	return AuditHandler{repo: repo}
+}
@@ -2,18 +2,69 @@ package logic
 
 import (
 	"pay.gov.lk/model"
+	"pay.gov.lk/notify"
+	"pay.gov.lk/repository"
 )
 
 type BankHandler struct{
+	repo repository.Repository
+}
+
+
+func (p BankHandler) ConfirmAcc(Id string, actor string) (model.ConfirmedDetails, error) {
+	if err := transitionAccount(p.repo, Id, model.AccountConfirmed, actor); err != nil {
+		return model.ConfirmedDetails{}, err
+	}
+	notifyHolder(p.repo, notify.EventAccountConfirmed, Id, nil)
+	return model.ConfirmedDetails {AccountID: Id, IsVerified: true}, nil
+}
+
+func (p BankHandler) RejectAcc(Id string, actor string) (model.ConfirmedDetails, error) {
+	if err := transitionAccount(p.repo, Id, model.AccountRejected, actor); err != nil {
+		return model.ConfirmedDetails{}, err
+	}
+	return model.ConfirmedDetails {AccountID: Id, IsVerified: false}, nil
+}
+
+func (p BankHandler) AddInstitute(i model.Institute) error {
+	if err := validInstitute(i); err != nil {
+		return err
+	}
+	return p.repo.SaveInstitute(i)
+}
 
+func (p BankHandler) UpdateInstitute(i model.Institute) error {
+	if err := validInstitute(i); err != nil {
+		return err
+	}
+	return p.repo.UpdateInstitute(i)
 }
 
+func (p BankHandler) DeleteInstitute(Id string) error {
+	return p.repo.DeleteInstitute(Id)
+}
 
-func (p BankHandler) ConfirmAcc(Id string) model.ConfirmedDetails {
-	return model.ConfirmedDetails {}
+func (p BankHandler) GetInstitute(Id string) (model.Institute, error) {
+	return p.repo.GetInstitute(Id)
+}
+
+func (p BankHandler) ListInstitutes(f repository.InstituteFilter, offset int, limit int) (model.InstitutePage, error) {
+	offset, limit = page(offset, limit)
+	items, total, err := p.repo.ListInstitutes(f, offset, limit)
+	if err != nil {
+		return model.InstitutePage{}, err
+	}
+	return model.InstitutePage{Total: total, Offset: offset, Limit: limit, Items: items}, nil
+}
+
+func validInstitute(i model.Institute) error {
+	if i.InstituteID == "" || (i.Type != model.InstituteBank && i.Type != model.InstituteCustoms) {
+		return ErrInvalidInstitute
+	}
+	return nil
 }
 
 
 func NewBankHandler() BankHandler{
-	return BankHandler{}
-}
\ No newline at end of file
+	return BankHandler{repo: repo}
+}
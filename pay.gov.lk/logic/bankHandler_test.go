@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"pay.gov.lk/model"
+	"pay.gov.lk/notify"
+	"pay.gov.lk/repository"
+	"testing"
+)
+
+func TestAccountTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		want  string
+		err   bool
+	}{
+		{"confirm", []string{model.AccountConfirmed}, model.AccountConfirmed, false},
+		{"reject", []string{model.AccountRejected}, model.AccountRejected, false},
+		{"confirm twice", []string{model.AccountConfirmed, model.AccountConfirmed}, model.AccountConfirmed, true},
+		{"confirm rejected", []string{model.AccountRejected, model.AccountConfirmed}, model.AccountRejected, true},
+		{"reject confirmed", []string{model.AccountConfirmed, model.AccountRejected}, model.AccountConfirmed, true},
+	}
+	for _, tt := range tests {
+		r := repository.NewMemoryRepository()
+		if err := r.SaveAccount(model.Account{Number: "A1", Status: model.AccountRegistered}); err != nil {
+			t.Fatal(err)
+		}
+		h := BankHandler{repo: r}
+
+		var err error
+		for _, step := range tt.steps {
+			if step == model.AccountConfirmed {
+				_, err = h.ConfirmAcc("A1", "bank")
+			} else {
+				_, err = h.RejectAcc("A1", "bank")
+			}
+			if err != nil {
+				break
+			}
+		}
+		if _, ok := err.(*TransitionError); ok != tt.err {
+			t.Errorf("%s: last step = %v; want a TransitionError %v", tt.name, err, tt.err)
+		}
+		if s, _ := r.GetStatus("A1"); s.Status != tt.want {
+			t.Errorf("%s: account is %s; want %s", tt.name, s.Status, tt.want)
+		}
+	}
+
+	h := BankHandler{repo: repository.NewMemoryRepository()}
+	if _, err := h.ConfirmAcc("A9", "bank"); err != repository.ErrNotFound {
+		t.Errorf("ConfirmAcc of a missing account = %v; want ErrNotFound", err)
+	}
+}
+
+func TestConfirmAccNotifies(t *testing.T) {
+	r := repository.NewMemoryRepository()
+	if err := r.SaveAccount(model.Account{Number: "A1", Email: "a@example.com", Status: model.AccountRegistered}); err != nil {
+		t.Fatal(err)
+	}
+	o := notify.NewOutbox(r)
+	email := notify.NewFakeChannel("email")
+	o.Register(email)
+	UseNotifier(o)
+	defer UseNotifier(nil)
+
+	h := BankHandler{repo: r}
+	if _, err := h.RejectAcc("A9", "bank"); err == nil {
+		t.Fatal("rejected a missing account")
+	}
+	if _, err := h.ConfirmAcc("A1", "bank"); err != nil {
+		t.Fatal(err)
+	}
+	if sent, err := o.Flush(); sent != 1 || err != nil {
+		t.Fatalf("Flush = %d, %v; want 1", sent, err)
+	}
+	if got := email.Sent(); got[0].To != "a@example.com" {
+		t.Errorf("confirmation went to %q", got[0].To)
+	}
+}
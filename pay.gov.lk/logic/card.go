@@ -0,0 +1,137 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/nacl/secretbox"
+	"io"
+	"pay.gov.lk/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nonceSize = 24
+
+var cardKey = deriveCardKey(randomKey())
+
+// SetCardKey sets the secret that card numbers are encrypted with. Without
+// it a random key is used and stored card numbers cannot be read after a
+// restart. Changing the key makes every card stored under the old one
+// unreadable, so it is only set at startup.
+func SetCardKey(secret []byte) {
+	cardKey = deriveCardKey(secret)
+}
+
+func deriveCardKey(secret []byte) *[32]byte {
+	var k [32]byte
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte("pay.gov.lk card")), k[:]); err != nil {
+		panic(err)
+	}
+	return &k
+}
+
+// tokenizeCard validates the card on a, encrypts the card number into
+// CardCipher and replaces CardNumber with its masked form. Accounts without a
+// card are left alone.
+func tokenizeCard(a *model.Account, now time.Time) error {
+	if a.CardNumber == "" {
+		return nil
+	}
+	pan := strings.NewReplacer(" ", "", "-", "").Replace(a.CardNumber)
+	if !luhn(pan) {
+		return ErrInvalidCard
+	}
+	expires, err := parseExpiry(a.Expiry)
+	if err != nil {
+		return err
+	}
+	if !now.Before(expires) {
+		return ErrCardExpired
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	sealed := secretbox.Seal(nonce[:], []byte(pan), &nonce, cardKey)
+
+	a.CardCipher = base64.StdEncoding.EncodeToString(sealed)
+	a.CardToken = "tok_" + strings.Replace(newID(), "-", "", -1)
+	a.CardNumber = model.MaskPAN(pan)
+	return nil
+}
+
+// redactCard blanks the card details that are kept in the clear for the
+// bank but must not be sent back to a client.
+func redactCard(a model.Account) model.Account {
+	a.NameonCard = ""
+	a.Expiry = ""
+	return a
+}
+
+// RevealCard decrypts the card number of a stored account. It is meant for
+// settlement with the issuing bank and must never be sent back to a client.
+func RevealCard(a model.Account) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(a.CardCipher)
+	if err != nil || len(sealed) < nonceSize {
+		return "", ErrCardUnreadable
+	}
+	var nonce [nonceSize]byte
+	copy(nonce[:], sealed)
+	pan, ok := secretbox.Open(nil, sealed[nonceSize:], &nonce, cardKey)
+	if !ok {
+		return "", ErrCardUnreadable
+	}
+	return string(pan), nil
+}
+
+func luhn(pan string) bool {
+	if len(pan) < 12 || len(pan) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(pan) - 1; i >= 0; i-- {
+		d := int(pan[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// parseExpiry reads MM/YY or MM/YYYY and returns the first instant after the
+// card expires, i.e. the start of the following month.
+func parseExpiry(s string) (time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	month, err := strconv.Atoi(parts[0])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	year, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	switch len(parts[1]) {
+	case 2:
+		year += 2000
+	case 4:
+	default:
+		return time.Time{}, ErrInvalidExpiry
+	}
+	return time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		pan  string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"5500005555555559", true},
+		{"4111111111111112", false},
+		{"41111111111", false},
+		{"4111x11111111111", false},
+		{"41111111111111111111", false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.pan); got != tt.want {
+			t.Errorf("luhn(%q) = %v; want %v", tt.pan, got, tt.want)
+		}
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Time
+		err  error
+	}{
+		{"12/30", time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"02/2031", time.Date(2031, 3, 1, 0, 0, 0, 0, time.UTC), nil},
+		{" 7/29 ", time.Date(2029, 8, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"13/30", time.Time{}, ErrInvalidExpiry},
+		{"12/030", time.Time{}, ErrInvalidExpiry},
+		{"1230", time.Time{}, ErrInvalidExpiry},
+		{"ab/cd", time.Time{}, ErrInvalidExpiry},
+	}
+	for _, tt := range tests {
+		got, err := parseExpiry(tt.s)
+		if err != tt.err || !got.Equal(tt.want) {
+			t.Errorf("parseExpiry(%q) = %v, %v; want %v, %v", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestTokenizeCard(t *testing.T) {
+	now := time.Date(2030, 6, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		card, expiry string
+		err          error
+	}{
+		{"4111 1111 1111 1111", "06/30", nil},
+		{"4111-1111-1111-1112", "06/30", ErrInvalidCard},
+		{"4111111111111111", "5/30", ErrCardExpired},
+		{"4111111111111111", "2030-06", ErrInvalidExpiry},
+	}
+	for _, tt := range tests {
+		a := model.Account{Number: "A1", CardNumber: tt.card, Expiry: tt.expiry}
+		if err := tokenizeCard(&a, now); err != tt.err {
+			t.Errorf("tokenizeCard(%q, %q) = %v; want %v", tt.card, tt.expiry, err, tt.err)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if a.CardNumber != "************1111" || !strings.HasPrefix(a.CardToken, "tok_") || a.CardCipher == "" {
+			t.Errorf("tokenized card is %q, token %q", a.CardNumber, a.CardToken)
+		}
+		if pan, err := RevealCard(a); err != nil || pan != "4111111111111111" {
+			t.Errorf("RevealCard = %q, %v", pan, err)
+		}
+	}
+
+	a := model.Account{Number: "A1"}
+	if err := tokenizeCard(&a, now); err != nil || a.CardToken != "" {
+		t.Errorf("tokenizeCard of an account without a card = %v, token %q", err, a.CardToken)
+	}
+}
+
+func TestSetCardKey(t *testing.T) {
+	defer SetCardKey(randomKey())
+
+	SetCardKey([]byte("first key, at least 32 characters"))
+	a := model.Account{CardNumber: "4111111111111111", Expiry: "12/99"}
+	if err := tokenizeCard(&a, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	SetCardKey([]byte("other key, at least 32 characters"))
+	if _, err := RevealCard(a); err != ErrCardUnreadable {
+		t.Errorf("RevealCard under another key = %v; want ErrCardUnreadable", err)
+	}
+	a.CardCipher = "not base64"
+	if _, err := RevealCard(a); err != ErrCardUnreadable {
+		t.Errorf("RevealCard of a corrupt cipher = %v; want ErrCardUnreadable", err)
+	}
+}
+
+func TestGetAccountRedactsCard(t *testing.T) {
+	h := AccountHandler{repo: repository.NewMemoryRepository()}
+	u := model.Account{Number: "A1", CardNumber: "4111111111111111", NameonCard: "A PERERA", Expiry: "12/99"}
+	if err := h.AddAccount(u, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := h.GetAccount("A1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.NameonCard != "" || a.Expiry != "" || a.CardNumber != "************1111" {
+		t.Errorf("GetAccount returned card %q, name %q, expiry %q", a.CardNumber, a.NameonCard, a.Expiry)
+	}
+	pg, err := h.ListAccounts(repository.AccountFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pg.Items) != 1 || pg.Items[0].NameonCard != "" || pg.Items[0].Expiry != "" {
+		t.Errorf("ListAccounts returned %+v", pg.Items)
+	}
+	if _, err := h.GetAccount("A9"); err != repository.ErrNotFound {
+		t.Errorf("GetAccount of a missing account = %v; want ErrNotFound", err)
+	}
+}
@@ -1,21 +1,225 @@
 package logic
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
 	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"sync"
+	"time"
 )
 
+const (
+	DocAccConfirm   = "confirmacc"
+	DocTranReciept  = "tranreciept"
+	verificationKey = "Verification"
+)
+
+var (
+	documentKeyMu sync.RWMutex
+	documentKey   = randomKey()
+)
+
+// SetDocumentKey sets the secret used to sign the verification code printed
+// on every document. Without it a random key is used and codes do not
+// survive a restart. It may be called while documents are being issued.
+func SetDocumentKey(key []byte) {
+	documentKeyMu.Lock()
+	documentKey = key
+	documentKeyMu.Unlock()
+}
+
+func randomKey() []byte {
+	k := make([]byte, 32)
+	rand.Read(k)
+	return k
+}
+
+// documentTemplate fixes the title of a document and the order in which its
+// Header and Body fields are printed.
+type documentTemplate struct {
+	title  string
+	header []string
+	body   []string
+}
+
+var accConfirmTemplate = documentTemplate{
+	title:  "Account Confirmation",
+	header: []string{"Document", "Account", "Issued", verificationKey},
+	body:   []string{"Name", "Display Name", "Account Type", "Card Type", "Status"},
+}
+
+var tranRecieptTemplate = documentTemplate{
+	title:  "Payment Receipt",
+	header: []string{"Document", "Transaction", "Issued", verificationKey},
+	body:   []string{"CUSDEC Number", "Account", "Amount", "State", "Payment State", "Paid On"},
+}
+
 type DocumentHandler struct{
+	repo repository.Repository
+}
+
+func (p DocumentHandler) DocumentAccConfirm(Id string) (model.PrintDocument, error) {
+	a, err := p.repo.GetAccount(Id)
+	if err != nil {
+		return model.PrintDocument{}, err
+	}
+	if a.Status != model.AccountConfirmed && a.Status != model.AccountActive {
+		return model.PrintDocument{}, ErrUnconfirmedAccount
+	}
+	return fill(accConfirmTemplate, DocAccConfirm, a.Number, accConfirmFacts(a), map[string]interface{}{
+		"Name":         a.Name,
+		"Display Name": a.DisplayName,
+		"Account Type": a.Type,
+		"Card Type":    a.CardType,
+		"Status":       a.Status,
+	}), nil
+}
+
+func (p DocumentHandler) DocumentTranReciept(Id string) (model.PrintDocument, error) {
+	t, err := p.repo.GetTransaction(Id)
+	if err != nil {
+		return model.PrintDocument{}, err
+	}
+	if t.PaymentState != model.PaymentSettled {
+		return model.PrintDocument{}, ErrUnsettledPayment
+	}
+	return fill(tranRecieptTemplate, DocTranReciept, t.TransactionID, tranRecieptFacts(t), map[string]interface{}{
+		"CUSDEC Number": t.CUSDECNumber,
+		"Account":       t.AccountID,
+		"Amount":        fmt.Sprintf("%.2f", t.Amount),
+		"State":         t.State,
+		"Payment State": t.PaymentState,
+		"Paid On":       paidOn(t),
+	}), nil
+}
 
+// paidOn is the settlement time of a payment. Receipts are only issued for
+// settled payments, but ones stored before the time was kept have none.
+func paidOn(t model.Trnasction) string {
+	if t.SettledAt.IsZero() {
+		return ""
+	}
+	return t.SettledAt.Format(time.RFC1123)
 }
 
-func (p DocumentHandler) DocumentAccConfirm(Id string) model.PrintDocument {
-	return model.PrintDocument {}
+func (p DocumentHandler) DocumentAccConfirmPDF(Id string) ([]byte, error) {
+	d, err := p.DocumentAccConfirm(Id)
+	if err != nil {
+		return nil, err
+	}
+	return render(accConfirmTemplate, d)
 }
 
-func (p DocumentHandler) DocumentTranReciept(Id string) model.PrintDocument {
-	return model.PrintDocument {}
+func (p DocumentHandler) DocumentTranRecieptPDF(Id string) ([]byte, error) {
+	d, err := p.DocumentTranReciept(Id)
+	if err != nil {
+		return nil, err
+	}
+	return render(tranRecieptTemplate, d)
+}
+
+// Verify checks the verification code printed on a document against the
+// stored record and reports the record's current status, so that an officer
+// can tell a forged receipt from a genuine one that was later refunded.
+func (p DocumentHandler) Verify(Kind string, Id string, Code string) (model.DocumentVerification, error) {
+	var facts []string
+	status := ""
+	switch Kind {
+	case DocAccConfirm:
+		a, err := p.repo.GetAccount(Id)
+		if err != nil {
+			return model.DocumentVerification{}, err
+		}
+		facts, status = accConfirmFacts(a), a.Status
+	case DocTranReciept:
+		t, err := p.repo.GetTransaction(Id)
+		if err != nil {
+			return model.DocumentVerification{}, err
+		}
+		facts, status = tranRecieptFacts(t), t.PaymentState
+	default:
+		return model.DocumentVerification{}, repository.ErrNotFound
+	}
+
+	valid := hmac.Equal([]byte(Code), []byte(sign(Kind, facts)))
+	return model.DocumentVerification{DocumentID: Id, IsValid: valid, Status: status}, nil
+}
+
+// The facts are the fields of a record that never change once the document
+// can be issued, so a printed code stays valid while the status moves on.
+func accConfirmFacts(a model.Account) []string {
+	return []string{a.Number, a.Name, a.Type}
+}
+
+func tranRecieptFacts(t model.Trnasction) []string {
+	return []string{t.TransactionID, t.CUSDECNumber, t.AccountID, fmt.Sprintf("%.2f", t.Amount), t.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func sign(kind string, facts []string) string {
+	documentKeyMu.RLock()
+	m := hmac.New(sha256.New, documentKey)
+	documentKeyMu.RUnlock()
+	m.Write([]byte(kind))
+	for _, f := range facts {
+		m.Write([]byte{0})
+		m.Write([]byte(f))
+	}
+	// 20 hex characters are short enough to type in from a printout.
+	return hex.EncodeToString(m.Sum(nil))[:20]
+}
+
+func fill(t documentTemplate, kind string, id string, facts []string, body map[string]interface{}) model.PrintDocument {
+	return model.PrintDocument{
+		Title: t.title,
+		Header: map[string]interface{}{
+			"Document":      kind,
+			t.header[1]:     id,
+			"Issued":        time.Now().Format(time.RFC1123),
+			verificationKey: sign(kind, facts),
+		},
+		Body: body,
+	}
+}
+
+func render(t documentTemplate, d model.PrintDocument) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(d.Title, false)
+	pdf.AddPage()
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(0, 12, d.Title, "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Arial", "", 10)
+	for _, k := range t.header {
+		pdf.CellFormat(40, 7, k, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 7, fmt.Sprint(d.Header[k]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	pdf.SetFont("Arial", "", 12)
+	for _, k := range t.body {
+		pdf.CellFormat(60, 9, k, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 9, fmt.Sprint(d.Body[k]), "1", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 9)
+	pdf.MultiCell(0, 5, fmt.Sprintf("Verify this document at /documents/verify/%s/%s/%s/", d.Header["Document"], d.Header[t.header[1]], d.Header[verificationKey]), "", "L", false)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func NewDocumentHandler() DocumentHandler {
-	return DocumentHandler{}
-}
\ No newline at end of file
+	return DocumentHandler{repo: repo}
+}
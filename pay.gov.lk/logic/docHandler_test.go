@@ -0,0 +1,131 @@
+package logic
+
+import (
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"sync"
+	"testing"
+)
+
+func TestDocumentAccConfirm(t *testing.T) {
+	tests := []struct {
+		status string
+		err    error
+	}{
+		{model.AccountRegistered, ErrUnconfirmedAccount},
+		{model.AccountRejected, ErrUnconfirmedAccount},
+		{model.AccountDeactivated, ErrUnconfirmedAccount},
+		{model.AccountConfirmed, nil},
+		{model.AccountActive, nil},
+	}
+	for _, tt := range tests {
+		r := repository.NewMemoryRepository()
+		if err := r.SaveAccount(model.Account{Number: "A1", Name: "Importer", Status: tt.status}); err != nil {
+			t.Fatal(err)
+		}
+		h := DocumentHandler{repo: r}
+
+		d, err := h.DocumentAccConfirm("A1")
+		if err != tt.err {
+			t.Errorf("%s: DocumentAccConfirm = %v; want %v", tt.status, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		code := d.Header[verificationKey].(string)
+		if v, _ := h.Verify(DocAccConfirm, "A1", code); !v.IsValid || v.Status != tt.status {
+			t.Errorf("%s: Verify = %+v; want a valid code", tt.status, v)
+		}
+		if v, _ := h.Verify(DocAccConfirm, "A1", code[:len(code)-1]); v.IsValid {
+			t.Errorf("%s: Verify accepted a forged code", tt.status)
+		}
+	}
+	h := DocumentHandler{repo: repository.NewMemoryRepository()}
+	if _, err := h.DocumentAccConfirm("A9"); err != repository.ErrNotFound {
+		t.Errorf("DocumentAccConfirm of a missing account = %v; want ErrNotFound", err)
+	}
+	if _, err := h.Verify("other", "A1", ""); err != repository.ErrNotFound {
+		t.Errorf("Verify of an unknown kind = %v; want ErrNotFound", err)
+	}
+}
+
+func TestDocumentTranReciept(t *testing.T) {
+	p := newTestPayment(t, model.AccountActive)
+	h := DocumentHandler{repo: p.repo}
+	tr, _, err := p.Pay(testPaymentInfo(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DocumentTranReciept(tr.TransactionID); err != ErrUnsettledPayment {
+		t.Errorf("receipt of an authorized payment = %v; want ErrUnsettledPayment", err)
+	}
+	if _, err := h.DocumentTranRecieptPDF(tr.TransactionID); err != ErrUnsettledPayment {
+		t.Errorf("PDF receipt of an authorized payment = %v; want ErrUnsettledPayment", err)
+	}
+
+	info := testPaymentInfo()
+	info.CUSDECNumber = "C2"
+	failed, _, err := p.Pay(info, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Fail(failed.TransactionID, "declined", "bank"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DocumentTranReciept(failed.TransactionID); err != ErrUnsettledPayment {
+		t.Errorf("receipt of a failed payment = %v; want ErrUnsettledPayment", err)
+	}
+
+	if _, err := p.Settle(tr.TransactionID, "bank"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := h.DocumentTranReciept(tr.TransactionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Body["Paid On"] == "" {
+		t.Error("settled receipt has no payment date")
+	}
+	code := d.Header[verificationKey].(string)
+	if v, _ := h.Verify(DocTranReciept, tr.TransactionID, code); !v.IsValid || v.Status != model.PaymentSettled {
+		t.Errorf("Verify = %+v; want a valid settled receipt", v)
+	}
+
+	if _, err := p.Refund(tr.TransactionID, "duplicate", "bank"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DocumentTranReciept(tr.TransactionID); err != ErrUnsettledPayment {
+		t.Errorf("receipt of a refunded payment = %v; want ErrUnsettledPayment", err)
+	}
+	if v, _ := h.Verify(DocTranReciept, tr.TransactionID, code); !v.IsValid || v.Status != model.PaymentRefunded {
+		t.Errorf("Verify after refund = %+v; want a valid code showing the refund", v)
+	}
+}
+
+func TestSetDocumentKey(t *testing.T) {
+	defer SetDocumentKey(randomKey())
+
+	SetDocumentKey([]byte("one"))
+	code := sign(DocAccConfirm, []string{"A1"})
+	SetDocumentKey([]byte("two"))
+	if sign(DocAccConfirm, []string{"A1"}) == code {
+		t.Error("a new document key signs like the old one")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			SetDocumentKey(randomKey())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			sign(DocAccConfirm, []string{"A1"})
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,30 @@
+package logic
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidAccount  = errors.New("account number is required")
+	ErrInvalidPayment  = errors.New("payment requires a CUSDEC number, an account and a positive amount")
+	ErrInactiveAccount = errors.New("account is not active")
+	ErrUnknownAccount  = errors.New("account does not exist")
+	ErrNotOwner        = errors.New("account belongs to another payer")
+
+	ErrUnconfirmedAccount = errors.New("account has not been confirmed by the bank")
+	ErrUnsettledPayment   = errors.New("payment has not been settled by the bank")
+
+	ErrInvalidCard    = errors.New("card number is not valid")
+	ErrInvalidExpiry  = errors.New("card expiry must be MM/YY or MM/YYYY")
+	ErrCardExpired    = errors.New("card has expired")
+	ErrCardUnreadable = errors.New("stored card number cannot be decrypted")
+
+	ErrInvalidInstitute = errors.New("institute requires an ID and a type of bank or customs")
+
+	ErrAlreadyPaid         = errors.New("customs declaration has already been paid")
+	ErrIdempotencyMismatch = errors.New("idempotency key was already used for a different payment")
+
+	ErrInvalidDate      = errors.New("dates must be given as YYYY-MM-DD")
+	ErrInvalidRange     = errors.New("reconciliation range must end after it starts")
+	ErrInvalidStatement = errors.New("bank statement needs reference, amount and date columns")
+)
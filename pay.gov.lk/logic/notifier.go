@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"log"
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+)
+
+// Notifier queues a message about event for the given addresses, keyed by
+// channel name. notify.Outbox implements it.
+type Notifier interface {
+	Notify(event string, to map[string]string, data interface{}) error
+}
+
+var notifier Notifier
+
+// UseNotifier turns on payer notifications. Without it handlers send none.
+func UseNotifier(n Notifier) {
+	notifier = n
+}
+
+// notifyHolder tells the holder of account Id about event. A failure to
+// queue is logged rather than returned: the state change it reports has
+// already happened.
+func notifyHolder(r repository.Repository, event string, Id string, data interface{}) {
+	if notifier == nil {
+		return
+	}
+	a, err := r.GetAccount(Id)
+	if err != nil {
+		log.Println("notify:", event, Id, err)
+		return
+	}
+	if data == nil {
+		data = a
+	}
+	if err := notifier.Notify(event, contactOf(a), data); err != nil {
+		log.Println("notify:", event, Id, err)
+	}
+}
+
+func contactOf(a model.Account) map[string]string {
+	return map[string]string{"email": a.Email, "sms": a.Phone}
+}
@@ -2,16 +2,202 @@ package logic
 
 import (
 	"pay.gov.lk/model"
+	"pay.gov.lk/notify"
+	"pay.gov.lk/repository"
+	"time"
 )
 
 type PaymentHandler struct{
+	repo repository.Repository
+}
+
+// Pay records a payment for a customs declaration and returns its
+// transaction. Retries are safe: a request carrying an idempotency key that
+// was seen before, or a second payment of the same CUSDEC by the same
+// account, replays the original transaction instead of paying again. A
+// replay must match the original payment; one that differs fails with
+// ErrIdempotencyMismatch when the key was reused and ErrAlreadyPaid
+// otherwise. The returned bool reports whether the transaction is a replay.
+func (p PaymentHandler) Pay(u model.PaymentInfo, actor string) (model.Trnasction, bool, error) {
+	if u.CUSDECNumber == "" || u.AccountID == "" || u.AmountToPay <= 0 {
+		return model.Trnasction{}, false, ErrInvalidPayment
+	}
+
+	if u.IdempotencyKey != "" {
+		t, err := p.repo.GetTransactionByKey(u.IdempotencyKey)
+		if err == nil {
+			return replay(t, u)
+		}
+		if err != repository.ErrNotFound {
+			return model.Trnasction{}, false, err
+		}
+	}
+
+	prior, err := p.repo.GetTransactionsByCUSDEC(u.CUSDECNumber)
+	if err != nil {
+		return model.Trnasction{}, false, err
+	}
+	for _, t := range prior {
+		if t.State == model.TransactionRejected {
+			continue
+		}
+		if samePayment(t, u) {
+			return t, true, nil
+		}
+		return model.Trnasction{}, false, ErrAlreadyPaid
+	}
+
+	now := time.Now()
+	t := model.Trnasction{
+		TransactionID:   newID(),
+		IdempotencyKey:  u.IdempotencyKey,
+		CUSDECNumber:    u.CUSDECNumber,
+		AccountID:       u.AccountID,
+		ToInstituteID:   u.ToInstituteID,
+		FromInstituteID: u.FromInstituteID,
+		BankId:          u.BankId,
+		AmountPayable:   u.AmountPayable,
+		Amount:          u.AmountToPay,
+		State:           model.TransactionPending,
+		PaymentState:    model.PaymentInitiated,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := p.repo.SaveTransaction(t); err != nil {
+		if err == repository.ErrDuplicate && u.IdempotencyKey != "" {
+			// A concurrent retry with the same key got there first.
+			if existing, err := p.repo.GetTransactionByKey(u.IdempotencyKey); err == nil {
+				return replay(existing, u)
+			}
+		}
+		return model.Trnasction{}, false, err
+	}
+	if err := audit(p.repo, paymentStates.entity, t.TransactionID, "", t.PaymentState, actor); err != nil {
+		return model.Trnasction{}, false, err
+	}
+
+	postErr := p.post(u)
+	next := model.PaymentAuthorized
+	if postErr != nil {
+		next = model.PaymentFailed
+		t.State = model.TransactionRejected
+		t.Reason = postErr.Error()
+	} else {
+		t.State = model.TransactionAccepted
+		t.IsVerified = true
+	}
+	t.UpdatedAt = time.Now()
+	if err := p.repo.UpdateTransaction(t); err != nil {
+		return model.Trnasction{}, false, err
+	}
+
+	t, err = transitionPayment(p.repo, t.TransactionID, next, actor)
+	if err != nil {
+		return model.Trnasction{}, false, err
+	}
+	return t, false, nil
+}
+
+func (p PaymentHandler) GetTransaction(Id string) (model.Trnasction, error) {
+	return p.repo.GetTransaction(Id)
+}
+
+// CheckOwner fails with ErrNotOwner unless transaction Id was paid from an
+// account of payer. Staff pass an empty payer and may see every payment.
+func (p PaymentHandler) CheckOwner(Id string, payer string) error {
+	if payer == "" {
+		return nil
+	}
+	t, err := p.repo.GetTransaction(Id)
+	if err != nil {
+		return err
+	}
+	return checkOwner(p.repo, t.AccountID, payer)
+}
 
+// Settle marks an authorized payment as settled by the bank.
+func (p PaymentHandler) Settle(Id string, actor string) (model.Trnasction, error) {
+	t, err := transitionPayment(p.repo, Id, model.PaymentSettled, actor)
+	if err != nil {
+		return t, err
+	}
+	notifyHolder(p.repo, notify.EventPaymentSettled, t.AccountID, t)
+	return t, nil
 }
 
-func (p PaymentHandler) Pay(u model.PaymentInfo) {
-	
+// Fail marks a payment that could not be authorized or settled as failed.
+// Its ledger entries are reversed and the transaction is rejected for
+// reason, which leaves the CUSDEC free to be paid again.
+func (p PaymentHandler) Fail(Id string, reason string, actor string) (model.Trnasction, error) {
+	return reversePayment(p.repo, Id, model.PaymentFailed, reason, actor)
+}
+
+// Refund marks a settled payment as refunded, reversing it like Fail.
+func (p PaymentHandler) Refund(Id string, reason string, actor string) (model.Trnasction, error) {
+	return reversePayment(p.repo, Id, model.PaymentRefunded, reason, actor)
+}
+
+func (p PaymentHandler) GetLedger(CUSDECNumber string) ([]model.LedgerEntry, error) {
+	return p.repo.GetLedger(CUSDECNumber)
+}
+
+// post writes the payment as a balanced pair of ledger entries: a debit
+// against the paying account and a credit to the receiving institute.
+func (p PaymentHandler) post(u model.PaymentInfo) error {
+	acc, err := p.repo.GetAccount(u.AccountID)
+	if err == repository.ErrNotFound {
+		return ErrUnknownAccount
+	}
+	if err != nil {
+		return err
+	}
+	if acc.Status != model.AccountActive {
+		return ErrInactiveAccount
+	}
+
+	now := time.Now()
+	entries := []model.LedgerEntry{
+		{
+			EntryID:      newID(),
+			CUSDECNumber: u.CUSDECNumber,
+			AccountID:    u.AccountID,
+			InstituteID:  u.FromInstituteID,
+			Debit:        u.AmountToPay,
+			Timestamp:    now,
+		},
+		{
+			EntryID:      newID(),
+			CUSDECNumber: u.CUSDECNumber,
+			InstituteID:  u.ToInstituteID,
+			Credit:       u.AmountToPay,
+			Timestamp:    now,
+		},
+	}
+	err = p.repo.PostLedger(u.CUSDECNumber, entries)
+	if err == repository.ErrDuplicate {
+		return ErrAlreadyPaid
+	}
+	return err
+}
+
+func replay(t model.Trnasction, u model.PaymentInfo) (model.Trnasction, bool, error) {
+	if !samePayment(t, u) {
+		return model.Trnasction{}, false, ErrIdempotencyMismatch
+	}
+	return t, true, nil
+}
+
+// samePayment reports whether u asks for the payment t was created for.
+func samePayment(t model.Trnasction, u model.PaymentInfo) bool {
+	return t.CUSDECNumber == u.CUSDECNumber &&
+		t.AccountID == u.AccountID &&
+		t.ToInstituteID == u.ToInstituteID &&
+		t.FromInstituteID == u.FromInstituteID &&
+		t.BankId == u.BankId &&
+		t.AmountPayable == u.AmountPayable &&
+		t.Amount == u.AmountToPay
 }
 
 func NewPaymentHandler() PaymentHandler{
-	return PaymentHandler{}
-}
\ No newline at end of file
+	return PaymentHandler{repo: repo}
+}
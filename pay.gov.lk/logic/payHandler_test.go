@@ -0,0 +1,267 @@
+package logic
+
+import (
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"testing"
+)
+
+// newTestPayment returns a handler on an empty repository holding one
+// account in the given status.
+func newTestPayment(t *testing.T, status string) PaymentHandler {
+	r := repository.NewMemoryRepository()
+	if err := r.SaveAccount(model.Account{Number: "A1", Status: status}); err != nil {
+		t.Fatal(err)
+	}
+	return PaymentHandler{repo: r}
+}
+
+func testPaymentInfo() model.PaymentInfo {
+	return model.PaymentInfo{
+		CUSDECNumber:    "C1",
+		AccountID:       "A1",
+		FromInstituteID: "bank",
+		ToInstituteID:   "customs",
+		BankId:          "bank",
+		AmountPayable:   100,
+		AmountToPay:     100,
+	}
+}
+
+// balance sums the debits and the credits in the ledger of cusdec.
+func balance(t *testing.T, r repository.Repository, cusdec string) (debit, credit float64) {
+	entries, err := r.GetLedger(cusdec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		debit += e.Debit
+		credit += e.Credit
+	}
+	return debit, credit
+}
+
+func TestPayPostsLedger(t *testing.T) {
+	h := newTestPayment(t, model.AccountActive)
+
+	tr, _, err := h.Pay(testPaymentInfo(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.State != model.TransactionAccepted || tr.PaymentState != model.PaymentAuthorized {
+		t.Errorf("transaction is %s/%s; want accepted/authorized", tr.State, tr.PaymentState)
+	}
+	entries, err := h.GetLedger("C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("ledger has %d entries; want 2", len(entries))
+	}
+	if debit, credit := balance(t, h.repo, "C1"); debit != 100 || credit != 100 {
+		t.Errorf("ledger debits %v and credits %v; want 100 each", debit, credit)
+	}
+}
+
+func TestPayRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		info   func(*model.PaymentInfo)
+		err    error
+		reason error
+	}{
+		{"no CUSDEC", model.AccountActive, func(u *model.PaymentInfo) { u.CUSDECNumber = "" }, ErrInvalidPayment, nil},
+		{"no account", model.AccountActive, func(u *model.PaymentInfo) { u.AccountID = "" }, ErrInvalidPayment, nil},
+		{"zero amount", model.AccountActive, func(u *model.PaymentInfo) { u.AmountToPay = 0 }, ErrInvalidPayment, nil},
+		{"unknown account", model.AccountActive, func(u *model.PaymentInfo) { u.AccountID = "A9" }, nil, ErrUnknownAccount},
+		{"inactive account", model.AccountConfirmed, func(u *model.PaymentInfo) {}, nil, ErrInactiveAccount},
+	}
+	for _, tt := range tests {
+		h := newTestPayment(t, tt.status)
+		u := testPaymentInfo()
+		tt.info(&u)
+
+		tr, _, err := h.Pay(u, "test")
+		if err != tt.err {
+			t.Errorf("%s: Pay = %v; want %v", tt.name, err, tt.err)
+			continue
+		}
+		if tt.reason == nil {
+			continue
+		}
+		if tr.State != model.TransactionRejected || tr.PaymentState != model.PaymentFailed || tr.Reason != tt.reason.Error() {
+			t.Errorf("%s: transaction is %s/%s %q; want rejected/failed %q", tt.name, tr.State, tr.PaymentState, tr.Reason, tt.reason)
+		}
+		if _, err := h.GetLedger(u.CUSDECNumber); err != repository.ErrNotFound {
+			t.Errorf("%s: rejected payment was posted to the ledger", tt.name)
+		}
+	}
+}
+
+func TestPayReplay(t *testing.T) {
+	h := newTestPayment(t, model.AccountActive)
+	if err := h.repo.SaveAccount(model.Account{Number: "A2", Status: model.AccountActive}); err != nil {
+		t.Fatal(err)
+	}
+	u := testPaymentInfo()
+	u.IdempotencyKey = "k1"
+
+	first, replayed, err := h.Pay(u, "test")
+	if err != nil || replayed {
+		t.Fatalf("first Pay = %v, replayed %v", err, replayed)
+	}
+	again, replayed, err := h.Pay(u, "test")
+	if err != nil || !replayed || again.TransactionID != first.TransactionID {
+		t.Errorf("retried Pay = %s, replayed %v, %v; want %s replayed", again.TransactionID, replayed, err, first.TransactionID)
+	}
+
+	u.IdempotencyKey = ""
+	if again, replayed, err := h.Pay(u, "test"); err != nil || !replayed || again.TransactionID != first.TransactionID {
+		t.Errorf("Pay of the same CUSDEC = %s, replayed %v, %v; want %s replayed", again.TransactionID, replayed, err, first.TransactionID)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		info func(*model.PaymentInfo)
+		err  error
+	}{
+		{"key with another amount", "k1", func(u *model.PaymentInfo) { u.AmountToPay = 50 }, ErrIdempotencyMismatch},
+		{"key with another bank", "k1", func(u *model.PaymentInfo) { u.BankId = "other" }, ErrIdempotencyMismatch},
+		{"key with another CUSDEC", "k1", func(u *model.PaymentInfo) { u.CUSDECNumber = "C2" }, ErrIdempotencyMismatch},
+		{"another amount", "", func(u *model.PaymentInfo) { u.AmountToPay = 50 }, ErrAlreadyPaid},
+		{"another payee", "", func(u *model.PaymentInfo) { u.ToInstituteID = "other" }, ErrAlreadyPaid},
+		{"another account", "", func(u *model.PaymentInfo) { u.AccountID = "A2" }, ErrAlreadyPaid},
+	}
+	for _, tt := range tests {
+		u := testPaymentInfo()
+		u.IdempotencyKey = tt.key
+		tt.info(&u)
+		if _, _, err := h.Pay(u, "test"); err != tt.err {
+			t.Errorf("%s: Pay = %v; want %v", tt.name, err, tt.err)
+		}
+	}
+	if debit, credit := balance(t, h.repo, "C1"); debit != 100 || credit != 100 {
+		t.Errorf("ledger debits %v and credits %v after retries; want 100 each", debit, credit)
+	}
+}
+
+func TestPaymentTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		err   bool
+	}{
+		{"settle", []string{model.PaymentSettled}, false},
+		{"fail", []string{model.PaymentFailed}, false},
+		{"refund", []string{model.PaymentSettled, model.PaymentRefunded}, false},
+		{"refund unsettled", []string{model.PaymentRefunded}, true},
+		{"settle twice", []string{model.PaymentSettled, model.PaymentSettled}, true},
+		{"fail settled", []string{model.PaymentSettled, model.PaymentFailed}, true},
+		{"settle failed", []string{model.PaymentFailed, model.PaymentSettled}, true},
+		{"refund twice", []string{model.PaymentSettled, model.PaymentRefunded, model.PaymentRefunded}, true},
+	}
+	for _, tt := range tests {
+		h := newTestPayment(t, model.AccountActive)
+		tr, _, err := h.Pay(testPaymentInfo(), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, step := range tt.steps {
+			switch step {
+			case model.PaymentSettled:
+				_, err = h.Settle(tr.TransactionID, "bank")
+			case model.PaymentFailed:
+				_, err = h.Fail(tr.TransactionID, "declined", "bank")
+			case model.PaymentRefunded:
+				_, err = h.Refund(tr.TransactionID, "returned", "bank")
+			}
+			if err != nil {
+				break
+			}
+		}
+		if _, ok := err.(*TransitionError); ok != tt.err {
+			t.Errorf("%s: last step = %v; want a TransitionError %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestSettleRecordsTime(t *testing.T) {
+	h := newTestPayment(t, model.AccountActive)
+	tr, _, err := h.Pay(testPaymentInfo(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err = h.Settle(tr.TransactionID, "bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.PaymentState != model.PaymentSettled || tr.SettledAt.IsZero() {
+		t.Errorf("settled transaction is %s at %v", tr.PaymentState, tr.SettledAt)
+	}
+	audit, _ := h.repo.GetAudit("payment", tr.TransactionID)
+	if len(audit) != 3 {
+		t.Errorf("payment has %d audit records; want 3", len(audit))
+	}
+}
+
+func TestReversePayment(t *testing.T) {
+	for _, to := range []string{model.PaymentFailed, model.PaymentRefunded} {
+		h := newTestPayment(t, model.AccountActive)
+		tr, _, err := h.Pay(testPaymentInfo(), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if to == model.PaymentRefunded {
+			if _, err := h.Settle(tr.TransactionID, "bank"); err != nil {
+				t.Fatal(err)
+			}
+			tr, err = h.Refund(tr.TransactionID, "returned", "bank")
+		} else {
+			tr, err = h.Fail(tr.TransactionID, "returned", "bank")
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", to, err)
+		}
+		if tr.State != model.TransactionRejected || tr.PaymentState != to || tr.Reason != "returned" {
+			t.Errorf("%s: transaction is %s/%s %q; want rejected/%s", to, tr.State, tr.PaymentState, tr.Reason, to)
+		}
+
+		entries, err := h.GetLedger("C1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 4 {
+			t.Errorf("%s: ledger has %d entries; want 4", to, len(entries))
+		}
+		if debit, credit := balance(t, h.repo, "C1"); debit != 200 || credit != 200 {
+			t.Errorf("%s: ledger debits %v and credits %v; want 200 each", to, debit, credit)
+		}
+
+		again, replayed, err := h.Pay(testPaymentInfo(), "test")
+		if err != nil || replayed || again.TransactionID == tr.TransactionID {
+			t.Fatalf("%s: paying again = %v, replayed %v", to, err, replayed)
+		}
+		if again.State != model.TransactionAccepted {
+			t.Errorf("%s: new payment is %s; want accepted", to, again.State)
+		}
+		if debit, credit := balance(t, h.repo, "C1"); debit != 300 || credit != 300 {
+			t.Errorf("%s: ledger debits %v and credits %v; want 300 each", to, debit, credit)
+		}
+	}
+}
+
+func TestFailRejectedPayment(t *testing.T) {
+	h := newTestPayment(t, model.AccountConfirmed)
+	tr, _, err := h.Pay(testPaymentInfo(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Fail(tr.TransactionID, "", "bank"); err == nil {
+		t.Error("failed a payment that had already failed")
+	}
+	if _, err := h.Fail("T9", "", "bank"); err != repository.ErrNotFound {
+		t.Errorf("Fail of a missing transaction = %v; want ErrNotFound", err)
+	}
+}
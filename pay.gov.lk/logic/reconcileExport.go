@@ -0,0 +1,107 @@
+package logic
+
+import (
+	"encoding/csv"
+	"github.com/tealeg/xlsx"
+	"io"
+	"pay.gov.lk/model"
+	"strconv"
+	"time"
+)
+
+var (
+	lineHeader     = []string{"Institute", "Payments", "Amount", "Refunded"}
+	mismatchHeader = []string{"Transaction", "CUSDEC Number", "Account", "Amount Payable", "Amount Paid"}
+)
+
+// WriteReconciliationCSV writes r as CSV: a summary row, then the per-bank,
+// per-institute and mismatch tables, each with its own header and separated
+// by an empty line.
+func WriteReconciliationCSV(w io.Writer, r model.Reconciliation) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"From", "To", "Payments", "Amount"})
+	c.Write([]string{r.From.Format(time.RFC3339), r.To.Format(time.RFC3339), strconv.Itoa(r.Count), money(r.Amount)})
+
+	for _, section := range []struct {
+		title string
+		lines []model.ReconciliationLine
+	}{{"By bank", r.ByBank}, {"By institute", r.ByInstitute}} {
+		c.Write(nil)
+		c.Write([]string{section.title})
+		c.Write(lineHeader)
+		for _, l := range section.lines {
+			c.Write([]string{l.InstituteID, strconv.Itoa(l.Count), money(l.Amount), money(l.Refunded)})
+		}
+	}
+
+	c.Write(nil)
+	c.Write([]string{"Mismatches"})
+	c.Write(mismatchHeader)
+	for _, m := range r.Mismatches {
+		c.Write([]string{m.TransactionID, m.CUSDECNumber, m.AccountID, money(m.AmountPayable), money(m.AmountToPay)})
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+// WriteReconciliationXLSX writes r as a workbook with a sheet per table.
+func WriteReconciliationXLSX(w io.Writer, r model.Reconciliation) error {
+	f := xlsx.NewFile()
+
+	summary, err := f.AddSheet("Summary")
+	if err != nil {
+		return err
+	}
+	addRow(summary, "From", "To", "Payments", "Amount")
+	row := summary.AddRow()
+	row.AddCell().SetDateTime(r.From)
+	row.AddCell().SetDateTime(r.To)
+	row.AddCell().SetInt(r.Count)
+	row.AddCell().SetFloatWithFormat(r.Amount, "#,##0.00")
+
+	for _, section := range []struct {
+		title string
+		lines []model.ReconciliationLine
+	}{{"By bank", r.ByBank}, {"By institute", r.ByInstitute}} {
+		sheet, err := f.AddSheet(section.title)
+		if err != nil {
+			return err
+		}
+		addRow(sheet, lineHeader...)
+		for _, l := range section.lines {
+			row := sheet.AddRow()
+			row.AddCell().SetString(l.InstituteID)
+			row.AddCell().SetInt(l.Count)
+			row.AddCell().SetFloatWithFormat(l.Amount, "#,##0.00")
+			row.AddCell().SetFloatWithFormat(l.Refunded, "#,##0.00")
+		}
+	}
+
+	sheet, err := f.AddSheet("Mismatches")
+	if err != nil {
+		return err
+	}
+	addRow(sheet, mismatchHeader...)
+	for _, m := range r.Mismatches {
+		row := sheet.AddRow()
+		row.AddCell().SetString(m.TransactionID)
+		row.AddCell().SetString(m.CUSDECNumber)
+		row.AddCell().SetString(m.AccountID)
+		row.AddCell().SetFloatWithFormat(m.AmountPayable, "#,##0.00")
+		row.AddCell().SetFloatWithFormat(m.AmountToPay, "#,##0.00")
+	}
+
+	return f.Write(w)
+}
+
+func addRow(sheet *xlsx.Sheet, values ...string) {
+	row := sheet.AddRow()
+	for _, v := range values {
+		row.AddCell().SetString(v)
+	}
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,233 @@
+package logic
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// amountTolerance absorbs rounding when amounts are compared.
+const amountTolerance = 0.005
+
+type ReconciliationHandler struct{
+	repo repository.Repository
+}
+
+// Reconcile aggregates the payments settled in [from, to) per bank and per
+// receiving institute and lists those whose amount paid differs from the
+// amount payable on the declaration. Refunded payments are counted with the
+// bank that settled them and their amount is also reported as Refunded.
+func (p ReconciliationHandler) Reconcile(from, to time.Time) (model.Reconciliation, error) {
+	if !to.After(from) {
+		return model.Reconciliation{}, ErrInvalidRange
+	}
+	settled, err := p.repo.ListSettledTransactions(from, to)
+	if err != nil {
+		return model.Reconciliation{}, err
+	}
+
+	r := model.Reconciliation{From: from, To: to, Mismatches: make([]model.AmountMismatch, 0)}
+	banks := make(map[string]*model.ReconciliationLine)
+	institutes := make(map[string]*model.ReconciliationLine)
+	for _, t := range settled {
+		r.Count++
+		r.Amount += t.Amount
+		addLine(banks, bankOf(t), t)
+		addLine(institutes, t.ToInstituteID, t)
+
+		if t.AmountPayable > 0 && math.Abs(t.AmountPayable-t.Amount) > amountTolerance {
+			r.Mismatches = append(r.Mismatches, model.AmountMismatch{
+				TransactionID: t.TransactionID,
+				CUSDECNumber:  t.CUSDECNumber,
+				AccountID:     t.AccountID,
+				AmountPayable: t.AmountPayable,
+				AmountToPay:   t.Amount,
+			})
+		}
+	}
+	r.ByBank = sortedLines(banks)
+	r.ByInstitute = sortedLines(institutes)
+	return r, nil
+}
+
+// MatchStatement matches the lines of a bank statement against the payments
+// settled through that bank over the statement period. A line matches when
+// its reference is the transaction ID or CUSDEC number of a payment with the
+// same amount; lines without a reference never match. Lines and payments left
+// over are reported.
+func (p ReconciliationHandler) MatchStatement(s model.BankStatement) (model.StatementMatch, error) {
+	if !s.To.After(s.From) {
+		return model.StatementMatch{}, ErrInvalidRange
+	}
+	lines := s.Lines
+	if s.CSV != "" {
+		parsed, err := ParseStatementCSV(strings.NewReader(s.CSV))
+		if err != nil {
+			return model.StatementMatch{}, err
+		}
+		lines = append(lines, parsed...)
+	}
+
+	settled, err := p.repo.ListSettledTransactions(s.From, s.To)
+	if err != nil {
+		return model.StatementMatch{}, err
+	}
+	open := make(map[string]model.Trnasction)
+	byRef := make(map[string]string)
+	for _, t := range settled {
+		if s.BankID != "" && bankOf(t) != s.BankID {
+			continue
+		}
+		open[t.TransactionID] = t
+		for _, ref := range []string{t.TransactionID, t.CUSDECNumber} {
+			if ref != "" {
+				byRef[ref] = t.TransactionID
+			}
+		}
+	}
+
+	m := model.StatementMatch{
+		BankID:                s.BankID,
+		From:                  s.From,
+		To:                    s.To,
+		UnmatchedLines:        make([]model.StatementLine, 0),
+		UnmatchedTransactions: make([]model.Trnasction, 0),
+	}
+	for _, l := range lines {
+		t, ok := open[byRef[l.Reference]]
+		if l.Reference == "" || !ok || math.Abs(t.Amount-l.Amount) > amountTolerance {
+			m.UnmatchedLines = append(m.UnmatchedLines, l)
+			continue
+		}
+		delete(open, t.TransactionID)
+		m.Matched++
+	}
+	for _, t := range settled {
+		if _, ok := open[t.TransactionID]; ok {
+			m.UnmatchedTransactions = append(m.UnmatchedTransactions, t)
+		}
+	}
+	return m, nil
+}
+
+// ParseStatementCSV reads a bank statement with a header row naming the
+// reference, amount and date columns, in any order. Dates are YYYY-MM-DD.
+func ParseStatementCSV(r io.Reader) ([]model.StatementLine, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrInvalidStatement
+	}
+
+	col := map[string]int{"reference": -1, "amount": -1, "date": -1}
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "ref" {
+			h = "reference"
+		}
+		if _, ok := col[h]; ok {
+			col[h] = i
+		}
+	}
+	for _, i := range col {
+		if i < 0 {
+			return nil, ErrInvalidStatement
+		}
+	}
+
+	lines := make([]model.StatementLine, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		amount, err := strconv.ParseFloat(strings.TrimSpace(row[col["amount"]]), 64)
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[col["date"]]))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, model.StatementLine{
+			Reference: strings.TrimSpace(row[col["reference"]]),
+			Amount:    amount,
+			Date:      date,
+		})
+	}
+	return lines, nil
+}
+
+// DayRange turns the inclusive dates from and to, given as YYYY-MM-DD in
+// loc, into the half-open range Reconcile expects. An empty from means
+// yesterday and an empty to means the same day as from.
+func DayRange(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	var start time.Time
+	if from == "" {
+		now := time.Now().In(loc)
+		start = time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, loc)
+	} else {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDate
+		}
+		start = t
+	}
+	end := start
+	if to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDate
+		}
+		end = t
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+	return start, end, nil
+}
+
+// bankOf is the bank a payment was made through: the paying institute, or
+// the bank named on the payment when no institute was given.
+func bankOf(t model.Trnasction) string {
+	if t.FromInstituteID != "" {
+		return t.FromInstituteID
+	}
+	return t.BankId
+}
+
+func addLine(lines map[string]*model.ReconciliationLine, id string, t model.Trnasction) {
+	l, ok := lines[id]
+	if !ok {
+		l = &model.ReconciliationLine{InstituteID: id}
+		lines[id] = l
+	}
+	l.Count++
+	l.Amount += t.Amount
+	if t.PaymentState == model.PaymentRefunded {
+		l.Refunded += t.Amount
+	}
+}
+
+func sortedLines(lines map[string]*model.ReconciliationLine) []model.ReconciliationLine {
+	ids := make([]string, 0, len(lines))
+	for id := range lines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := make([]model.ReconciliationLine, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, *lines[id])
+	}
+	return out
+}
+
+func NewReconciliationHandler() ReconciliationHandler{
+	return ReconciliationHandler{repo: repo}
+}
@@ -0,0 +1,189 @@
+package logic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+var reconDay = time.Date(2030, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// newTestReconciliation stores settled payments through two banks to two
+// institutes, one refunded, one short paid and one settled the next day.
+func newTestReconciliation(t *testing.T) ReconciliationHandler {
+	r := repository.NewMemoryRepository()
+	for _, tr := range []model.Trnasction{
+		{TransactionID: "T1", CUSDECNumber: "C1", FromInstituteID: "boc", ToInstituteID: "customs", AmountPayable: 100, Amount: 100, PaymentState: model.PaymentSettled, SettledAt: reconDay.Add(time.Hour)},
+		{TransactionID: "T2", CUSDECNumber: "C2", BankId: "hnb", ToInstituteID: "customs", AmountPayable: 50, Amount: 40, PaymentState: model.PaymentSettled, SettledAt: reconDay.Add(2 * time.Hour)},
+		{TransactionID: "T3", CUSDECNumber: "C3", FromInstituteID: "boc", ToInstituteID: "ports", Amount: 25, PaymentState: model.PaymentRefunded, SettledAt: reconDay.Add(3 * time.Hour)},
+		{TransactionID: "T4", CUSDECNumber: "C4", FromInstituteID: "boc", ToInstituteID: "customs", Amount: 999, PaymentState: model.PaymentSettled, SettledAt: reconDay.AddDate(0, 0, 1)},
+		{TransactionID: "T5", CUSDECNumber: "C5", FromInstituteID: "boc", ToInstituteID: "customs", Amount: 7, PaymentState: model.PaymentAuthorized},
+	} {
+		if err := r.SaveTransaction(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ReconciliationHandler{repo: r}
+}
+
+func TestReconcile(t *testing.T) {
+	h := newTestReconciliation(t)
+
+	r, err := h.Reconcile(reconDay, reconDay.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 3 || r.Amount != 165 {
+		t.Errorf("Reconcile counted %d payments of %v; want 3 of 165", r.Count, r.Amount)
+	}
+	wantBanks := []model.ReconciliationLine{{InstituteID: "boc", Count: 2, Amount: 125, Refunded: 25}, {InstituteID: "hnb", Count: 1, Amount: 40, Refunded: 0}}
+	if len(r.ByBank) != 2 || r.ByBank[0] != wantBanks[0] || r.ByBank[1] != wantBanks[1] {
+		t.Errorf("ByBank = %+v; want %+v", r.ByBank, wantBanks)
+	}
+	wantInstitutes := []model.ReconciliationLine{{InstituteID: "customs", Count: 2, Amount: 140, Refunded: 0}, {InstituteID: "ports", Count: 1, Amount: 25, Refunded: 25}}
+	if len(r.ByInstitute) != 2 || r.ByInstitute[0] != wantInstitutes[0] || r.ByInstitute[1] != wantInstitutes[1] {
+		t.Errorf("ByInstitute = %+v; want %+v", r.ByInstitute, wantInstitutes)
+	}
+	if len(r.Mismatches) != 1 || r.Mismatches[0].TransactionID != "T2" {
+		t.Errorf("Mismatches = %+v; want T2 only", r.Mismatches)
+	}
+
+	if _, err := h.Reconcile(reconDay, reconDay); err != ErrInvalidRange {
+		t.Errorf("Reconcile of an empty range = %v; want ErrInvalidRange", err)
+	}
+}
+
+func TestMatchStatement(t *testing.T) {
+	h := newTestReconciliation(t)
+	s := model.BankStatement{
+		BankID: "boc",
+		From:   reconDay,
+		To:     reconDay.AddDate(0, 0, 1),
+		CSV:    "Date,Amount,Ref\n2030-03-10,100.00,T1\n2030-03-10,30,C3\n",
+		Lines:  []model.StatementLine{{Reference: "X9", Amount: 1}},
+	}
+
+	m, err := h.MatchStatement(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Matched != 1 {
+		t.Errorf("matched %d lines; want 1", m.Matched)
+	}
+	if len(m.UnmatchedLines) != 2 || m.UnmatchedLines[0].Reference != "X9" || m.UnmatchedLines[1].Reference != "C3" {
+		t.Errorf("UnmatchedLines = %+v; want X9 and the short C3", m.UnmatchedLines)
+	}
+	if len(m.UnmatchedTransactions) != 1 || m.UnmatchedTransactions[0].TransactionID != "T3" {
+		t.Errorf("UnmatchedTransactions = %+v; want T3", m.UnmatchedTransactions)
+	}
+
+	s.BankID, s.CSV, s.Lines = "", "", []model.StatementLine{{Reference: "C2", Amount: 40}, {Reference: "T2", Amount: 40}}
+	if m, _ = h.MatchStatement(s); m.Matched != 1 || len(m.UnmatchedLines) != 1 || len(m.UnmatchedTransactions) != 2 {
+		t.Errorf("a payment matched twice: %+v", m)
+	}
+
+	blank := model.Trnasction{TransactionID: "T6", FromInstituteID: "boc", ToInstituteID: "customs", Amount: 55, PaymentState: model.PaymentSettled, SettledAt: reconDay.Add(4 * time.Hour)}
+	if err := h.repo.SaveTransaction(blank); err != nil {
+		t.Fatal(err)
+	}
+	s.BankID, s.Lines = "boc", []model.StatementLine{{Reference: "", Amount: 55}, {Reference: " ", Amount: 55}}
+	m, err = h.MatchStatement(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Matched != 0 || len(m.UnmatchedLines) != 2 {
+		t.Errorf("lines without a reference matched: %+v", m)
+	}
+	found := false
+	for _, tr := range m.UnmatchedTransactions {
+		found = found || tr.TransactionID == "T6"
+	}
+	if !found {
+		t.Errorf("UnmatchedTransactions = %+v; want the payment without a CUSDEC number", m.UnmatchedTransactions)
+	}
+
+	s.CSV = "Reference,Amount\nT1,100\n"
+	if _, err := h.MatchStatement(s); err != ErrInvalidStatement {
+		t.Errorf("MatchStatement without a date column = %v; want ErrInvalidStatement", err)
+	}
+	s.To = s.From
+	if _, err := h.MatchStatement(s); err != ErrInvalidRange {
+		t.Errorf("MatchStatement of an empty range = %v; want ErrInvalidRange", err)
+	}
+}
+
+func TestParseStatementCSV(t *testing.T) {
+	tests := []struct {
+		csv  string
+		ok   bool
+		name string
+	}{
+		{"reference,amount,date\nT1, 10.5 ,2030-03-10\n", true, "valid"},
+		{"", false, "empty"},
+		{"reference,amount\nT1,10\n", false, "missing column"},
+		{"reference,amount,date\nT1,ten,2030-03-10\n", false, "bad amount"},
+		{"reference,amount,date\nT1,10,10/03/2030\n", false, "bad date"},
+		{"reference,amount,date\nT1,10\n", false, "short row"},
+	}
+	for _, tt := range tests {
+		lines, err := ParseStatementCSV(strings.NewReader(tt.csv))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ParseStatementCSV = %v", tt.name, err)
+			continue
+		}
+		if tt.ok && (len(lines) != 1 || lines[0].Amount != 10.5 || !lines[0].Date.Equal(reconDay)) {
+			t.Errorf("%s: ParseStatementCSV = %+v", tt.name, lines)
+		}
+	}
+}
+
+func TestDayRange(t *testing.T) {
+	colombo := time.FixedZone("+0530", 5*3600+1800)
+	from, to, err := DayRange("2030-03-10", "2030-03-11", colombo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 3, 10, 0, 0, 0, 0, colombo); !from.Equal(want) || !to.Equal(want.AddDate(0, 0, 2)) {
+		t.Errorf("DayRange = %v, %v", from, to)
+	}
+	if from, to, _ = DayRange("", "", colombo); to.Sub(from) != 24*time.Hour || !to.After(time.Now().Add(-24*time.Hour)) {
+		t.Errorf("DayRange of yesterday = %v, %v", from, to)
+	}
+	if _, _, err := DayRange("10/03/2030", "", colombo); err != ErrInvalidDate {
+		t.Errorf("DayRange of a malformed date = %v; want ErrInvalidDate", err)
+	}
+	if _, _, err := DayRange("2030-03-10", "2030-03-09", colombo); err != ErrInvalidRange {
+		t.Errorf("DayRange ending before it starts = %v; want ErrInvalidRange", err)
+	}
+}
+
+func TestReconciliationJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reconciliation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := &ReconciliationJob{Dir: dir, Location: time.UTC, handler: newTestReconciliation(t)}
+	if err := j.RunDay(reconDay.Add(12 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "reconciliation-2030-03-10.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "boc,2,125.00,25.00") || !strings.Contains(string(b), "T2,C2,,50.00,40.00") {
+		t.Errorf("report is\n%s", b)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "reconciliation-2030-03-10.xlsx")); err != nil || fi.Size() == 0 {
+		t.Errorf("no workbook was written: %v", err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+}
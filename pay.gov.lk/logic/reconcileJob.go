@@ -0,0 +1,78 @@
+package logic
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReconciliationJob writes the reconciliation of every finished day to Dir
+// as reconciliation-YYYY-MM-DD.csv and .xlsx. A day whose files already
+// exist is skipped, so the job can be restarted at any time.
+type ReconciliationJob struct {
+	Dir      string
+	Location *time.Location
+
+	handler ReconciliationHandler
+}
+
+func NewReconciliationJob(dir string) *ReconciliationJob {
+	return &ReconciliationJob{Dir: dir, Location: time.Local, handler: NewReconciliationHandler()}
+}
+
+// RunDay reconciles the day containing day, in j.Location.
+func (j *ReconciliationJob) RunDay(day time.Time) error {
+	day = day.In(j.Location)
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, j.Location)
+	r, err := j.handler.Reconcile(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(j.Dir, "reconciliation-"+from.Format("2006-01-02"))
+	if err := writeReport(base+".csv", func(f *os.File) error { return WriteReconciliationCSV(f, r) }); err != nil {
+		return err
+	}
+	return writeReport(base+".xlsx", func(f *os.File) error { return WriteReconciliationXLSX(f, r) })
+}
+
+// Run reconciles yesterday whenever its report is missing, checking every
+// interval until stop is closed.
+func (j *ReconciliationJob) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		yesterday := time.Now().In(j.Location).AddDate(0, 0, -1)
+		name := filepath.Join(j.Dir, "reconciliation-"+yesterday.Format("2006-01-02")+".xlsx")
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			if err := j.RunDay(yesterday); err != nil {
+				log.Println("reconciliation:", err)
+			}
+		}
+		select {
+		case <-t.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// writeReport writes through a temporary file so that a half-written report
+// is never mistaken for a finished one.
+func writeReport(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
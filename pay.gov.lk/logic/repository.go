@@ -0,0 +1,13 @@
+package logic
+
+import (
+	"pay.gov.lk/repository"
+)
+
+var repo repository.Repository = repository.NewMemoryRepository()
+
+// UseRepository replaces the storage shared by all handlers. It should be
+// called once at startup, before the RESTful service starts serving.
+func UseRepository(r repository.Repository) {
+	repo = r
+}
@@ -0,0 +1,171 @@
+package logic
+
+import (
+	"fmt"
+	"pay.gov.lk/model"
+	"pay.gov.lk/repository"
+	"time"
+)
+
+// TransitionError is returned when a state change is not allowed from the
+// current state of an account or payment.
+type TransitionError struct {
+	Entity string
+	ID     string
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s %s cannot move from %q to %q", e.Entity, e.ID, e.From, e.To)
+}
+
+type stateMachine struct {
+	entity      string
+	transitions map[string][]string
+}
+
+var accountStates = stateMachine{
+	entity: "account",
+	transitions: map[string][]string{
+		model.AccountRegistered: {model.AccountConfirmed, model.AccountRejected},
+		model.AccountConfirmed:  {model.AccountActive},
+		model.AccountActive:     {model.AccountDeactivated},
+	},
+}
+
+var paymentStates = stateMachine{
+	entity: "payment",
+	transitions: map[string][]string{
+		model.PaymentInitiated:  {model.PaymentAuthorized, model.PaymentFailed},
+		model.PaymentAuthorized: {model.PaymentSettled, model.PaymentFailed},
+		model.PaymentSettled:    {model.PaymentRefunded},
+	},
+}
+
+func (m stateMachine) check(id, from, to string) error {
+	for _, s := range m.transitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return &TransitionError{Entity: m.entity, ID: id, From: from, To: to}
+}
+
+// transitionAccount moves an account to a new status and records who did it.
+func transitionAccount(r repository.Repository, id, to, actor string) error {
+	s, err := r.GetStatus(id)
+	if err != nil {
+		return err
+	}
+	if err := accountStates.check(id, s.Status, to); err != nil {
+		return err
+	}
+	if err := r.SwapStatus(id, s.Status, to); err != nil {
+		if err == repository.ErrStale {
+			// Another request changed the status first; report against it.
+			if s, err = r.GetStatus(id); err == nil {
+				err = &TransitionError{Entity: accountStates.entity, ID: id, From: s.Status, To: to}
+			}
+		}
+		return err
+	}
+	return audit(r, accountStates.entity, id, s.Status, to, actor)
+}
+
+// transitionPayment moves a payment to a new state and records who did it.
+func transitionPayment(r repository.Repository, id, to, actor string) (model.Trnasction, error) {
+	return changePayment(r, id, to, actor, func(t model.Trnasction) error {
+		return r.SwapPaymentState(id, t.PaymentState, to)
+	})
+}
+
+// reversePayment moves a payment to failed or refunded, rejects its
+// transaction and, when the payment was posted, posts entries that cancel it
+// so that the CUSDEC can be paid again.
+func reversePayment(r repository.Repository, id, to, reason, actor string) (model.Trnasction, error) {
+	return changePayment(r, id, to, actor, func(t model.Trnasction) error {
+		var entries []model.LedgerEntry
+		if t.State != model.TransactionRejected {
+			posted, err := r.GetLedger(t.CUSDECNumber)
+			if err != nil && err != repository.ErrNotFound {
+				return err
+			}
+			entries = reversal(t.CUSDECNumber, posted)
+		}
+		return r.ReversePayment(id, t.PaymentState, to, reason, entries)
+	})
+}
+
+// changePayment checks that a payment may move to a new state, lets swap
+// make the change and records who did it.
+func changePayment(r repository.Repository, id, to, actor string, swap func(model.Trnasction) error) (model.Trnasction, error) {
+	t, err := r.GetTransaction(id)
+	if err != nil {
+		return model.Trnasction{}, err
+	}
+	if err := paymentStates.check(id, t.PaymentState, to); err != nil {
+		return model.Trnasction{}, err
+	}
+	if err := swap(t); err != nil {
+		if err == repository.ErrStale {
+			if t, err = r.GetTransaction(id); err == nil {
+				err = &TransitionError{Entity: paymentStates.entity, ID: id, From: t.PaymentState, To: to}
+			}
+		}
+		return model.Trnasction{}, err
+	}
+	if err := audit(r, paymentStates.entity, id, t.PaymentState, to, actor); err != nil {
+		return model.Trnasction{}, err
+	}
+	return r.GetTransaction(id)
+}
+
+// reversal returns the entries that bring the open balance of every account
+// and institute in a CUSDEC ledger back to zero.
+func reversal(cusdec string, posted []model.LedgerEntry) []model.LedgerEntry {
+	type party struct{ account, institute string }
+	var order []party
+	net := make(map[party]float64)
+	for _, e := range posted {
+		k := party{e.AccountID, e.InstituteID}
+		if _, ok := net[k]; !ok {
+			order = append(order, k)
+		}
+		net[k] += e.Debit - e.Credit
+	}
+
+	now := time.Now()
+	entries := make([]model.LedgerEntry, 0, len(order))
+	for _, k := range order {
+		e := model.LedgerEntry{
+			EntryID:      newID(),
+			CUSDECNumber: cusdec,
+			AccountID:    k.account,
+			InstituteID:  k.institute,
+			Timestamp:    now,
+		}
+		switch n := net[k]; {
+		case n > 0:
+			e.Credit = n
+		case n < 0:
+			e.Debit = -n
+		default:
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func audit(r repository.Repository, entity, id, from, to, actor string) error {
+	return r.AddAudit(model.AuditRecord{
+		RecordID:  newID(),
+		Entity:    entity,
+		EntityID:  id,
+		From:      from,
+		To:        to,
+		Actor:     actor,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,28 @@
+package logic
+
+import (
+	"github.com/twinj/uuid"
+)
+
+func newID() string {
+	return uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen)
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// page clamps the offset and limit asked for by a list endpoint.
+func page(offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return offset, limit
+}
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"fmt"
+	"time"
+)
+
 type Account struct {
 	Number 			string
 	Type 			string
@@ -11,6 +16,58 @@ type Account struct {
 	NameonCard 		string
 	Expiry 			string
 	DisplayName 	string
+	Status 			string
+	Email			string
+	Phone			string
+	CardToken		string
+	CardCipher		string	`json:"-"`
+	// Owner is the payer who registered the account; set by AddAccount.
+	Owner			string
+}
+
+// String redacts the card fields so that an Account can be logged safely,
+// before and after tokenization.
+func (a Account) String() string {
+	type plain Account
+	r := plain(a)
+	r.CardNumber = MaskPAN(a.CardNumber)
+	if r.NameonCard != "" {
+		r.NameonCard = "[redacted]"
+	}
+	if r.Expiry != "" {
+		r.Expiry = "[redacted]"
+	}
+	if r.CardCipher != "" {
+		r.CardCipher = "[redacted]"
+	}
+	return fmt.Sprintf("%+v", r)
+}
+
+func (a Account) GoString() string {
+	return "model.Account" + a.String()
+}
+
+// MaskPAN keeps only the last four digits of a card number. Masking a
+// masked number returns it unchanged.
+func MaskPAN(pan string) string {
+	digits := make([]byte, 0, len(pan))
+	for i := 0; i < len(pan); i++ {
+		if pan[i] >= '0' && pan[i] <= '9' || pan[i] == '*' {
+			digits = append(digits, pan[i])
+		}
+	}
+	if len(digits) <= 4 {
+		return string(digits)
+	}
+	masked := make([]byte, len(digits))
+	for i := range masked {
+		if i < len(digits)-4 {
+			masked[i] = '*'
+		} else {
+			masked[i] = digits[i]
+		}
+	}
+	return string(masked)
 }
 
 
@@ -30,6 +87,7 @@ type PaymentInfo struct {
 	AmountPayable	float64
 	AmountToPay		float64
 	IsAccepted		bool
+	IdempotencyKey	string
 }
 
 type PrintDocument struct {
@@ -38,6 +96,12 @@ type PrintDocument struct {
 	Body 	map[string] interface{}
 }
 
+// StateChange is posted to the endpoints that move an account or payment to
+// a new state. Reason is kept on failed and refunded payments.
+type StateChange struct {
+	Reason			string
+}
+
 type ConfirmedDetails struct {
 	AccountID		string
 	IsVerified		bool
@@ -46,9 +110,170 @@ type ConfirmedDetails struct {
 type Trnasction struct {
 	TransactionID	string
 	IsVerified		bool
+	IdempotencyKey	string
+	CUSDECNumber	string
+	AccountID		string
+	ToInstituteID	string
+	FromInstituteID	string
+	BankId			string
+	AmountPayable	float64
+	Amount			float64
+	State			string
+	PaymentState	string
+	Reason			string
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+	SettledAt		time.Time
 }
 
 type Institute struct {
 	InstituteID 		string
 	InstituteName		string
-}
\ No newline at end of file
+	Type				string
+}
+
+type InstitutePage struct {
+	Total			int
+	Offset			int
+	Limit			int
+	Items			[]Institute
+}
+
+type AccountPage struct {
+	Total			int
+	Offset			int
+	Limit			int
+	Items			[]Account
+}
+
+type LedgerEntry struct {
+	EntryID			string
+	CUSDECNumber	string
+	AccountID		string
+	InstituteID		string
+	Debit			float64
+	Credit			float64
+	Timestamp		time.Time
+}
+
+const (
+	AccountRegistered	= "registered"
+	AccountConfirmed	= "bank-confirmed"
+	AccountRejected		= "rejected"
+	AccountActive		= "active"
+	AccountDeactivated	= "deactivated"
+)
+
+const (
+	InstituteBank		= "bank"
+	InstituteCustoms	= "customs"
+)
+
+const (
+	TransactionPending	= "pending"
+	TransactionAccepted	= "accepted"
+	TransactionRejected	= "rejected"
+)
+
+const (
+	PaymentInitiated	= "initiated"
+	PaymentAuthorized	= "authorized"
+	PaymentSettled		= "settled"
+	PaymentFailed		= "failed"
+	PaymentRefunded		= "refunded"
+)
+
+type AuditRecord struct {
+	RecordID		string
+	Entity			string
+	EntityID		string
+	From			string
+	To				string
+	Actor			string
+	Timestamp		time.Time
+}
+
+type DocumentVerification struct {
+	DocumentID		string
+	IsValid			bool
+	Status			string
+}
+
+type Notification struct {
+	NotificationID	string
+	Event			string
+	Channel			string
+	To				string
+	Subject			string
+	Body			string
+	State			string
+	Attempts		int
+	LastError		string
+	NextAttempt		time.Time
+	CreatedAt		time.Time
+}
+
+const (
+	NotificationQueued	= "queued"
+	NotificationSent	= "sent"
+	NotificationFailed	= "failed"
+)
+
+type TokenRequest struct {
+	User			string
+	Password		string
+}
+
+type IssuedToken struct {
+	Token			string
+	User			string
+	Roles			[]string
+}
+
+type ReconciliationLine struct {
+	InstituteID		string
+	Count			int
+	Amount			float64
+	Refunded		float64
+}
+
+type AmountMismatch struct {
+	TransactionID	string
+	CUSDECNumber	string
+	AccountID		string
+	AmountPayable	float64
+	AmountToPay		float64
+}
+
+type Reconciliation struct {
+	From			time.Time
+	To				time.Time
+	Count			int
+	Amount			float64
+	ByBank			[]ReconciliationLine
+	ByInstitute		[]ReconciliationLine
+	Mismatches		[]AmountMismatch
+}
+
+type StatementLine struct {
+	Reference		string
+	Amount			float64
+	Date			time.Time
+}
+
+type BankStatement struct {
+	BankID			string
+	From			time.Time
+	To				time.Time
+	CSV				string
+	Lines			[]StatementLine
+}
+
+type StatementMatch struct {
+	BankID					string
+	From					time.Time
+	To						time.Time
+	Matched					int
+	UnmatchedLines			[]StatementLine
+	UnmatchedTransactions	[]Trnasction
+}
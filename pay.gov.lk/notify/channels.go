@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"github.com/subosito/twilio"
+	"gopkg.in/gomail.v2-unstable"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// EmailChannel sends notifications through an SMTP server.
+type EmailChannel struct {
+	From   string
+	dialer *gomail.Dialer
+}
+
+// NewEmailChannel takes the server as host:port, as found in the
+// Smtpserver setting.
+func NewEmailChannel(server, username, password, from string) (*EmailChannel, error) {
+	host, p, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailChannel{From: from, dialer: gomail.NewPlainDialer(host, port, username, password)}, nil
+}
+
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+func (c *EmailChannel) Send(to, subject, body string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", c.From)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
+	return c.dialer.DialAndSend(m)
+}
+
+// SMSChannel sends notifications as text messages through Twilio. The
+// subject is not sent.
+type SMSChannel struct {
+	From   string
+	client *twilio.Client
+}
+
+func NewSMSChannel(accountSid, authToken, from string) *SMSChannel {
+	return &SMSChannel{From: from, client: twilio.NewClient(accountSid, authToken, nil)}
+}
+
+func (c *SMSChannel) Name() string {
+	return "sms"
+}
+
+func (c *SMSChannel) Send(to, subject, body string) error {
+	_, _, err := c.client.Messages.SendSMS(c.From, to, body)
+	return err
+}
+
+// SentMessage is a message recorded by FakeChannel.
+type SentMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// FakeChannel records messages instead of sending them. Set Err to make
+// every Send fail, e.g. to exercise the outbox retries.
+type FakeChannel struct {
+	ChannelName string
+	Err         error
+
+	mu   sync.Mutex
+	sent []SentMessage
+}
+
+func NewFakeChannel(name string) *FakeChannel {
+	return &FakeChannel{ChannelName: name}
+}
+
+func (c *FakeChannel) Name() string {
+	return c.ChannelName
+}
+
+func (c *FakeChannel) Send(to, subject, body string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Err != nil {
+		return c.Err
+	}
+	c.sent = append(c.sent, SentMessage{to, subject, body})
+	return nil
+}
+
+func (c *FakeChannel) Sent() []SentMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SentMessage(nil), c.sent...)
+}
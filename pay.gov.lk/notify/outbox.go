@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"fmt"
+	"github.com/twinj/uuid"
+	"log"
+	"pay.gov.lk/model"
+	"time"
+)
+
+const (
+	EventAccountConfirmed = "account.confirmed"
+	EventPaymentSettled   = "payment.settled"
+)
+
+// Channel delivers a rendered message to one address, e.g. an email address
+// or a phone number.
+type Channel interface {
+	Name() string
+	Send(to, subject, body string) error
+}
+
+// Store keeps the outbox. repository.Repository satisfies it.
+type Store interface {
+	SaveNotification(n model.Notification) error
+	UpdateNotification(n model.Notification) error
+	DueNotifications(now time.Time, limit int) ([]model.Notification, error)
+}
+
+// Outbox queues notifications in a Store and delivers them through the
+// registered channels. Messages that fail are retried with exponential
+// backoff until MaxAttempts is reached, so a channel that is down for a while
+// does not lose anything.
+type Outbox struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	BatchSize   int
+
+	store     Store
+	channels  map[string]Channel
+	templates *Templates
+}
+
+func NewOutbox(store Store) *Outbox {
+	return &Outbox{
+		MaxAttempts: 8,
+		Backoff:     30 * time.Second,
+		BatchSize:   100,
+		store:       store,
+		channels:    make(map[string]Channel),
+		templates:   DefaultTemplates(),
+	}
+}
+
+func (o *Outbox) Register(c Channel) {
+	o.channels[c.Name()] = c
+}
+
+func (o *Outbox) Templates() *Templates {
+	return o.templates
+}
+
+// Notify renders event for every registered channel that has both a
+// template and an address in to (keyed by channel name) and queues the
+// results. Delivery happens on the next Flush.
+func (o *Outbox) Notify(event string, to map[string]string, data interface{}) error {
+	now := time.Now()
+	for name := range o.channels {
+		addr := to[name]
+		if addr == "" || !o.templates.Has(event, name) {
+			continue
+		}
+		subject, body, err := o.templates.Render(event, name, data)
+		if err != nil {
+			return err
+		}
+		n := model.Notification{
+			NotificationID: uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen),
+			Event:          event,
+			Channel:        name,
+			To:             addr,
+			Subject:        subject,
+			Body:           body,
+			State:          model.NotificationQueued,
+			NextAttempt:    now,
+			CreatedAt:      now,
+		}
+		if err := o.store.SaveNotification(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush tries to deliver every notification that is due and returns how
+// many were sent.
+func (o *Outbox) Flush() (int, error) {
+	due, err := o.store.DueNotifications(time.Now(), o.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, n := range due {
+		// A channel that is no longer configured counts as a failed attempt,
+		// so that its messages back off instead of filling every batch. They
+		// are still sent if the channel comes back before they give up.
+		err := fmt.Errorf("no %s channel is registered", n.Channel)
+		if c, ok := o.channels[n.Channel]; ok {
+			err = c.Send(n.To, n.Subject, n.Body)
+		}
+
+		n.Attempts++
+		if err != nil {
+			n.LastError = err.Error()
+			if n.Attempts >= o.MaxAttempts {
+				n.State = model.NotificationFailed
+				log.Println("notify: giving up on", n.NotificationID, "after", n.Attempts, "attempts:", err)
+			} else {
+				n.NextAttempt = time.Now().Add(o.Backoff << uint(n.Attempts-1))
+			}
+		} else {
+			n.State = model.NotificationSent
+			n.LastError = ""
+			sent++
+		}
+		if err := o.store.UpdateNotification(n); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// Run flushes the outbox every interval until stop is closed.
+func (o *Outbox) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := o.Flush(); err != nil {
+			log.Println("notify:", err)
+		}
+		select {
+		case <-t.C:
+		case <-stop:
+			return
+		}
+	}
+}
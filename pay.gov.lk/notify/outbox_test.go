@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"errors"
+	"pay.gov.lk/model"
+	"sort"
+	"testing"
+	"time"
+)
+
+// memStore is a Store that keeps every notification, whatever its state.
+type memStore map[string]model.Notification
+
+func (s memStore) SaveNotification(n model.Notification) error {
+	s[n.NotificationID] = n
+	return nil
+}
+
+func (s memStore) UpdateNotification(n model.Notification) error {
+	s[n.NotificationID] = n
+	return nil
+}
+
+func (s memStore) DueNotifications(now time.Time, limit int) ([]model.Notification, error) {
+	var due []model.Notification
+	for _, n := range s {
+		if n.State == model.NotificationQueued && !n.NextAttempt.After(now) {
+			due = append(due, n)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s memStore) only(t *testing.T) model.Notification {
+	if len(s) != 1 {
+		t.Fatalf("outbox holds %d notifications; want 1", len(s))
+	}
+	for _, n := range s {
+		return n
+	}
+	panic("unreachable")
+}
+
+var settled = model.Trnasction{TransactionID: "T1", CUSDECNumber: "C1", Amount: 12.5}
+
+func TestNotify(t *testing.T) {
+	s := memStore{}
+	o := NewOutbox(s)
+	email, sms := NewFakeChannel("email"), NewFakeChannel("sms")
+	o.Register(email)
+	o.Register(sms)
+
+	if err := o.Notify(EventPaymentSettled, map[string]string{"email": "a@example.com", "push": "x"}, settled); err != nil {
+		t.Fatal(err)
+	}
+	n := s.only(t)
+	if n.Channel != "email" || n.Subject != "Payment settled for CUSDEC C1" || n.State != model.NotificationQueued {
+		t.Errorf("queued %+v", n)
+	}
+	if len(email.Sent()) != 0 {
+		t.Error("Notify sent before Flush")
+	}
+
+	if sent, err := o.Flush(); sent != 1 || err != nil {
+		t.Fatalf("Flush = %d, %v; want 1", sent, err)
+	}
+	if got := email.Sent(); len(got) != 1 || got[0].To != "a@example.com" {
+		t.Errorf("email sent %+v", got)
+	}
+	if len(sms.Sent()) != 0 {
+		t.Error("sms was sent without an address")
+	}
+	if n := s.only(t); n.State != model.NotificationSent || n.Attempts != 1 {
+		t.Errorf("notification is %s after %d attempts; want sent after 1", n.State, n.Attempts)
+	}
+	if sent, _ := o.Flush(); sent != 0 {
+		t.Errorf("second Flush sent %d", sent)
+	}
+
+	if err := o.Notify("unknown.event", map[string]string{"email": "a@example.com"}, nil); err != nil || len(s) != 1 {
+		t.Errorf("Notify of an event without templates = %v, outbox holds %d", err, len(s))
+	}
+}
+
+func TestFlushRetries(t *testing.T) {
+	s := memStore{}
+	o := NewOutbox(s)
+	o.MaxAttempts = 3
+	o.Backoff = time.Hour
+	c := NewFakeChannel("sms")
+	c.Err = errors.New("gateway down")
+	o.Register(c)
+
+	if err := o.Notify(EventPaymentSettled, map[string]string{"sms": "+9470"}, settled); err != nil {
+		t.Fatal(err)
+	}
+	if sent, err := o.Flush(); sent != 0 || err != nil {
+		t.Fatalf("Flush = %d, %v; want 0", sent, err)
+	}
+	n := s.only(t)
+	if n.State != model.NotificationQueued || n.Attempts != 1 || n.LastError != "gateway down" {
+		t.Errorf("notification is %s after %d attempts, %q", n.State, n.Attempts, n.LastError)
+	}
+	if d := n.NextAttempt.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("next attempt in %v; want an hour", d)
+	}
+	if o.Flush(); s.only(t).Attempts != 1 {
+		t.Error("Flush retried before the backoff elapsed")
+	}
+
+	// Make it due again until it runs out of attempts.
+	for attempt := 2; attempt <= 3; attempt++ {
+		n := s.only(t)
+		n.NextAttempt = time.Now()
+		s[n.NotificationID] = n
+		o.Flush()
+	}
+	if n := s.only(t); n.State != model.NotificationFailed || n.Attempts != 3 {
+		t.Errorf("notification is %s after %d attempts; want failed after 3", n.State, n.Attempts)
+	}
+
+	c.Err = nil
+	if sent, _ := o.Flush(); sent != 0 || len(c.Sent()) != 0 {
+		t.Error("Flush retried a failed notification")
+	}
+}
+
+func TestFlushUnregisteredChannel(t *testing.T) {
+	s := memStore{}
+	o := NewOutbox(s)
+	o.BatchSize = 2
+	o.Backoff = time.Hour
+	email := NewFakeChannel("email")
+	o.Register(email)
+
+	// Queued for an SMS channel that was configured before a restart.
+	past := time.Now().Add(-time.Minute)
+	for _, id := range []string{"S1", "S2", "S3"} {
+		s[id] = model.Notification{NotificationID: id, Channel: "sms", To: "+9470", State: model.NotificationQueued, NextAttempt: past, CreatedAt: past}
+	}
+	if err := o.Notify(EventPaymentSettled, map[string]string{"email": "a@example.com"}, settled); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := 0
+	for i := 0; i < 2; i++ {
+		n, err := o.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent += n
+	}
+	if sent != 1 || len(email.Sent()) != 1 {
+		t.Errorf("sent %d behind a full batch of unregistered channel messages; want 1", sent)
+	}
+	for _, id := range []string{"S1", "S2", "S3"} {
+		n := s[id]
+		if n.State != model.NotificationQueued || n.Attempts != 1 || n.LastError == "" || !n.NextAttempt.After(time.Now()) {
+			t.Errorf("%s is %s after %d attempts, %q, next at %v; want queued and backed off", id, n.State, n.Attempts, n.LastError, n.NextAttempt)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	tm := DefaultTemplates()
+	events := []string{EventAccountConfirmed, EventPaymentSettled}
+	for _, e := range events {
+		for _, ch := range []string{"email", "sms"} {
+			if !tm.Has(e, ch) {
+				t.Errorf("no default template for %s on %s", e, ch)
+			}
+		}
+	}
+
+	_, body, err := tm.Render(EventAccountConfirmed, "sms", model.Account{Number: "A1"})
+	if err != nil || body != "pay.gov.lk: account A1 has been confirmed by your bank." {
+		t.Errorf("Render = %q, %v", body, err)
+	}
+	if _, _, err := tm.Render(EventPaymentSettled, "sms", struct{}{}); err == nil {
+		t.Error("Render accepted data without the fields the template uses")
+	}
+
+	if err := tm.Set("x", "email", "{{", ""); err == nil {
+		t.Error("Set accepted a malformed template")
+	}
+	if err := tm.Set("x", "email", "Hi {{.}}", "{{.}}"); err != nil {
+		t.Fatal(err)
+	}
+	subject, _, _ := tm.Render("x", "email", "there")
+	if subject != "Hi there" {
+		t.Errorf("custom subject = %q", subject)
+	}
+
+}
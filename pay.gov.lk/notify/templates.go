@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"bytes"
+	"sync"
+	"text/template"
+)
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// Templates holds the subject and body templates of each event, per
+// channel. They are text/template templates executed with the data passed
+// to Outbox.Notify.
+type Templates struct {
+	mu    sync.RWMutex
+	byKey map[string]messageTemplate
+}
+
+func NewTemplates() *Templates {
+	return &Templates{byKey: make(map[string]messageTemplate)}
+}
+
+// DefaultTemplates returns the built-in email and SMS messages for account
+// confirmation and payment settlement.
+func DefaultTemplates() *Templates {
+	t := NewTemplates()
+	t.mustSet(EventAccountConfirmed, "email", "Your pay.gov.lk account has been confirmed",
+		"Dear {{.Name}},\n\nYour bank has confirmed account {{.Number}}. You can now use it to pay customs declarations.\n")
+	t.mustSet(EventAccountConfirmed, "sms", "",
+		"pay.gov.lk: account {{.Number}} has been confirmed by your bank.")
+	t.mustSet(EventPaymentSettled, "email", "Payment settled for CUSDEC {{.CUSDECNumber}}",
+		"Your payment of {{printf \"%.2f\" .Amount}} for customs declaration {{.CUSDECNumber}} has been settled.\nTransaction: {{.TransactionID}}\n")
+	t.mustSet(EventPaymentSettled, "sms", "",
+		"pay.gov.lk: payment of {{printf \"%.2f\" .Amount}} for CUSDEC {{.CUSDECNumber}} settled. Ref {{.TransactionID}}")
+	return t
+}
+
+// Set replaces the template of event on channel.
+func (t *Templates) Set(event, channel, subject, body string) error {
+	s, err := template.New(event + ".subject").Parse(subject)
+	if err != nil {
+		return err
+	}
+	b, err := template.New(event + ".body").Parse(body)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.byKey[event+"/"+channel] = messageTemplate{s, b}
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Templates) mustSet(event, channel, subject, body string) {
+	if err := t.Set(event, channel, subject, body); err != nil {
+		panic(err)
+	}
+}
+
+func (t *Templates) Has(event, channel string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.byKey[event+"/"+channel]
+	return ok
+}
+
+func (t *Templates) Render(event, channel string, data interface{}) (string, string, error) {
+	t.mu.RLock()
+	m := t.byKey[event+"/"+channel]
+	t.mu.RUnlock()
+
+	var subject, body bytes.Buffer
+	if err := m.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := m.body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
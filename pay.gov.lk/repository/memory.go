@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"pay.gov.lk/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	accounts map[string]model.Account
+	ledger   map[string][]model.LedgerEntry
+	posted   map[string]bool
+
+	transactions map[string]model.Trnasction
+	keys         map[string]string
+
+	institutes map[string]model.Institute
+
+	notifications map[string]model.Notification
+
+	audit []model.AuditRecord
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		accounts: make(map[string]model.Account),
+		ledger:   make(map[string][]model.LedgerEntry),
+		posted:   make(map[string]bool),
+
+		transactions: make(map[string]model.Trnasction),
+		keys:         make(map[string]string),
+
+		institutes: make(map[string]model.Institute),
+
+		notifications: make(map[string]model.Notification),
+	}
+}
+
+func (r *MemoryRepository) SaveAccount(a model.Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[a.Number]; ok {
+		return ErrDuplicate
+	}
+	r.accounts[a.Number] = a
+	return nil
+}
+
+func (r *MemoryRepository) GetAccount(number string) (model.Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.accounts[number]
+	if !ok {
+		return model.Account{}, ErrNotFound
+	}
+	return a, nil
+}
+
+func (r *MemoryRepository) ListAccounts(f AccountFilter, offset, limit int) ([]model.Account, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := make([]model.Account, 0)
+	for _, a := range r.accounts {
+		if f.Status != "" && a.Status != f.Status {
+			continue
+		}
+		if f.Name != "" && !containsFold(a.Name, f.Name) && !containsFold(a.DisplayName, f.Name) {
+			continue
+		}
+		found = append(found, a)
+	}
+	sort.Sort(byNumber(found))
+
+	lo, hi := window(len(found), offset, limit)
+	return found[lo:hi], len(found), nil
+}
+
+func (r *MemoryRepository) GetStatus(number string) (model.AccountStatus, error) {
+	a, err := r.GetAccount(number)
+	if err != nil {
+		return model.AccountStatus{}, err
+	}
+	return model.AccountStatus{Number: a.Number, Status: a.Status}, nil
+}
+
+func (r *MemoryRepository) SetStatus(s model.AccountStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[s.Number]
+	if !ok {
+		return ErrNotFound
+	}
+	a.Status = s.Status
+	r.accounts[s.Number] = a
+	return nil
+}
+
+func (r *MemoryRepository) SwapStatus(number, from, to string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[number]
+	if !ok {
+		return ErrNotFound
+	}
+	if a.Status != from {
+		return ErrStale
+	}
+	a.Status = to
+	r.accounts[number] = a
+	return nil
+}
+
+func (r *MemoryRepository) PostLedger(cusdec string, entries []model.LedgerEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.posted[cusdec] {
+		return ErrDuplicate
+	}
+	r.posted[cusdec] = true
+	r.ledger[cusdec] = append(r.ledger[cusdec], entries...)
+	return nil
+}
+
+func (r *MemoryRepository) GetLedger(cusdec string) ([]model.LedgerEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries, ok := r.ledger[cusdec]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]model.LedgerEntry(nil), entries...), nil
+}
+
+func (r *MemoryRepository) SaveTransaction(t model.Trnasction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.transactions[t.TransactionID]; ok {
+		return ErrDuplicate
+	}
+	if t.IdempotencyKey != "" {
+		if _, ok := r.keys[t.IdempotencyKey]; ok {
+			return ErrDuplicate
+		}
+		r.keys[t.IdempotencyKey] = t.TransactionID
+	}
+	r.transactions[t.TransactionID] = t
+	return nil
+}
+
+func (r *MemoryRepository) UpdateTransaction(t model.Trnasction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.transactions[t.TransactionID]
+	if !ok {
+		return ErrNotFound
+	}
+	// The idempotency key is fixed once the transaction is created.
+	t.IdempotencyKey = old.IdempotencyKey
+	t.PaymentState = old.PaymentState
+	t.SettledAt = old.SettledAt
+	r.transactions[t.TransactionID] = t
+	return nil
+}
+
+func (r *MemoryRepository) GetTransaction(id string) (model.Trnasction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.transactions[id]
+	if !ok {
+		return model.Trnasction{}, ErrNotFound
+	}
+	return t, nil
+}
+
+func (r *MemoryRepository) GetTransactionByKey(key string) (model.Trnasction, error) {
+	r.mu.RLock()
+	id, ok := r.keys[key]
+	r.mu.RUnlock()
+
+	if !ok {
+		return model.Trnasction{}, ErrNotFound
+	}
+	return r.GetTransaction(id)
+}
+
+func (r *MemoryRepository) GetTransactionsByCUSDEC(cusdec string) ([]model.Trnasction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := make([]model.Trnasction, 0)
+	for _, t := range r.transactions {
+		if t.CUSDECNumber == cusdec {
+			found = append(found, t)
+		}
+	}
+	sort.Sort(byCreated(found))
+	return found, nil
+}
+
+func (r *MemoryRepository) ListSettledTransactions(from, to time.Time) ([]model.Trnasction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := make([]model.Trnasction, 0)
+	for _, t := range r.transactions {
+		if !t.SettledAt.IsZero() && !t.SettledAt.Before(from) && t.SettledAt.Before(to) {
+			found = append(found, t)
+		}
+	}
+	sort.Sort(bySettled(found))
+	return found, nil
+}
+
+func (r *MemoryRepository) SwapPaymentState(id, from, to string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.transactions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.PaymentState != from {
+		return ErrStale
+	}
+	t.PaymentState = to
+	t.UpdatedAt = time.Now()
+	if to == model.PaymentSettled {
+		t.SettledAt = t.UpdatedAt
+	}
+	r.transactions[id] = t
+	return nil
+}
+
+func (r *MemoryRepository) ReversePayment(id, from, to, reason string, entries []model.LedgerEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.transactions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.PaymentState != from {
+		return ErrStale
+	}
+	t.PaymentState = to
+	t.State = model.TransactionRejected
+	t.Reason = reason
+	t.UpdatedAt = time.Now()
+	r.transactions[id] = t
+
+	if len(entries) > 0 {
+		r.ledger[t.CUSDECNumber] = append(r.ledger[t.CUSDECNumber], entries...)
+		delete(r.posted, t.CUSDECNumber)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) AddAudit(a model.AuditRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.audit = append(r.audit, a)
+	return nil
+}
+
+func (r *MemoryRepository) GetAudit(entity, id string) ([]model.AuditRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := make([]model.AuditRecord, 0)
+	for _, a := range r.audit {
+		if a.Entity == entity && a.EntityID == id {
+			found = append(found, a)
+		}
+	}
+	return found, nil
+}
+
+func (r *MemoryRepository) SaveInstitute(i model.Institute) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.institutes[i.InstituteID]; ok {
+		return ErrDuplicate
+	}
+	r.institutes[i.InstituteID] = i
+	return nil
+}
+
+func (r *MemoryRepository) UpdateInstitute(i model.Institute) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.institutes[i.InstituteID]; !ok {
+		return ErrNotFound
+	}
+	r.institutes[i.InstituteID] = i
+	return nil
+}
+
+func (r *MemoryRepository) DeleteInstitute(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.institutes[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.institutes, id)
+	return nil
+}
+
+func (r *MemoryRepository) GetInstitute(id string) (model.Institute, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.institutes[id]
+	if !ok {
+		return model.Institute{}, ErrNotFound
+	}
+	return i, nil
+}
+
+func (r *MemoryRepository) ListInstitutes(f InstituteFilter, offset, limit int) ([]model.Institute, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := make([]model.Institute, 0)
+	for _, i := range r.institutes {
+		if f.Type != "" && i.Type != f.Type {
+			continue
+		}
+		if f.Name != "" && !containsFold(i.InstituteName, f.Name) {
+			continue
+		}
+		found = append(found, i)
+	}
+	sort.Sort(byInstituteID(found))
+
+	lo, hi := window(len(found), offset, limit)
+	return found[lo:hi], len(found), nil
+}
+
+func (r *MemoryRepository) SaveNotification(n model.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.notifications[n.NotificationID]; ok {
+		return ErrDuplicate
+	}
+	r.notifications[n.NotificationID] = n
+	return nil
+}
+
+func (r *MemoryRepository) UpdateNotification(n model.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.notifications[n.NotificationID]; !ok {
+		return ErrNotFound
+	}
+	r.notifications[n.NotificationID] = n
+	return nil
+}
+
+func (r *MemoryRepository) DueNotifications(now time.Time, limit int) ([]model.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	due := make([]model.Notification, 0)
+	for _, n := range r.notifications {
+		if n.State == model.NotificationQueued && !n.NextAttempt.After(now) {
+			due = append(due, n)
+		}
+	}
+	sort.Sort(byQueued(due))
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// window clamps offset and limit to a slice of length n.
+func window(n, offset, limit int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	if offset+limit > n {
+		return offset, n
+	}
+	return offset, offset + limit
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+type byInstituteID []model.Institute
+
+func (a byInstituteID) Len() int           { return len(a) }
+func (a byInstituteID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byInstituteID) Less(i, j int) bool { return a[i].InstituteID < a[j].InstituteID }
+
+type byQueued []model.Notification
+
+func (a byQueued) Len() int           { return len(a) }
+func (a byQueued) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byQueued) Less(i, j int) bool { return a[i].CreatedAt.Before(a[j].CreatedAt) }
+
+type byCreated []model.Trnasction
+
+func (a byCreated) Len() int           { return len(a) }
+func (a byCreated) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCreated) Less(i, j int) bool { return a[i].CreatedAt.Before(a[j].CreatedAt) }
+
+type bySettled []model.Trnasction
+
+func (a bySettled) Len() int           { return len(a) }
+func (a bySettled) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a bySettled) Less(i, j int) bool { return a[i].SettledAt.Before(a[j].SettledAt) }
+
+type byNumber []model.Account
+
+func (a byNumber) Len() int           { return len(a) }
+func (a byNumber) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byNumber) Less(i, j int) bool { return a[i].Number < a[j].Number }
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"pay.gov.lk/model"
+	"testing"
+)
+
+func TestMemoryAccounts(t *testing.T) {
+	r := NewMemoryRepository()
+
+	if _, err := r.GetAccount("A1"); err != ErrNotFound {
+		t.Fatalf("GetAccount of a missing account = %v; want ErrNotFound", err)
+	}
+	if err := r.SaveAccount(model.Account{Number: "A1", Status: model.AccountRegistered}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SaveAccount(model.Account{Number: "A1", Status: model.AccountActive}); err != ErrDuplicate {
+		t.Fatalf("second SaveAccount = %v; want ErrDuplicate", err)
+	}
+	if s, _ := r.GetStatus("A1"); s.Status != model.AccountRegistered {
+		t.Errorf("duplicate SaveAccount changed the status to %q", s.Status)
+	}
+
+	if err := r.SwapStatus("A1", model.AccountConfirmed, model.AccountActive); err != ErrStale {
+		t.Errorf("SwapStatus from the wrong status = %v; want ErrStale", err)
+	}
+	if err := r.SwapStatus("A1", model.AccountRegistered, model.AccountConfirmed); err != nil {
+		t.Errorf("SwapStatus: %v", err)
+	}
+	if err := r.SwapStatus("A2", model.AccountRegistered, model.AccountConfirmed); err != ErrNotFound {
+		t.Errorf("SwapStatus of a missing account = %v; want ErrNotFound", err)
+	}
+}
+
+func TestMemoryLedger(t *testing.T) {
+	r := NewMemoryRepository()
+
+	if _, err := r.GetLedger("C1"); err != ErrNotFound {
+		t.Fatalf("GetLedger of an unpaid CUSDEC = %v; want ErrNotFound", err)
+	}
+	entries := []model.LedgerEntry{
+		{EntryID: "e1", CUSDECNumber: "C1", AccountID: "A1", Debit: 10},
+		{EntryID: "e2", CUSDECNumber: "C1", InstituteID: "customs", Credit: 10},
+	}
+	if err := r.PostLedger("C1", entries); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PostLedger("C1", entries); err != ErrDuplicate {
+		t.Fatalf("second PostLedger = %v; want ErrDuplicate", err)
+	}
+
+	got, err := r.GetLedger("C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("GetLedger returned %d entries; want 2", len(got))
+	}
+	got[0].Debit = 99
+	if again, _ := r.GetLedger("C1"); again[0].Debit != 10 {
+		t.Error("GetLedger returned the stored entries rather than a copy")
+	}
+}
+
+func TestMemoryTransactions(t *testing.T) {
+	r := NewMemoryRepository()
+
+	tr := model.Trnasction{TransactionID: "T1", IdempotencyKey: "k1", CUSDECNumber: "C1"}
+	if err := r.SaveTransaction(tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SaveTransaction(tr); err != ErrDuplicate {
+		t.Errorf("SaveTransaction with a used ID = %v; want ErrDuplicate", err)
+	}
+	if err := r.SaveTransaction(model.Trnasction{TransactionID: "T2", IdempotencyKey: "k1"}); err != ErrDuplicate {
+		t.Errorf("SaveTransaction with a used key = %v; want ErrDuplicate", err)
+	}
+	if got, err := r.GetTransactionByKey("k1"); err != nil || got.TransactionID != "T1" {
+		t.Errorf("GetTransactionByKey = %v, %v; want T1", got.TransactionID, err)
+	}
+	if _, err := r.GetTransactionByKey("k2"); err != ErrNotFound {
+		t.Errorf("GetTransactionByKey of an unused key = %v; want ErrNotFound", err)
+	}
+	if err := r.UpdateTransaction(model.Trnasction{TransactionID: "T9"}); err != ErrNotFound {
+		t.Errorf("UpdateTransaction of a missing transaction = %v; want ErrNotFound", err)
+	}
+}
+
+func TestMemoryPaymentState(t *testing.T) {
+	r := NewMemoryRepository()
+
+	tr := model.Trnasction{TransactionID: "T1", CUSDECNumber: "C1", State: model.TransactionAccepted, PaymentState: model.PaymentAuthorized}
+	if err := r.SaveTransaction(tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PostLedger("C1", []model.LedgerEntry{{EntryID: "e1", AccountID: "A1", Debit: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SwapPaymentState("T1", model.PaymentAuthorized, model.PaymentSettled); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.PaymentState = model.PaymentAuthorized
+	tr.Reason = "note"
+	if err := r.UpdateTransaction(tr); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := r.GetTransaction("T1")
+	if got.PaymentState != model.PaymentSettled || got.SettledAt.IsZero() || got.Reason != "note" {
+		t.Errorf("UpdateTransaction stored %s settled at %v, reason %q", got.PaymentState, got.SettledAt, got.Reason)
+	}
+
+	reversal := []model.LedgerEntry{{EntryID: "e2", AccountID: "A1", Credit: 10}}
+	if err := r.ReversePayment("T1", model.PaymentAuthorized, model.PaymentFailed, "late", reversal); err != ErrStale {
+		t.Errorf("ReversePayment from the wrong state = %v; want ErrStale", err)
+	}
+	if err := r.ReversePayment("T9", model.PaymentSettled, model.PaymentRefunded, "late", reversal); err != ErrNotFound {
+		t.Errorf("ReversePayment of a missing transaction = %v; want ErrNotFound", err)
+	}
+	if err := r.ReversePayment("T1", model.PaymentSettled, model.PaymentRefunded, "late", reversal); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = r.GetTransaction("T1")
+	if got.State != model.TransactionRejected || got.PaymentState != model.PaymentRefunded || got.Reason != "late" {
+		t.Errorf("reversed transaction is %s/%s %q", got.State, got.PaymentState, got.Reason)
+	}
+	if entries, _ := r.GetLedger("C1"); len(entries) != 2 {
+		t.Errorf("ledger has %d entries after the reversal; want 2", len(entries))
+	}
+	if err := r.PostLedger("C1", nil); err != nil {
+		t.Errorf("PostLedger after a reversal = %v", err)
+	}
+}
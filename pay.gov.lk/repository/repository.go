@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"errors"
+	"pay.gov.lk/model"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("record already exists")
+	ErrStale     = errors.New("record was changed by another request")
+)
+
+// AccountFilter narrows ListAccounts. Empty fields match everything; Name
+// matches a substring of the account or display name.
+type AccountFilter struct {
+	Status string
+	Name   string
+}
+
+// InstituteFilter narrows ListInstitutes. Name matches a substring.
+type InstituteFilter struct {
+	Type string
+	Name string
+}
+
+// Repository is the storage layer used by the logic handlers. The memory
+// implementation is meant for tests and local runs, the SQL one for
+// production.
+type Repository interface {
+	// SaveAccount inserts a new account. It fails with ErrDuplicate when the
+	// number is already registered.
+	SaveAccount(a model.Account) error
+	GetAccount(number string) (model.Account, error)
+	// ListAccounts returns one page of the accounts matching f, ordered by
+	// number, together with the total number of matches.
+	ListAccounts(f AccountFilter, offset, limit int) ([]model.Account, int, error)
+
+	GetStatus(number string) (model.AccountStatus, error)
+	SetStatus(s model.AccountStatus) error
+	// SwapStatus moves an account from one status to another and fails with
+	// ErrStale when the stored status is no longer from.
+	SwapStatus(number, from, to string) error
+
+	// PostLedger stores the entries of one payment. Entries are keyed by
+	// CUSDEC number and a CUSDEC number can only be posted once until the
+	// payment is reversed by ReversePayment.
+	PostLedger(cusdec string, entries []model.LedgerEntry) error
+	GetLedger(cusdec string) ([]model.LedgerEntry, error)
+
+	// SaveTransaction inserts a new transaction. It fails with ErrDuplicate
+	// when the transaction ID or a non-empty idempotency key is already used.
+	SaveTransaction(t model.Trnasction) error
+	// UpdateTransaction stores the details of a transaction. It leaves the
+	// payment state and settlement time alone; those only change through
+	// SwapPaymentState and ReversePayment.
+	UpdateTransaction(t model.Trnasction) error
+	GetTransaction(id string) (model.Trnasction, error)
+	GetTransactionByKey(key string) (model.Trnasction, error)
+	GetTransactionsByCUSDEC(cusdec string) ([]model.Trnasction, error)
+	// SwapPaymentState is the SwapStatus counterpart for payments. Moving a
+	// payment to settled also records its settlement time.
+	SwapPaymentState(id, from, to string) error
+	// ReversePayment moves a payment from one state to another like
+	// SwapPaymentState, marks the transaction rejected for reason and posts
+	// the reversing entries, all at once. When entries are given the CUSDEC
+	// number is released so that it can be paid again.
+	ReversePayment(id, from, to, reason string, entries []model.LedgerEntry) error
+	// ListSettledTransactions returns the transactions settled in
+	// [from, to), including those refunded since, ordered by settlement time.
+	ListSettledTransactions(from, to time.Time) ([]model.Trnasction, error)
+
+	SaveInstitute(i model.Institute) error
+	UpdateInstitute(i model.Institute) error
+	DeleteInstitute(id string) error
+	GetInstitute(id string) (model.Institute, error)
+	ListInstitutes(f InstituteFilter, offset, limit int) ([]model.Institute, int, error)
+
+	SaveNotification(n model.Notification) error
+	UpdateNotification(n model.Notification) error
+	// DueNotifications returns up to limit queued notifications whose next
+	// attempt is not after now, oldest first.
+	DueNotifications(now time.Time, limit int) ([]model.Notification, error)
+
+	AddAudit(a model.AuditRecord) error
+	GetAudit(entity, id string) ([]model.AuditRecord, error)
+}
@@ -0,0 +1,648 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"pay.gov.lk/model"
+	"strings"
+	"time"
+)
+
+// migrations are the steps that build the schema, oldest first. Each step
+// runs once and is recorded in schema_migrations; new columns and tables
+// must be added as a new step, never by editing an old one. Databases
+// created before migrations were recorded already have some of the columns
+// and indexes, so adding one that exists is not an error.
+var migrations = [][]string{
+	{
+		`CREATE TABLE IF NOT EXISTS accounts (
+			number VARCHAR(64) NOT NULL PRIMARY KEY,
+			type VARCHAR(32) NOT NULL DEFAULT '',
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			transaction_id VARCHAR(64) NOT NULL DEFAULT '',
+			card_number VARCHAR(255) NOT NULL DEFAULT '',
+			card_type VARCHAR(32) NOT NULL DEFAULT '',
+			name_on_card VARCHAR(255) NOT NULL DEFAULT '',
+			expiry VARCHAR(16) NOT NULL DEFAULT '',
+			display_name VARCHAR(255) NOT NULL DEFAULT '',
+			status VARCHAR(32) NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS ledger_entries (
+			entry_id VARCHAR(64) NOT NULL PRIMARY KEY,
+			cusdec_number VARCHAR(64) NOT NULL,
+			account_id VARCHAR(64) NOT NULL DEFAULT '',
+			institute_id VARCHAR(64) NOT NULL DEFAULT '',
+			debit DECIMAL(18,2) NOT NULL DEFAULT 0,
+			credit DECIMAL(18,2) NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			INDEX (cusdec_number)
+		)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS transactions (
+			transaction_id VARCHAR(64) NOT NULL PRIMARY KEY,
+			idempotency_key VARCHAR(128) NULL UNIQUE,
+			cusdec_number VARCHAR(64) NOT NULL,
+			account_id VARCHAR(64) NOT NULL DEFAULT '',
+			amount DECIMAL(18,2) NOT NULL DEFAULT 0,
+			state VARCHAR(32) NOT NULL,
+			reason VARCHAR(255) NOT NULL DEFAULT '',
+			is_verified BOOL NOT NULL DEFAULT FALSE,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			INDEX (cusdec_number)
+		)`,
+	},
+	{
+		`ALTER TABLE transactions ADD COLUMN payment_state VARCHAR(32) NOT NULL DEFAULT '' AFTER state`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			record_id VARCHAR(64) NOT NULL PRIMARY KEY,
+			entity VARCHAR(32) NOT NULL,
+			entity_id VARCHAR(64) NOT NULL,
+			from_state VARCHAR(32) NOT NULL DEFAULT '',
+			to_state VARCHAR(32) NOT NULL,
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			INDEX (entity, entity_id)
+		)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS institutes (
+			institute_id VARCHAR(64) NOT NULL PRIMARY KEY,
+			institute_name VARCHAR(255) NOT NULL DEFAULT '',
+			type VARCHAR(32) NOT NULL DEFAULT '',
+			INDEX (type)
+		)`,
+	},
+	{
+		`ALTER TABLE accounts ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE accounts ADD COLUMN phone VARCHAR(32) NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			notification_id VARCHAR(64) NOT NULL PRIMARY KEY,
+			event VARCHAR(64) NOT NULL,
+			channel VARCHAR(32) NOT NULL,
+			recipient VARCHAR(255) NOT NULL,
+			subject VARCHAR(255) NOT NULL DEFAULT '',
+			body TEXT NOT NULL,
+			state VARCHAR(16) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			last_error VARCHAR(1024) NOT NULL DEFAULT '',
+			next_attempt DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			INDEX (state, next_attempt)
+		)`,
+	},
+	{
+		`ALTER TABLE accounts ADD COLUMN card_token VARCHAR(64) NOT NULL DEFAULT ''`,
+		`ALTER TABLE accounts ADD COLUMN card_cipher VARCHAR(255) NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE transactions ADD COLUMN to_institute_id VARCHAR(64) NOT NULL DEFAULT '' AFTER account_id`,
+		`ALTER TABLE transactions ADD COLUMN from_institute_id VARCHAR(64) NOT NULL DEFAULT '' AFTER to_institute_id`,
+		`ALTER TABLE transactions ADD COLUMN bank_id VARCHAR(64) NOT NULL DEFAULT '' AFTER from_institute_id`,
+		`ALTER TABLE transactions ADD COLUMN amount_payable DECIMAL(18,2) NOT NULL DEFAULT 0 AFTER bank_id`,
+		`ALTER TABLE transactions ADD COLUMN settled_at DATETIME NULL`,
+		`ALTER TABLE transactions ADD INDEX settled_at (settled_at)`,
+	},
+	{
+		// One row per CUSDEC number with an open posting; the primary key
+		// is what stops a declaration from being paid twice.
+		`CREATE TABLE IF NOT EXISTS ledger_postings (
+			cusdec_number VARCHAR(64) NOT NULL PRIMARY KEY,
+			posted_at DATETIME NOT NULL
+		)`,
+		`INSERT IGNORE INTO ledger_postings (cusdec_number, posted_at)
+			SELECT cusdec_number, MIN(created_at) FROM ledger_entries GROUP BY cusdec_number`,
+	},
+	{
+		`ALTER TABLE accounts ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT ''`,
+	},
+}
+
+// SQLRepository stores accounts and ledger entries in MySQL.
+type SQLRepository struct {
+	db *sql.DB
+}
+
+// OpenSQLRepository opens a MySQL connection with the given DSN and brings
+// the schema up to date. parseTime and clientFoundRows are always enabled,
+// the latter so that SetStatus can tell a missing account from an unchanged
+// one.
+func OpenSQLRepository(dsn string) (*SQLRepository, error) {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("mysql", dsn+sep+"parseTime=true&clientFoundRows=true")
+	if err != nil {
+		return nil, err
+	}
+	r := NewSQLRepository(db)
+	if err := r.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+// Migrate applies the migrations that have not run yet.
+func (r *SQLRepository) Migrate() error {
+	_, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	if err := r.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		for _, stmt := range migrations[version] {
+			if _, err := r.db.Exec(stmt); err != nil && !alreadyMigrated(err) {
+				return fmt.Errorf("migration %d: %v", version+1, err)
+			}
+		}
+		if _, err := r.db.Exec("INSERT IGNORE INTO schema_migrations (version, applied_at) VALUES (?, ?)", version+1, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLRepository) Close() error {
+	return r.db.Close()
+}
+
+const accountColumns = "number, type, name, transaction_id, card_number, card_type, name_on_card, expiry, display_name, status, email, phone, card_token, card_cipher, owner"
+
+func (r *SQLRepository) SaveAccount(a model.Account) error {
+	_, err := r.db.Exec("INSERT INTO accounts ("+accountColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		a.Number, a.Type, a.Name, a.TransactionID, a.CardNumber, a.CardType, a.NameonCard, a.Expiry, a.DisplayName, a.Status, a.Email, a.Phone, a.CardToken, a.CardCipher, a.Owner)
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *SQLRepository) GetAccount(number string) (model.Account, error) {
+	row := r.db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE number = ?", number)
+	a, err := scanAccount(row)
+	if err == sql.ErrNoRows {
+		return model.Account{}, ErrNotFound
+	}
+	return a, err
+}
+
+func (r *SQLRepository) ListAccounts(f AccountFilter, offset, limit int) ([]model.Account, int, error) {
+	where, args := "WHERE 1 = 1", []interface{}{}
+	if f.Status != "" {
+		where += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	if f.Name != "" {
+		where += " AND (name LIKE ? OR display_name LIKE ?)"
+		args = append(args, like(f.Name), like(f.Name))
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM accounts "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query("SELECT "+accountColumns+" FROM accounts "+where+" ORDER BY number LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	found := make([]model.Account, 0)
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		found = append(found, a)
+	}
+	return found, total, rows.Err()
+}
+
+func (r *SQLRepository) GetStatus(number string) (model.AccountStatus, error) {
+	s := model.AccountStatus{Number: number}
+	err := r.db.QueryRow("SELECT status FROM accounts WHERE number = ?", number).Scan(&s.Status)
+	if err == sql.ErrNoRows {
+		return model.AccountStatus{}, ErrNotFound
+	}
+	return s, err
+}
+
+func (r *SQLRepository) SetStatus(s model.AccountStatus) error {
+	res, err := r.db.Exec("UPDATE accounts SET status = ? WHERE number = ?", s.Status, s.Number)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLRepository) SwapStatus(number, from, to string) error {
+	res, err := r.db.Exec("UPDATE accounts SET status = ? WHERE number = ? AND status = ?", to, number, from)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(res); err != ErrNotFound {
+		return err
+	}
+	if _, err := r.GetStatus(number); err != nil {
+		return err
+	}
+	return ErrStale
+}
+
+func (r *SQLRepository) PostLedger(cusdec string, entries []model.LedgerEntry) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO ledger_postings (cusdec_number, posted_at) VALUES (?, ?)", cusdec, time.Now().UTC())
+	if err != nil {
+		tx.Rollback()
+		if isDuplicate(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		_, err := tx.Exec("INSERT INTO ledger_entries (entry_id, cusdec_number, account_id, institute_id, debit, credit, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			e.EntryID, cusdec, e.AccountID, e.InstituteID, e.Debit, e.Credit, e.Timestamp.UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLRepository) GetLedger(cusdec string) ([]model.LedgerEntry, error) {
+	rows, err := r.db.Query("SELECT entry_id, cusdec_number, account_id, institute_id, debit, credit, created_at FROM ledger_entries WHERE cusdec_number = ? ORDER BY created_at, entry_id", cusdec)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]model.LedgerEntry, 0)
+	for rows.Next() {
+		var e model.LedgerEntry
+		if err := rows.Scan(&e.EntryID, &e.CUSDECNumber, &e.AccountID, &e.InstituteID, &e.Debit, &e.Credit, &e.Timestamp); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return entries, nil
+}
+
+const transactionColumns = "transaction_id, idempotency_key, cusdec_number, account_id, to_institute_id, from_institute_id, bank_id, amount_payable, amount, state, payment_state, reason, is_verified, created_at, updated_at, settled_at"
+
+func (r *SQLRepository) SaveTransaction(t model.Trnasction) error {
+	var key interface{}
+	if t.IdempotencyKey != "" {
+		key = t.IdempotencyKey
+	}
+	_, err := r.db.Exec("INSERT INTO transactions ("+transactionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		t.TransactionID, key, t.CUSDECNumber, t.AccountID, t.ToInstituteID, t.FromInstituteID, t.BankId, t.AmountPayable, t.Amount, t.State, t.PaymentState, t.Reason, t.IsVerified, t.CreatedAt.UTC(), t.UpdatedAt.UTC(), nullTime(t.SettledAt))
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *SQLRepository) UpdateTransaction(t model.Trnasction) error {
+	res, err := r.db.Exec("UPDATE transactions SET cusdec_number = ?, account_id = ?, to_institute_id = ?, from_institute_id = ?, bank_id = ?, amount_payable = ?, amount = ?, state = ?, reason = ?, is_verified = ?, updated_at = ? WHERE transaction_id = ?",
+		t.CUSDECNumber, t.AccountID, t.ToInstituteID, t.FromInstituteID, t.BankId, t.AmountPayable, t.Amount, t.State, t.Reason, t.IsVerified, t.UpdatedAt.UTC(), t.TransactionID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLRepository) GetTransaction(id string) (model.Trnasction, error) {
+	row := r.db.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE transaction_id = ?", id)
+	t, err := scanTransaction(row)
+	if err == sql.ErrNoRows {
+		return model.Trnasction{}, ErrNotFound
+	}
+	return t, err
+}
+
+func (r *SQLRepository) GetTransactionByKey(key string) (model.Trnasction, error) {
+	row := r.db.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE idempotency_key = ?", key)
+	t, err := scanTransaction(row)
+	if err == sql.ErrNoRows {
+		return model.Trnasction{}, ErrNotFound
+	}
+	return t, err
+}
+
+func (r *SQLRepository) GetTransactionsByCUSDEC(cusdec string) ([]model.Trnasction, error) {
+	rows, err := r.db.Query("SELECT "+transactionColumns+" FROM transactions WHERE cusdec_number = ? ORDER BY created_at", cusdec)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make([]model.Trnasction, 0)
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, t)
+	}
+	return found, rows.Err()
+}
+
+func (r *SQLRepository) ListSettledTransactions(from, to time.Time) ([]model.Trnasction, error) {
+	rows, err := r.db.Query("SELECT "+transactionColumns+" FROM transactions WHERE settled_at >= ? AND settled_at < ? ORDER BY settled_at", from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make([]model.Trnasction, 0)
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, t)
+	}
+	return found, rows.Err()
+}
+
+func (r *SQLRepository) SwapPaymentState(id, from, to string) error {
+	now := time.Now().UTC()
+	var settled interface{}
+	if to == model.PaymentSettled {
+		settled = now
+	}
+	res, err := r.db.Exec("UPDATE transactions SET payment_state = ?, updated_at = ?, settled_at = COALESCE(?, settled_at) WHERE transaction_id = ? AND payment_state = ?", to, now, settled, id, from)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(res); err != ErrNotFound {
+		return err
+	}
+	return r.staleOrMissing(id)
+}
+
+func (r *SQLRepository) ReversePayment(id, from, to, reason string, entries []model.LedgerEntry) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("UPDATE transactions SET payment_state = ?, state = ?, reason = ?, updated_at = ? WHERE transaction_id = ? AND payment_state = ?",
+		to, model.TransactionRejected, reason, time.Now().UTC(), id, from)
+	if err == nil {
+		err = requireRow(res)
+	}
+	if err != nil {
+		tx.Rollback()
+		if err == ErrNotFound {
+			return r.staleOrMissing(id)
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		_, err := tx.Exec("INSERT INTO ledger_entries (entry_id, cusdec_number, account_id, institute_id, debit, credit, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			e.EntryID, e.CUSDECNumber, e.AccountID, e.InstituteID, e.Debit, e.Credit, e.Timestamp.UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if len(entries) > 0 {
+		if _, err := tx.Exec("DELETE FROM ledger_postings WHERE cusdec_number = (SELECT cusdec_number FROM transactions WHERE transaction_id = ?)", id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// staleOrMissing explains a compare-and-set update of transaction id that
+// touched no row: the transaction is either gone or in another state.
+func (r *SQLRepository) staleOrMissing(id string) error {
+	if _, err := r.GetTransaction(id); err != nil {
+		return err
+	}
+	return ErrStale
+}
+
+func (r *SQLRepository) SaveInstitute(i model.Institute) error {
+	_, err := r.db.Exec("INSERT INTO institutes (institute_id, institute_name, type) VALUES (?, ?, ?)", i.InstituteID, i.InstituteName, i.Type)
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *SQLRepository) UpdateInstitute(i model.Institute) error {
+	res, err := r.db.Exec("UPDATE institutes SET institute_name = ?, type = ? WHERE institute_id = ?", i.InstituteName, i.Type, i.InstituteID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLRepository) DeleteInstitute(id string) error {
+	res, err := r.db.Exec("DELETE FROM institutes WHERE institute_id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLRepository) GetInstitute(id string) (model.Institute, error) {
+	var i model.Institute
+	err := r.db.QueryRow("SELECT institute_id, institute_name, type FROM institutes WHERE institute_id = ?", id).Scan(&i.InstituteID, &i.InstituteName, &i.Type)
+	if err == sql.ErrNoRows {
+		return model.Institute{}, ErrNotFound
+	}
+	return i, err
+}
+
+func (r *SQLRepository) ListInstitutes(f InstituteFilter, offset, limit int) ([]model.Institute, int, error) {
+	where, args := "WHERE 1 = 1", []interface{}{}
+	if f.Type != "" {
+		where += " AND type = ?"
+		args = append(args, f.Type)
+	}
+	if f.Name != "" {
+		where += " AND institute_name LIKE ?"
+		args = append(args, like(f.Name))
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM institutes "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query("SELECT institute_id, institute_name, type FROM institutes "+where+" ORDER BY institute_id LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	found := make([]model.Institute, 0)
+	for rows.Next() {
+		var i model.Institute
+		if err := rows.Scan(&i.InstituteID, &i.InstituteName, &i.Type); err != nil {
+			return nil, 0, err
+		}
+		found = append(found, i)
+	}
+	return found, total, rows.Err()
+}
+
+const notificationColumns = "notification_id, event, channel, recipient, subject, body, state, attempts, last_error, next_attempt, created_at"
+
+func (r *SQLRepository) SaveNotification(n model.Notification) error {
+	_, err := r.db.Exec("INSERT INTO notifications ("+notificationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		n.NotificationID, n.Event, n.Channel, n.To, n.Subject, n.Body, n.State, n.Attempts, n.LastError, n.NextAttempt.UTC(), n.CreatedAt.UTC())
+	if isDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *SQLRepository) UpdateNotification(n model.Notification) error {
+	res, err := r.db.Exec("UPDATE notifications SET state = ?, attempts = ?, last_error = ?, next_attempt = ? WHERE notification_id = ?",
+		n.State, n.Attempts, n.LastError, n.NextAttempt.UTC(), n.NotificationID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (r *SQLRepository) DueNotifications(now time.Time, limit int) ([]model.Notification, error) {
+	rows, err := r.db.Query("SELECT "+notificationColumns+" FROM notifications WHERE state = ? AND next_attempt <= ? ORDER BY created_at LIMIT ?",
+		model.NotificationQueued, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := make([]model.Notification, 0)
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(&n.NotificationID, &n.Event, &n.Channel, &n.To, &n.Subject, &n.Body, &n.State, &n.Attempts, &n.LastError, &n.NextAttempt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		due = append(due, n)
+	}
+	return due, rows.Err()
+}
+
+func (r *SQLRepository) AddAudit(a model.AuditRecord) error {
+	_, err := r.db.Exec("INSERT INTO audit_log (record_id, entity, entity_id, from_state, to_state, actor, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		a.RecordID, a.Entity, a.EntityID, a.From, a.To, a.Actor, a.Timestamp.UTC())
+	return err
+}
+
+func (r *SQLRepository) GetAudit(entity, id string) ([]model.AuditRecord, error) {
+	rows, err := r.db.Query("SELECT record_id, entity, entity_id, from_state, to_state, actor, created_at FROM audit_log WHERE entity = ? AND entity_id = ? ORDER BY created_at", entity, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make([]model.AuditRecord, 0)
+	for rows.Next() {
+		var a model.AuditRecord
+		if err := rows.Scan(&a.RecordID, &a.Entity, &a.EntityID, &a.From, &a.To, &a.Actor, &a.Timestamp); err != nil {
+			return nil, err
+		}
+		found = append(found, a)
+	}
+	return found, rows.Err()
+}
+
+// MySQL server errors.
+const (
+	errDupFieldName = 1060 // ER_DUP_FIELDNAME
+	errDupKeyName   = 1061 // ER_DUP_KEYNAME
+	errDupEntry     = 1062 // ER_DUP_ENTRY
+)
+
+func isDuplicate(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && e.Number == errDupEntry
+}
+
+// alreadyMigrated reports whether a migration failed only because the
+// column or index it adds is already there.
+func alreadyMigrated(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && (e.Number == errDupFieldName || e.Number == errDupKeyName)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(s scanner) (model.Account, error) {
+	var a model.Account
+	err := s.Scan(&a.Number, &a.Type, &a.Name, &a.TransactionID, &a.CardNumber, &a.CardType, &a.NameonCard, &a.Expiry, &a.DisplayName, &a.Status, &a.Email, &a.Phone, &a.CardToken, &a.CardCipher, &a.Owner)
+	return a, err
+}
+
+func scanTransaction(s scanner) (model.Trnasction, error) {
+	var t model.Trnasction
+	var key sql.NullString
+	var settled mysql.NullTime
+	err := s.Scan(&t.TransactionID, &key, &t.CUSDECNumber, &t.AccountID, &t.ToInstituteID, &t.FromInstituteID, &t.BankId, &t.AmountPayable, &t.Amount, &t.State, &t.PaymentState, &t.Reason, &t.IsVerified, &t.CreatedAt, &t.UpdatedAt, &settled)
+	t.IdempotencyKey = key.String
+	t.SettledAt = settled.Time
+	return t, err
+}
+
+// nullTime stores the zero time as NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// like turns a substring into a LIKE pattern, escaping the wildcards in it.
+func like(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -1,25 +1,59 @@
 package main
 
 import (
+	"pay.gov.lk/auth"
+	"pay.gov.lk/config"
 	"pay.gov.lk/lib"
+	"pay.gov.lk/logic"
+	"pay.gov.lk/notify"
+	"pay.gov.lk/repository"
 	"duov6.com/cebadapter"
 	"duov6.com/gorest"
 	"duov6.com/term"
+	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
 
 func main() {
 
+	// The shipped Auth.config serves plain HTTP and validates tokens against a
+	// DuoAuth on localhost. Deployments turn HTTPS on and point at their DuoAuth
+	// with PAYGOV_HTTPS_ENABLED, PAYGOV_CERTIFICATE, PAYGOV_PRIVATE_KEY and
+	// PAYGOV_AUTH_SERVICE.
+	c, err := config.Load("Auth.config")
+	if err != nil {
+		term.Write(err.Error(), term.Error)
+		os.Exit(1)
+	}
+	if err := setup(c); err != nil {
+		term.Write(err.Error(), term.Error)
+		os.Exit(1)
+	}
+	config.OnChange(func(n config.Config) {
+		if n.DocumentKey != "" {
+			logic.SetDocumentKey([]byte(n.DocumentKey))
+		}
+		if fields := restartRequired(c, n); len(fields) > 0 {
+			term.Write("Restart the service to apply the new "+strings.Join(fields, ", "), term.Warning)
+		}
+	})
+
 	cebadapter.Attach("DuoAuth", func(s bool){
 		cebadapter.GetLatestGlobalConfig("StoreConfig", func(data []interface{}) {
+			reloadConfig(data)
 			term.Write("Store Configuration Successfully Loaded...", term.Information)
 
 			agent := cebadapter.GetAgent();
-			
+
 			agent.Client.OnEvent("globalConfigChanged.StoreConfig", func(from string, name string, data map[string]interface{}, resources map[string]interface{}){
 				cebadapter.GetLatestGlobalConfig("StoreConfig", func(data []interface{}) {
-					term.Write("Store Configuration Successfully Updated...", term.Information)
+					if reloadConfig(data) {
+						term.Write("Store Configuration Successfully Updated...", term.Information)
+					}
 				});
 			});
 		})
@@ -28,40 +62,127 @@ func main() {
 
 	//paylib.SetupConfig()
 	term.GetConfig()
-	go runRestFul()
+	go runRestFul(c)
 
+	scheme := "http"
+	if c.Https_Enabled {
+		scheme = "https"
+	}
 
 	term.SplashScreen("splash.art")
 	term.Write("================================================================", term.Splash)
 	term.Write("|     Admintration Console running on  :9000                   |", term.Splash)
-	term.Write("|     https RestFul Service running on :3048                   |", term.Splash)
+	term.Write(fmt.Sprintf("|     %-5s RestFul Service running on %-24s|", scheme, c.ListenAddr), term.Splash)
 	term.Write("|     Duo v6 Auth Service 6.0                                  |", term.Splash)
 	term.Write("================================================================", term.Splash)
 	term.StartCommandLine()
 
 }
 
-func runRestFul() {
+// setup wires the parts of the configuration that are only read at startup.
+func setup(c config.Config) error {
+	var repo repository.Repository = repository.NewMemoryRepository()
+	if c.DatabaseDSN != "" {
+		r, err := repository.OpenSQLRepository(c.DatabaseDSN)
+		if err != nil {
+			return err
+		}
+		repo = r
+	}
+	logic.UseRepository(repo)
+
+	outbox := notify.NewOutbox(repo)
+	if c.Smtpserver != "" {
+		ch, err := notify.NewEmailChannel(c.Smtpserver, c.Smtpusername, c.Smtppassword, c.Smtpfrom)
+		if err != nil {
+			return err
+		}
+		outbox.Register(ch)
+	}
+	if c.TwilioAccountSid != "" {
+		outbox.Register(notify.NewSMSChannel(c.TwilioAccountSid, c.TwilioAuthToken, c.TwilioFrom))
+	}
+	logic.UseNotifier(outbox)
+	go outbox.Run(30*time.Second, nil)
+
+	if c.ReportDir != "" {
+		go logic.NewReconciliationJob(c.ReportDir).Run(time.Hour, nil)
+	}
+
+	if c.DocumentKey != "" {
+		logic.SetDocumentKey([]byte(c.DocumentKey))
+	}
+	if c.CardKey != "" {
+		logic.SetCardKey([]byte(c.CardKey))
+	}
+	return nil
+}
+
+// restartRequired lists the settings that differ between the configuration
+// the service started with and a reloaded one but are only read at startup.
+// Everything except DocumentKey is.
+func restartRequired(running, next config.Config) []string {
+	var fields []string
+	for _, f := range config.Changed(running, next) {
+		if f != "DocumentKey" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// reloadConfig applies the StoreConfig objects sent by CEB on top of the
+// config file. A configuration that fails validation is logged and ignored.
+func reloadConfig(data []interface{}) bool {
+	overrides := make(map[string]interface{})
+	for _, d := range data {
+		if m, ok := d.(map[string]interface{}); ok {
+			for k, v := range m {
+				overrides[k] = v
+			}
+		}
+	}
+	if _, err := config.Reload(overrides); err != nil {
+		term.Write("Store Configuration rejected: "+err.Error(), term.Error)
+		return false
+	}
+	return true
+}
+
+func runRestFul(c config.Config) {
+	if c.AuthLocal {
+		l := auth.NewLocalIssuer()
+		users, _ := c.LocalUsers() // checked by config.Load
+		for _, u := range users {
+			l.AddUser(u.Name, u.Password, u.Roles...)
+		}
+		lib.UseLocalIssuer(l)
+		gorest.RegisterRealmAuthorizer(auth.Realm, auth.Authorizer(l))
+		gorest.RegisterService(new(lib.TokenService))
+		term.Write("Using local session tokens; POST /auth/token/ to get one", term.Warning)
+	} else {
+		gorest.RegisterRealmAuthorizer(auth.Realm, auth.Authorizer(auth.NewDuoAuthValidator(c.AuthService, c.AuthDomain)))
+	}
+
 	gorest.RegisterService(new(lib.PayService))
 	gorest.RegisterService(new(lib.BankService))
 	gorest.RegisterService(new(lib.DocService))
 	gorest.RegisterService(new(lib.AccountService))
+	gorest.RegisterService(new(lib.AuditService))
+	gorest.RegisterService(new(lib.ReconciliationService))
+
+	server := &http.Server{Addr: c.ListenAddr, Handler: gorest.Handle()}
 
-/*
-	c := authlib.GetConfig()
+	var err error
 	if c.Https_Enabled {
-		err := http.ListenAndServeTLS(":4048", c.Cirtifcate, c.PrivateKey, gorest.Handle())
-		if err != nil {
-			term.Write(err.Error(), term.Error)
-			return
-		}
+		server.TLSConfig = config.TLSConfig()
+		err = server.ListenAndServeTLS("", "")
 	} else {
-*/
-		err := http.ListenAndServe(":4048", gorest.Handle())
-		if err != nil {
-			term.Write(err.Error(), term.Error)
-			return
-		}
-//	}
+		err = server.ListenAndServe()
+	}
+	if err != nil {
+		term.Write(err.Error(), term.Error)
+		return
+	}
 
-}
\ No newline at end of file
+}